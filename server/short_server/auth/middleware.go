@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/logging"
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"github.com/danielgtaylor/huma/v2"
+)
+
+const (
+	RoleAdmin = model.RoleAdmin
+	RoleUser  = model.RoleUser
+
+	// SecuritySchemeName is the OpenAPI security scheme operations reference
+	// to require a bearer token.
+	SecuritySchemeName = "bearerAuth"
+
+	// RoleExtension is the operation extension naming the minimum role an
+	// authenticated caller needs. It is rendered into the OpenAPI document.
+	RoleExtension = "x-required-role"
+)
+
+var (
+	// BearerSecurity is the Security requirement for operations that need a
+	// valid access token.
+	BearerSecurity = []map[string][]string{{SecuritySchemeName: {}}}
+)
+
+// Identity is the authenticated caller attached to the request context.
+type Identity struct {
+	UserID string
+	Email  string
+	Role   string
+}
+
+type identityKey struct{}
+
+// ErrUnknownAccount is returned by Accounts when a token's subject no longer
+// exists.
+var ErrUnknownAccount = errors.New("unknown account")
+
+// Account is the stored state of a token's subject.
+type Account struct {
+	Role     string
+	Disabled bool
+	// AccessToken is the access token last issued to the account. Any other
+	// token has been rotated out or revoked.
+	AccessToken string
+}
+
+// Accounts looks up the account behind a token subject.
+type Accounts interface {
+	Account(ctx context.Context, userID string) (Account, error)
+}
+
+// WithIdentity returns a copy of ctx carrying id.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext returns the caller attached by Middleware, if any.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// SecurityScheme describes the bearer JWT scheme for huma.Config.Components.
+func SecurityScheme() *huma.SecurityScheme {
+	return &huma.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+	}
+}
+
+// RequireRole returns operation extensions declaring the minimum role.
+func RequireRole(role string) map[string]any {
+	return map[string]any{RoleExtension: role}
+}
+
+// Middleware verifies bearer tokens for operations that declare
+// SecuritySchemeName in their Security requirements and enforces the role
+// from RoleExtension. Public operations still receive the caller's identity
+// when a valid token is sent, but an invalid one is ignored there.
+//
+// Whenever a token is valid the account is also looked up in accounts, so a
+// disabled, deleted or demoted user, or a token whose session was revoked,
+// loses access at once rather than when the token expires. The identity
+// carries the stored role. A token failing that check is treated like an
+// invalid one: rejected on protected operations and ignored on public ones,
+// which then see an anonymous caller. A nil accounts trusts the token's
+// claims alone.
+func Middleware(api huma.API, tokens *TokenManager, accounts Accounts) func(ctx huma.Context, next func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		op := ctx.Operation()
+		protected := requiresBearer(op)
+
+		raw, hasToken := bearerToken(ctx.Header("Authorization"))
+		if !hasToken {
+			if protected {
+				ctx.SetHeader("WWW-Authenticate", `Bearer realm="api"`)
+				_ = huma.WriteErr(api, ctx, http.StatusUnauthorized, "missing bearer token")
+				return
+			}
+			next(ctx)
+			return
+		}
+
+		claims, err := tokens.Parse(raw, TokenTypeAccess)
+		if err != nil {
+			if protected {
+				ctx.SetHeader("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+				_ = huma.WriteErr(api, ctx, http.StatusUnauthorized, "invalid or expired token")
+				return
+			}
+			next(ctx)
+			return
+		}
+
+		id := Identity{UserID: claims.Subject, Email: claims.Email, Role: claims.Role}
+		logging.SetUserID(ctx.Context(), id.UserID)
+		if accounts != nil {
+			account, err := accounts.Account(ctx.Context(), id.UserID)
+			if err != nil && !errors.Is(err, ErrUnknownAccount) {
+				slog.ErrorContext(ctx.Context(), "look up account failed", "op", op.OperationID, "user_id", id.UserID, "err", err)
+				_ = huma.WriteErr(api, ctx, http.StatusInternalServerError, "could not verify session")
+				return
+			}
+			if err != nil || account.Disabled || account.AccessToken != raw {
+				if protected {
+					ctx.SetHeader("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+					_ = huma.WriteErr(api, ctx, http.StatusUnauthorized, "session is no longer valid")
+					return
+				}
+				next(ctx)
+				return
+			}
+			id.Role = account.Role
+		}
+		if protected && !HasRole(id, requiredRole(op)) {
+			_ = huma.WriteErr(api, ctx, http.StatusForbidden, "insufficient role")
+			return
+		}
+		next(huma.WithValue(ctx, identityKey{}, id))
+	}
+}
+
+// HasRole reports whether id satisfies the required role. Admins satisfy
+// every role; an empty requirement only needs authentication.
+func HasRole(id Identity, required string) bool {
+	switch required {
+	case "", RoleUser:
+		return id.Role == RoleUser || id.Role == RoleAdmin
+	default:
+		return id.Role == required || id.Role == RoleAdmin
+	}
+}
+
+func requiresBearer(op *huma.Operation) bool {
+	if op == nil {
+		return false
+	}
+	for _, req := range op.Security {
+		if _, ok := req[SecuritySchemeName]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func requiredRole(op *huma.Operation) string {
+	if op == nil || op.Extensions == nil {
+		return ""
+	}
+	role, _ := op.Extensions[RoleExtension].(string)
+	return role
+}
+
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
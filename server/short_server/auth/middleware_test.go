@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/logging"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/humatest"
+)
+
+type whoamiOutput struct {
+	Body struct {
+		UserID string `json:"user_id"`
+		Role   string `json:"role"`
+	}
+}
+
+func whoami(ctx context.Context, in *struct{}) (*whoamiOutput, error) {
+	out := &whoamiOutput{}
+	if id, ok := IdentityFromContext(ctx); ok {
+		out.Body.UserID = id.UserID
+		out.Body.Role = id.Role
+	}
+	return out, nil
+}
+
+// testAccounts serves accounts from a map keyed by user ID.
+type testAccounts map[string]Account
+
+func (a testAccounts) Account(ctx context.Context, userID string) (Account, error) {
+	account, ok := a[userID]
+	if !ok {
+		return Account{}, ErrUnknownAccount
+	}
+	return account, nil
+}
+
+func newTestAPI(t *testing.T, tm *TokenManager, accounts Accounts) humatest.TestAPI {
+	_, api := humatest.New(t)
+	api.UseMiddleware(Middleware(api, tm, accounts))
+	huma.Register(api, huma.Operation{
+		OperationID: "public", Method: http.MethodGet, Path: "/public",
+	}, whoami)
+	huma.Register(api, huma.Operation{
+		OperationID: "user", Method: http.MethodGet, Path: "/user",
+		Security: BearerSecurity, Extensions: RequireRole(RoleUser),
+	}, whoami)
+	huma.Register(api, huma.Operation{
+		OperationID: "admin", Method: http.MethodGet, Path: "/admin",
+		Security: BearerSecurity, Extensions: RequireRole(RoleAdmin),
+	}, whoami)
+	return api
+}
+
+func TestMiddleware(t *testing.T) {
+	tm, err := NewTokenManager("test-secret", time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("new token manager: %v", err)
+	}
+	api := newTestAPI(t, tm, nil)
+
+	userPair, _ := tm.Issue("u1", "u@x.com", RoleUser)
+	adminPair, _ := tm.Issue("a1", "a@x.com", RoleAdmin)
+	bearer := func(tok string) string { return "Authorization: Bearer " + tok }
+
+	cases := []struct {
+		name   string
+		path   string
+		header []any
+		want   int
+	}{
+		{"public without token", "/public", nil, http.StatusOK},
+		{"public with invalid token", "/public", []any{bearer("garbage")}, http.StatusOK},
+		{"user without token", "/user", nil, http.StatusUnauthorized},
+		{"user with invalid token", "/user", []any{bearer("garbage")}, http.StatusUnauthorized},
+		{"user with refresh token", "/user", []any{bearer(userPair.RefreshToken)}, http.StatusUnauthorized},
+		{"user with user token", "/user", []any{bearer(userPair.AccessToken)}, http.StatusOK},
+		{"user with admin token", "/user", []any{bearer(adminPair.AccessToken)}, http.StatusOK},
+		{"admin with user token", "/admin", []any{bearer(userPair.AccessToken)}, http.StatusForbidden},
+		{"admin with admin token", "/admin", []any{bearer(adminPair.AccessToken)}, http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := api.Get(tc.path, tc.header...)
+			if resp.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, resp.Code, resp.Body.String())
+			}
+		})
+	}
+
+	t.Run("identity reaches handler", func(t *testing.T) {
+		resp := api.Get("/admin", bearer(adminPair.AccessToken))
+		if want := `"user_id":"a1"`; !strings.Contains(resp.Body.String(), want) {
+			t.Fatalf("expected body to contain %s, got %s", want, resp.Body.String())
+		}
+	})
+}
+
+func TestMiddlewareRecordsUserForAccessLog(t *testing.T) {
+	tm, _ := NewTokenManager("test-secret", time.Minute, time.Hour)
+	api := newTestAPI(t, tm, nil)
+	pair, _ := tm.Issue("u1", "u@x.com", RoleUser)
+
+	info := &logging.RequestInfo{ID: "req-1"}
+	ctx := logging.WithRequestInfo(context.Background(), info)
+	// A valid token is recorded even when the role check then fails.
+	api.GetCtx(ctx, "/admin", "Authorization: Bearer "+pair.AccessToken)
+	if got := info.UserID(); got != "u1" {
+		t.Fatalf("expected user id to be recorded, got %q", got)
+	}
+}
+
+func TestMiddlewareChecksAccount(t *testing.T) {
+	tm, _ := NewTokenManager("test-secret", time.Minute, time.Hour)
+	adminPair, _ := tm.Issue("a1", "a@x.com", RoleAdmin)
+	rotated, _ := tm.Issue("a1", "a@x.com", RoleAdmin)
+	accounts := testAccounts{"a1": {Role: RoleAdmin, AccessToken: adminPair.AccessToken}}
+	api := newTestAPI(t, tm, accounts)
+	bearer := "Authorization: Bearer " + adminPair.AccessToken
+
+	if resp := api.Get("/admin", bearer); resp.Code != http.StatusOK {
+		t.Fatalf("expected current token to pass, got %d", resp.Code)
+	}
+	if resp := api.Get("/admin", "Authorization: Bearer "+rotated.AccessToken); resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a token other than the stored one to be rejected, got %d", resp.Code)
+	}
+
+	accounts["a1"] = Account{Role: RoleUser, AccessToken: adminPair.AccessToken}
+	if resp := api.Get("/admin", bearer); resp.Code != http.StatusForbidden {
+		t.Fatalf("expected demoted admin to be forbidden, got %d", resp.Code)
+	}
+	if resp := api.Get("/user", bearer); !strings.Contains(resp.Body.String(), `"role":"USER"`) {
+		t.Fatalf("expected the stored role to reach the handler, got %s", resp.Body.String())
+	}
+
+	accounts["a1"] = Account{Role: RoleAdmin, Disabled: true, AccessToken: adminPair.AccessToken}
+	if resp := api.Get("/user", bearer); resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected disabled account to be rejected, got %d", resp.Code)
+	}
+
+	if resp := api.Get("/public", bearer); resp.Code != http.StatusOK || strings.Contains(resp.Body.String(), "a1") {
+		t.Fatalf("expected a disabled account to be anonymous on public operations, got %d %s", resp.Code, resp.Body.String())
+	}
+
+	accounts["a1"] = Account{Role: RoleUser, AccessToken: adminPair.AccessToken}
+	if resp := api.Get("/public", bearer); !strings.Contains(resp.Body.String(), `"role":"USER"`) {
+		t.Fatalf("expected public operations to see the stored role, got %s", resp.Body.String())
+	}
+
+	delete(accounts, "a1")
+	if resp := api.Get("/user", bearer); resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected deleted account to be rejected, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+
+	defaultAccessTTL  = 15 * time.Minute
+	defaultRefreshTTL = 7 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Claims is the JWT payload issued for both access and refresh tokens.
+// Subject carries the user's ObjectID in hex form.
+type Claims struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+	Type  string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// TokenPair is the result of a successful login or refresh.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+// TokenManager signs and verifies HS256 access and refresh tokens.
+type TokenManager struct {
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	now        func() time.Time
+}
+
+// NewTokenManager returns a TokenManager signing with secret. Zero TTLs fall
+// back to 15 minutes for access tokens and 7 days for refresh tokens.
+func NewTokenManager(secret string, accessTTL, refreshTTL time.Duration) (*TokenManager, error) {
+	if secret == "" {
+		return nil, errors.New("jwt secret is required")
+	}
+	if accessTTL <= 0 {
+		accessTTL = defaultAccessTTL
+	}
+	if refreshTTL <= 0 {
+		refreshTTL = defaultRefreshTTL
+	}
+	return &TokenManager{
+		secret:     []byte(secret),
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+		now:        time.Now,
+	}, nil
+}
+
+// Issue signs a new access/refresh pair for the given user.
+func (m *TokenManager) Issue(userID, email, role string) (TokenPair, error) {
+	now := m.now()
+	access, err := m.sign(userID, email, role, TokenTypeAccess, now, m.accessTTL)
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("sign access token: %w", err)
+	}
+	refresh, err := m.sign(userID, email, role, TokenTypeRefresh, now, m.refreshTTL)
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("sign refresh token: %w", err)
+	}
+	return TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    m.accessTTL,
+	}, nil
+}
+
+func (m *TokenManager) sign(userID, email, role, typ string, now time.Time, ttl time.Duration) (string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+	claims := Claims{
+		Email: email,
+		Role:  role,
+		Type:  typ,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+}
+
+// Parse verifies the signature, expiry and type of a token and returns its claims.
+func (m *TokenManager) Parse(token, wantType string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return m.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithTimeFunc(m.now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.Type != wantType {
+		return nil, fmt.Errorf("%w: expected %s token", ErrInvalidToken, wantType)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate token id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
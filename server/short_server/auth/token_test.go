@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenManager(t *testing.T) {
+	tm, err := NewTokenManager("test-secret", time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("new token manager: %v", err)
+	}
+
+	t.Run("issues tokens that parse back", func(t *testing.T) {
+		pair, err := tm.Issue("user-1", "a@b.com", "USER")
+		if err != nil {
+			t.Fatalf("issue: %v", err)
+		}
+		if pair.ExpiresIn != time.Minute {
+			t.Fatalf("expected access ttl 1m, got %v", pair.ExpiresIn)
+		}
+
+		claims, err := tm.Parse(pair.AccessToken, TokenTypeAccess)
+		if err != nil {
+			t.Fatalf("parse access: %v", err)
+		}
+		if claims.Subject != "user-1" || claims.Role != "USER" || claims.Email != "a@b.com" {
+			t.Fatalf("unexpected claims: %+v", claims)
+		}
+		if _, err := tm.Parse(pair.RefreshToken, TokenTypeRefresh); err != nil {
+			t.Fatalf("parse refresh: %v", err)
+		}
+	})
+
+	t.Run("rejects token of the wrong type", func(t *testing.T) {
+		pair, _ := tm.Issue("user-1", "a@b.com", "USER")
+		if _, err := tm.Parse(pair.RefreshToken, TokenTypeAccess); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+
+	t.Run("rejects token signed with another secret", func(t *testing.T) {
+		other, _ := NewTokenManager("other-secret", 0, 0)
+		pair, _ := other.Issue("user-1", "a@b.com", "USER")
+		if _, err := tm.Parse(pair.AccessToken, TokenTypeAccess); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+
+	t.Run("rejects expired token", func(t *testing.T) {
+		past, _ := NewTokenManager("test-secret", time.Minute, time.Hour)
+		past.now = func() time.Time { return time.Now().Add(-2 * time.Minute) }
+		pair, _ := past.Issue("user-1", "a@b.com", "USER")
+		if _, err := tm.Parse(pair.AccessToken, TokenTypeAccess); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+
+	t.Run("refresh tokens are unique per issue", func(t *testing.T) {
+		a, _ := tm.Issue("user-1", "a@b.com", "USER")
+		b, _ := tm.Issue("user-1", "a@b.com", "USER")
+		if a.RefreshToken == b.RefreshToken {
+			t.Fatal("expected distinct refresh tokens")
+		}
+	})
+}
+
+func TestNewTokenManagerRequiresSecret(t *testing.T) {
+	if _, err := NewTokenManager("", 0, 0); err == nil {
+		t.Fatal("expected error for empty secret")
+	}
+}
@@ -1,132 +1,93 @@
+// Command dedupe_users removes duplicate users, normalizes emails and builds
+// the unique email index. The same steps run as migration 0001 (see
+// cmd/migrate); this command remains for previewing them with -dry-run,
+// choosing a different survivor and re-running them by hand.
 package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/config"
 	"github.com/beheryahmed1991/ClipsStream/server/short_server/database"
-	"go.mongodb.org/mongo-driver/v2/bson"
-	"go.mongodb.org/mongo-driver/v2/mongo"
-	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/dedupe"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/schema"
 )
 
-type duplicateGroup struct {
-	Email string          `bson:"_id"`
-	IDs   []bson.ObjectID `bson:"ids"`
-	Count int             `bson:"count"`
-}
-
 func main() {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	configPath := flag.String("config", "", "path to a YAML or TOML config file (defaults to $CONFIG_FILE)")
+	dryRun := flag.Bool("dry-run", false, "report what would be deleted without changing anything")
+	keep := flag.String("keep", dedupe.KeepOldest, "which duplicate survives: oldest, newest or most-complete (an active admin always does)")
+	reportPath := flag.String("report", "", "write a report to this file; .csv writes CSV, anything else JSON (- for stdout)")
+	archiveName := flag.String("archive", dedupe.ArchiveCollection, "collection deleted users are copied to")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
 
-	col, err := database.OpenCollection("users")
+	cfg, err := config.Load(*configPath)
 	if err != nil {
-		log.Fatalf("open users collection: %v", err)
+		log.Fatalf("load configuration: %v", err)
 	}
-
-	removed, groups, err := removeDuplicateUsers(ctx, col)
+	db, err := database.Connect(ctx, cfg.Mongo)
 	if err != nil {
-		log.Fatalf("remove duplicates: %v", err)
+		log.Fatalf("connect database: %v", err)
 	}
+	defer db.Disconnect(context.Background())
 
-	if err := normalizeEmails(ctx, col); err != nil {
-		log.Fatalf("normalize emails: %v", err)
+	col, err := db.Collection("users")
+	if err != nil {
+		log.Fatalf("open users collection: %v", err)
 	}
-
-	if err := ensureUniqueEmailIndex(ctx, col); err != nil {
-		log.Fatalf("ensure unique email index: %v", err)
+	archive, err := db.Collection(*archiveName)
+	if err != nil {
+		log.Fatalf("open archive collection: %v", err)
 	}
 
-	fmt.Printf("Done. Duplicate groups: %d, removed users: %d\n", groups, removed)
-}
-
-func removeDuplicateUsers(ctx context.Context, col *mongo.Collection) (int, int, error) {
-	pipeline := mongo.Pipeline{
-		{{Key: "$match", Value: bson.M{
-			"email": bson.M{
-				"$type": "string",
-				"$ne":   "",
-			},
-		}}},
-		{{Key: "$addFields", Value: bson.M{
-			"norm_email": bson.M{
-				"$toLower": bson.M{
-					"$trim": bson.M{"input": "$email"},
-				},
-			},
-		}}},
-		{{Key: "$sort", Value: bson.M{"_id": 1}}},
-		{{Key: "$group", Value: bson.M{
-			"_id":   "$norm_email",
-			"count": bson.M{"$sum": 1},
-		}}},
-		{{Key: "$match", Value: bson.M{
-			"count": bson.M{"$gt": 1},
-		}}},
+	report, err := dedupe.Users(ctx, col, archive, dedupe.Options{Keep: *keep, DryRun: *dryRun})
+	if report != nil && *reportPath != "" {
+		if werr := writeReport(*reportPath, report); werr != nil {
+			log.Printf("write report: %v", werr)
+		}
 	}
-
-	cur, err := col.Aggregate(ctx, pipeline)
 	if err != nil {
-		return 0, 0, err
+		log.Fatalf("remove duplicates: %v", err)
 	}
-	defer cur.Close(ctx)
 
-	removed := 0
-	groups := 0
+	users, _ := schema.Lookup("users")
+	changes, err := schema.Reconcile(ctx, db.Database(), []schema.Collection{users}, schema.Options{DryRun: *dryRun})
+	if err != nil {
+		log.Fatalf("ensure unique email index: %v", err)
+	}
+	for _, c := range changes {
+		fmt.Println(c)
+	}
 
-	for cur.Next(ctx) {
-		var g duplicateGroup
-		if err := cur.Decode(&g); err != nil {
-			return removed, groups, err
-		}
-		if len(g.IDs) <= 1 {
-			continue
-		}
+	verb := "removed"
+	if *dryRun {
+		verb = "would remove"
+	}
+	fmt.Printf("Done. Duplicate groups: %d, %s users: %d\n", len(report.Groups), verb, report.Removed)
+}
 
-		groups++
-		deleteIDs := g.IDs[1:]
-		res, err := col.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": deleteIDs}})
+func writeReport(path string, report *dedupe.Report) error {
+	var w io.Writer = os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
 		if err != nil {
-			return removed, groups, err
+			return err
 		}
-		removed += int(res.DeletedCount)
+		defer f.Close()
+		w = f
 	}
-
-	if err := cur.Err(); err != nil {
-		return removed, groups, err
+	if filepath.Ext(path) == ".csv" {
+		return report.WriteCSV(w)
 	}
-	return removed, groups, nil
-}
-
-func normalizeEmails(ctx context.Context, col *mongo.Collection) error {
-	_, err := col.UpdateMany(
-		ctx,
-		bson.M{"email": bson.M{"$type": "string"}},
-		mongo.Pipeline{
-			{{Key: "$set", Value: bson.M{
-				"email": bson.M{
-					"$toLower": bson.M{
-						"$trim": bson.M{"input": "$email"},
-					},
-				},
-			}}},
-		},
-	)
-	return err
-}
-
-func ensureUniqueEmailIndex(ctx context.Context, col *mongo.Collection) error {
-	model := mongo.IndexModel{
-		Keys: bson.D{{Key: "email", Value: 1}},
-		Options: options.Index().
-			SetName("users_email_unique").
-			SetUnique(true).
-			SetPartialFilterExpression(bson.M{
-				"email": bson.M{"$type": "string", "$ne": ""},
-			})}
-	_, err := col.Indexes().CreateOne(ctx, model)
-	return err
+	return report.WriteJSON(w)
 }
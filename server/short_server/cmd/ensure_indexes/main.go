@@ -0,0 +1,60 @@
+// Command ensure_indexes reconciles the database with the indexes and
+// validators declared in the schema package, as the server does at startup,
+// and prints every change.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/config"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/database"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/schema"
+)
+
+func main() {
+	configPath := flag.String("config", "", "path to a YAML or TOML config file (defaults to $CONFIG_FILE)")
+	dryRun := flag.Bool("dry-run", false, "print the changes without making them")
+	dropUnknown := flag.Bool("drop-unknown", false, "drop indexes that are not declared")
+	validation := flag.String("validation", "", "validator mode: off, warn or error (defaults to mongo.schema_validation)")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("load configuration: %v", err)
+	}
+	if *validation != "" {
+		cfg.Mongo.SchemaValidation = *validation
+	}
+	// Only the mongo section matters here, so a missing JWT secret is fine.
+	if err := cfg.Mongo.Validate(); err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	db, err := database.Connect(ctx, cfg.Mongo)
+	if err != nil {
+		log.Fatalf("connect database: %v", err)
+	}
+	defer db.Disconnect(context.Background())
+
+	changes, err := schema.Reconcile(ctx, db.Database(), schema.Collections(), schema.Options{
+		Validation:  cfg.Mongo.SchemaValidation,
+		DropUnknown: *dropUnknown,
+		DryRun:      *dryRun,
+	})
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+	if err != nil {
+		log.Fatalf("reconcile: %v", err)
+	}
+	if len(changes) == 0 {
+		fmt.Println("Indexes are up to date.")
+	}
+}
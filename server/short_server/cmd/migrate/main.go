@@ -0,0 +1,101 @@
+// Command migrate applies, rolls back and lists database migrations.
+//
+//	migrate [-config file] up [-to version]
+//	migrate [-config file] down [-steps n]
+//	migrate [-config file] status
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/config"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/database"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/migrations"
+)
+
+func main() {
+	configPath := flag.String("config", "", "path to a YAML or TOML config file (defaults to $CONFIG_FILE)")
+	timeout := flag.Duration("timeout", 30*time.Minute, "give up after this long")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: migrate [flags] up [-to version] | down [-steps n] | status")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cmd := flag.NewFlagSet(flag.Arg(0), flag.ExitOnError)
+	to := cmd.Int("to", 0, "apply migrations up to this version (default all)")
+	steps := cmd.Int("steps", 1, "number of migrations to roll back")
+	_ = cmd.Parse(flag.Args()[1:])
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("load configuration: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	db, err := database.Connect(ctx, cfg.Mongo)
+	if err != nil {
+		log.Fatalf("connect database: %v", err)
+	}
+	defer db.Disconnect(context.Background())
+
+	m, err := migrations.New(db.Database(), migrations.NewMongoLedger(db.Database()), migrations.All())
+	if err != nil {
+		log.Fatalf("load migrations: %v", err)
+	}
+
+	switch flag.Arg(0) {
+	case "up":
+		applied, err := m.Up(ctx, *to)
+		for _, mig := range applied {
+			fmt.Println("applied", mig)
+		}
+		if err != nil {
+			log.Fatalf("migrate up: %v", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Nothing to apply.")
+		}
+	case "down":
+		rolledBack, err := m.Down(ctx, *steps)
+		for _, mig := range rolledBack {
+			fmt.Println("rolled back", mig)
+		}
+		if err != nil {
+			log.Fatalf("migrate down: %v", err)
+		}
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			log.Fatalf("migrate status: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			switch {
+			case s.Unknown:
+				applied = s.AppliedAt.Format(time.RFC3339) + " (unknown to this binary)"
+			case !s.AppliedAt.IsZero():
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		_ = w.Flush()
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,322 @@
+// Package config loads the server's typed configuration. Values are layered,
+// from lowest to highest precedence: built-in defaults, an optional YAML or
+// TOML file, a .env file and the process environment.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Config is the complete server configuration. Each field carries a conf tag
+// naming its key in the config file (sections are dotted, e.g. mongo.uri) and
+// an env tag naming the environment variable that overrides it.
+type Config struct {
+	Server      ServerConfig      `conf:"server"`
+	API         APIConfig         `conf:"api"`
+	Mongo       MongoConfig       `conf:"mongo"`
+	Auth        AuthConfig        `conf:"auth"`
+	Tracing     TracingConfig     `conf:"tracing"`
+	RateLimit   RateLimitConfig   `conf:"rate_limit"`
+	Idempotency IdempotencyConfig `conf:"idempotency"`
+	Search      SearchConfig      `conf:"search"`
+}
+
+type ServerConfig struct {
+	Addr              string        `conf:"addr" env:"HTTP_ADDR"`
+	ReadHeaderTimeout time.Duration `conf:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT"`
+	ReadTimeout       time.Duration `conf:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout      time.Duration `conf:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `conf:"idle_timeout" env:"HTTP_IDLE_TIMEOUT"`
+	// ShutdownTimeout bounds how long in-flight requests may take to finish
+	// after SIGINT or SIGTERM before their connections are closed.
+	ShutdownTimeout time.Duration `conf:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT"`
+	// DrainDelay keeps accepting requests, with /readyz answering 503, for
+	// this long after a shutdown signal so load balancers can stop routing
+	// to the instance before its listener closes.
+	DrainDelay time.Duration `conf:"drain_delay" env:"HTTP_DRAIN_DELAY"`
+	// ReadinessTimeout bounds each dependency check made by /readyz.
+	ReadinessTimeout time.Duration `conf:"readiness_timeout" env:"HTTP_READINESS_TIMEOUT"`
+}
+
+type APIConfig struct {
+	Title   string `conf:"title" env:"API_TITLE"`
+	Version string `conf:"version" env:"API_VERSION"`
+}
+
+type MongoConfig struct {
+	URI            string        `conf:"uri" env:"MONGODB_URI"`
+	Database       string        `conf:"database" env:"DATABASE_NAME"`
+	MinPoolSize    uint64        `conf:"min_pool_size" env:"MONGODB_MIN_POOL_SIZE"`
+	MaxPoolSize    uint64        `conf:"max_pool_size" env:"MONGODB_MAX_POOL_SIZE"`
+	ConnectTimeout time.Duration `conf:"connect_timeout" env:"MONGODB_CONNECT_TIMEOUT"`
+	QueryTimeout   time.Duration `conf:"query_timeout" env:"MONGODB_QUERY_TIMEOUT"`
+	// EnsureIndexes reconciles the declared indexes (see the schema package)
+	// at startup.
+	EnsureIndexes bool `conf:"ensure_indexes" env:"MONGODB_ENSURE_INDEXES"`
+	// SchemaValidation installs $jsonSchema validators generated from the
+	// models when it is warn or error, the validationAction to use. With off,
+	// existing validators are left alone.
+	SchemaValidation string `conf:"schema_validation" env:"MONGODB_SCHEMA_VALIDATION"`
+	// SkipDuplicateIndexes starts the server even when a unique index cannot
+	// be built because documents share its key, keeping any older index of
+	// that name. Off by default, so startup fails until the duplicates are
+	// removed.
+	SkipDuplicateIndexes bool `conf:"skip_duplicate_indexes" env:"MONGODB_SKIP_DUPLICATE_INDEXES"`
+}
+
+// Schema validation modes.
+const (
+	SchemaValidationOff   = "off"
+	SchemaValidationWarn  = "warn"
+	SchemaValidationError = "error"
+)
+
+type AuthConfig struct {
+	JWTSecret       string        `conf:"jwt_secret" env:"JWT_SECRET"`
+	AccessTokenTTL  time.Duration `conf:"access_token_ttl" env:"ACCESS_TOKEN_TTL"`
+	RefreshTokenTTL time.Duration `conf:"refresh_token_ttl" env:"REFRESH_TOKEN_TTL"`
+}
+
+// Tracing exporters.
+const (
+	TracingExporterNone   = "none"
+	TracingExporterStdout = "stdout"
+	TracingExporterFile   = "file"
+	TracingExporterOTLP   = "otlp"
+)
+
+type TracingConfig struct {
+	// Exporter is one of none, stdout, file or otlp. With none, spans are
+	// still created so trace IDs reach the logs, but nothing is exported.
+	Exporter    string  `conf:"exporter" env:"TRACING_EXPORTER"`
+	File        string  `conf:"file" env:"TRACING_FILE"`
+	Endpoint    string  `conf:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	ServiceName string  `conf:"service_name" env:"OTEL_SERVICE_NAME"`
+	SampleRatio float64 `conf:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+}
+
+type RateLimitConfig struct {
+	Enabled bool `conf:"enabled" env:"RATE_LIMIT_ENABLED"`
+	// Default applies to operations without a limit of their own, in
+	// ratelimit.ParseLimit syntax such as "120/1m".
+	Default string `conf:"default" env:"RATE_LIMIT_DEFAULT"`
+	// Operations overrides limits by huma OperationID as a comma-separated
+	// list, e.g. "login=5/1m,add-user=3/1h".
+	Operations string `conf:"operations" env:"RATE_LIMIT_OPERATIONS"`
+	// TrustProxyHeaders keys anonymous clients by X-Forwarded-For instead of
+	// the connection address. Only enable it behind a proxy that sets it.
+	TrustProxyHeaders bool `conf:"trust_proxy_headers" env:"RATE_LIMIT_TRUST_PROXY_HEADERS"`
+	// TrustedProxies is how many proxies in front of the server append to
+	// X-Forwarded-For. The client is the entry that many places from the
+	// right; anything further left was sent by the client and can be forged.
+	TrustedProxies int `conf:"trusted_proxies" env:"RATE_LIMIT_TRUSTED_PROXIES"`
+}
+
+type IdempotencyConfig struct {
+	// TTL is how long a completed response is kept for replay.
+	TTL time.Duration `conf:"ttl" env:"IDEMPOTENCY_TTL"`
+	// LockTimeout is how long a key stays reserved by a request that has not
+	// finished, e.g. because the instance crashed, before it can be reused.
+	LockTimeout time.Duration `conf:"lock_timeout" env:"IDEMPOTENCY_LOCK_TIMEOUT"`
+}
+
+type SearchConfig struct {
+	// Backend serves movie search from the movies_text index (mongo) or from
+	// an inverted index kept in process and built at startup (memory). The
+	// memory backend suits tests and small single-instance deployments.
+	Backend string `conf:"backend" env:"SEARCH_BACKEND"`
+}
+
+// Search backends.
+const (
+	SearchBackendMongo  = "mongo"
+	SearchBackendMemory = "memory"
+)
+
+// Default returns the configuration used when nothing overrides it. The
+// Mongo URI, database name and JWT secret have no safe default and must be
+// provided.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:              ":8080",
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   20 * time.Second,
+			ReadinessTimeout:  2 * time.Second,
+		},
+		API: APIConfig{
+			Title:   "My API",
+			Version: "1.0.0",
+		},
+		Mongo: MongoConfig{
+			MaxPoolSize:      100,
+			ConnectTimeout:   10 * time.Second,
+			QueryTimeout:     10 * time.Second,
+			EnsureIndexes:    true,
+			SchemaValidation: SchemaValidationOff,
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 7 * 24 * time.Hour,
+		},
+		Tracing: TracingConfig{
+			Exporter:    TracingExporterNone,
+			ServiceName: "clipsstream",
+			SampleRatio: 1,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:        true,
+			Default:        "120/1m",
+			TrustedProxies: 1,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:         24 * time.Hour,
+			LockTimeout: time.Minute,
+		},
+		Search: SearchConfig{
+			Backend: SearchBackendMongo,
+		},
+	}
+}
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	return errors.Join(
+		c.Server.Validate(),
+		c.API.Validate(),
+		c.Mongo.Validate(),
+		c.Auth.Validate(),
+		c.Tracing.Validate(),
+		c.Idempotency.Validate(),
+		c.Search.Validate(),
+	)
+}
+
+func (s ServerConfig) Validate() error {
+	var errs []error
+	if s.Addr == "" {
+		errs = append(errs, required("server.addr", "HTTP_ADDR"))
+	}
+	if s.ReadHeaderTimeout <= 0 {
+		errs = append(errs, positive("server.read_header_timeout", "HTTP_READ_HEADER_TIMEOUT"))
+	}
+	if s.ShutdownTimeout <= 0 {
+		errs = append(errs, positive("server.shutdown_timeout", "HTTP_SHUTDOWN_TIMEOUT"))
+	}
+	if s.DrainDelay < 0 {
+		errs = append(errs, errors.New("server.drain_delay (HTTP_DRAIN_DELAY) must not be negative"))
+	}
+	if s.ReadinessTimeout <= 0 {
+		errs = append(errs, positive("server.readiness_timeout", "HTTP_READINESS_TIMEOUT"))
+	}
+	return errors.Join(errs...)
+}
+
+func (a APIConfig) Validate() error {
+	var errs []error
+	if a.Title == "" {
+		errs = append(errs, required("api.title", "API_TITLE"))
+	}
+	if a.Version == "" {
+		errs = append(errs, required("api.version", "API_VERSION"))
+	}
+	return errors.Join(errs...)
+}
+
+func (m MongoConfig) Validate() error {
+	var errs []error
+	if m.URI == "" {
+		errs = append(errs, required("mongo.uri", "MONGODB_URI"))
+	}
+	if m.Database == "" {
+		errs = append(errs, required("mongo.database", "DATABASE_NAME"))
+	}
+	if m.MaxPoolSize == 0 {
+		errs = append(errs, errors.New("mongo.max_pool_size (MONGODB_MAX_POOL_SIZE) must be greater than 0"))
+	}
+	if m.MinPoolSize > m.MaxPoolSize {
+		errs = append(errs, fmt.Errorf("mongo.min_pool_size (%d) must not exceed mongo.max_pool_size (%d)", m.MinPoolSize, m.MaxPoolSize))
+	}
+	if m.ConnectTimeout <= 0 {
+		errs = append(errs, positive("mongo.connect_timeout", "MONGODB_CONNECT_TIMEOUT"))
+	}
+	if m.QueryTimeout <= 0 {
+		errs = append(errs, positive("mongo.query_timeout", "MONGODB_QUERY_TIMEOUT"))
+	}
+	switch m.SchemaValidation {
+	case SchemaValidationOff, SchemaValidationWarn, SchemaValidationError:
+	default:
+		errs = append(errs, fmt.Errorf("mongo.schema_validation (MONGODB_SCHEMA_VALIDATION) must be one of off, warn or error, got %q", m.SchemaValidation))
+	}
+	return errors.Join(errs...)
+}
+
+func (a AuthConfig) Validate() error {
+	var errs []error
+	if a.JWTSecret == "" {
+		errs = append(errs, required("auth.jwt_secret", "JWT_SECRET"))
+	}
+	if a.AccessTokenTTL <= 0 {
+		errs = append(errs, positive("auth.access_token_ttl", "ACCESS_TOKEN_TTL"))
+	}
+	if a.RefreshTokenTTL <= 0 {
+		errs = append(errs, positive("auth.refresh_token_ttl", "REFRESH_TOKEN_TTL"))
+	}
+	if a.AccessTokenTTL > 0 && a.RefreshTokenTTL > 0 && a.AccessTokenTTL >= a.RefreshTokenTTL {
+		errs = append(errs, errors.New("auth.access_token_ttl must be shorter than auth.refresh_token_ttl"))
+	}
+	return errors.Join(errs...)
+}
+
+func (t TracingConfig) Validate() error {
+	var errs []error
+	switch t.Exporter {
+	case TracingExporterNone, TracingExporterStdout, TracingExporterOTLP:
+	case TracingExporterFile:
+		if t.File == "" {
+			errs = append(errs, errors.New("tracing.file (TRACING_FILE) is required when tracing.exporter is file"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter (TRACING_EXPORTER) must be one of none, stdout, file or otlp, got %q", t.Exporter))
+	}
+	if t.ServiceName == "" {
+		errs = append(errs, required("tracing.service_name", "OTEL_SERVICE_NAME"))
+	}
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sample_ratio (TRACING_SAMPLE_RATIO) must be between 0 and 1, got %g", t.SampleRatio))
+	}
+	return errors.Join(errs...)
+}
+
+func (i IdempotencyConfig) Validate() error {
+	var errs []error
+	if i.TTL <= 0 {
+		errs = append(errs, positive("idempotency.ttl", "IDEMPOTENCY_TTL"))
+	}
+	if i.LockTimeout <= 0 {
+		errs = append(errs, positive("idempotency.lock_timeout", "IDEMPOTENCY_LOCK_TIMEOUT"))
+	}
+	return errors.Join(errs...)
+}
+
+func (s SearchConfig) Validate() error {
+	switch s.Backend {
+	case SearchBackendMongo, SearchBackendMemory:
+		return nil
+	default:
+		return fmt.Errorf("search.backend (SEARCH_BACKEND) must be mongo or memory, got %q", s.Backend)
+	}
+}
+
+func required(key, env string) error {
+	return fmt.Errorf("%s is required: set %s or %s in the config file", key, env, key)
+}
+
+func positive(key, env string) error {
+	return fmt.Errorf("%s (%s) must be a positive duration such as 10s", key, env)
+}
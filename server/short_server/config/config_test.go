@@ -0,0 +1,147 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func envMap(m map[string]string) func(string) (string, bool) {
+	return func(k string) (string, bool) {
+		v, ok := m[k]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := writeFile(t, "config.yaml", `
+server:
+  addr: ":9000"
+mongo:
+  uri: mongodb://file
+  database: clips
+  max_pool_size: 50
+  query_timeout: 3s
+auth:
+  jwt_secret: from-file
+`)
+	cfg, err := load(yamlFile, envMap(map[string]string{
+		"MONGODB_URI":      "mongodb://env",
+		"ACCESS_TOKEN_TTL": "5m",
+	}))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if cfg.Server.Addr != ":9000" {
+		t.Errorf("file should override default addr, got %q", cfg.Server.Addr)
+	}
+	if cfg.Mongo.URI != "mongodb://env" {
+		t.Errorf("env should override file uri, got %q", cfg.Mongo.URI)
+	}
+	if cfg.Mongo.MaxPoolSize != 50 || cfg.Mongo.QueryTimeout != 3*time.Second {
+		t.Errorf("file values not applied: %+v", cfg.Mongo)
+	}
+	if cfg.Mongo.ConnectTimeout != 10*time.Second {
+		t.Errorf("default connect timeout lost, got %s", cfg.Mongo.ConnectTimeout)
+	}
+	if cfg.Auth.AccessTokenTTL != 5*time.Minute || cfg.Auth.JWTSecret != "from-file" {
+		t.Errorf("unexpected auth config %+v", cfg.Auth)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected valid config, got %v", err)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+[mongo]
+uri = "mongodb://toml"
+min_pool_size = 5
+connect_timeout = "2s"
+`)
+	cfg, err := load(path, envMap(nil))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Mongo.URI != "mongodb://toml" || cfg.Mongo.MinPoolSize != 5 || cfg.Mongo.ConnectTimeout != 2*time.Second {
+		t.Fatalf("unexpected mongo config %+v", cfg.Mongo)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		env     map[string]string
+		want    string
+	}{
+		{name: "unknown key", file: "c.yaml", content: "mongo:\n  url: x\n", want: `unknown key "mongo.url"`},
+		{name: "bad file duration", file: "c.yaml", content: "mongo:\n  query_timeout: 10\n", want: "mongo.query_timeout: invalid duration"},
+		{name: "bad env duration", env: map[string]string{"REFRESH_TOKEN_TTL": "week"}, want: "REFRESH_TOKEN_TTL: invalid duration"},
+		{name: "bad env integer", env: map[string]string{"MONGODB_MAX_POOL_SIZE": "-1"}, want: "MONGODB_MAX_POOL_SIZE: invalid non-negative integer"},
+		{name: "unsupported extension", file: "c.json", content: "{}", want: "unsupported extension"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := ""
+			if tt.file != "" {
+				path = writeFile(t, tt.file, tt.content)
+			}
+			_, err := load(path, envMap(tt.env))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	if _, err := load(filepath.Join(t.TempDir(), "missing.yaml"), envMap(nil)); err == nil {
+		t.Fatal("expected error for missing config file")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Mongo.MinPoolSize = 200
+	cfg.Auth.AccessTokenTTL = 8 * 24 * time.Hour
+	cfg.Search.Backend = "elastic"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		"mongo.uri is required: set MONGODB_URI",
+		"mongo.database is required: set DATABASE_NAME",
+		"auth.jwt_secret is required: set JWT_SECRET",
+		"mongo.min_pool_size (200) must not exceed mongo.max_pool_size (100)",
+		"auth.access_token_ttl must be shorter than auth.refresh_token_ttl",
+		`search.backend (SEARCH_BACKEND) must be mongo or memory, got "elastic"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestExampleConfigLoads(t *testing.T) {
+	cfg, err := load("../config.example.yaml", envMap(map[string]string{"JWT_SECRET": "s"}))
+	if err != nil {
+		t.Fatalf("load example: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("example config invalid: %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	toml "github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EnvConfigFile names the config file when Load is given no path.
+	EnvConfigFile = "CONFIG_FILE"
+
+	dotEnvFile = ".env"
+)
+
+// Load builds the configuration from defaults, the file at path (or the file
+// named by CONFIG_FILE when path is empty), ./.env and the environment. A
+// missing .env is ignored; a missing config file that was asked for is an
+// error. Load does not validate the result; call Validate for that.
+func Load(path string) (Config, error) {
+	if path == "" {
+		path = os.Getenv(EnvConfigFile)
+	}
+	dotEnv, err := godotenv.Read(dotEnvFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Config{}, fmt.Errorf("read %s: %w", dotEnvFile, err)
+	}
+	return load(path, func(key string) (string, bool) {
+		if v, ok := os.LookupEnv(key); ok {
+			return v, true
+		}
+		v, ok := dotEnv[key]
+		return v, ok
+	})
+}
+
+func load(path string, lookupEnv func(string) (string, bool)) (Config, error) {
+	cfg := Default()
+	fields := configFields(&cfg)
+
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		var errs []error
+		for _, key := range sortedKeys(values) {
+			f, ok := fields[key]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown key %q", path, key))
+				continue
+			}
+			if err := f.set(values[key]); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s: %w", path, key, err))
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			return cfg, err
+		}
+	}
+
+	var errs []error
+	for _, key := range sortedKeys(fields) {
+		f := fields[key]
+		raw, ok := lookupEnv(f.env)
+		if !ok {
+			continue
+		}
+		if err := f.set(raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.env, err))
+		}
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// readFile decodes a YAML or TOML file, chosen by extension, into a flat map
+// of dotted keys to their scalar values rendered as strings. Rendering
+// everything as a string lets the file and the environment share one parser,
+// so durations are written the same way ("15m") in both.
+func readFile(path string) (map[string]string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	var doc map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, &doc)
+	case ".toml":
+		err = toml.Unmarshal(raw, &doc)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported extension %q, want .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten("", doc, values); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func flatten(prefix string, doc map[string]any, out map[string]string) error {
+	for k, v := range doc {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch v := v.(type) {
+		case map[string]any:
+			if err := flatten(key, v, out); err != nil {
+				return err
+			}
+		case []any:
+			return fmt.Errorf("%s: lists are not supported", key)
+		case nil:
+		default:
+			out[key] = fmt.Sprint(v)
+		}
+	}
+	return nil
+}
+
+// field is one settable leaf of Config.
+type field struct {
+	env string
+	v   reflect.Value
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+func (f field) set(raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch {
+	case f.v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, want a value such as 10s or 15m", raw)
+		}
+		f.v.SetInt(int64(d))
+	case f.v.Kind() == reflect.String:
+		f.v.SetString(raw)
+	case f.v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		f.v.SetInt(int64(n))
+	case f.v.Kind() == reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid non-negative integer %q", raw)
+		}
+		f.v.SetUint(n)
+	case f.v.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		f.v.SetFloat(n)
+	case f.v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		f.v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported setting type %s", f.v.Type())
+	}
+	return nil
+}
+
+// configFields indexes every leaf of cfg by its dotted conf key.
+func configFields(cfg *Config) map[string]field {
+	fields := make(map[string]field)
+	root := reflect.ValueOf(cfg).Elem()
+	for i := range root.NumField() {
+		section := root.Type().Field(i).Tag.Get("conf")
+		sv := root.Field(i)
+		for j := range sv.NumField() {
+			sf := sv.Type().Field(j)
+			fields[section+"."+sf.Tag.Get("conf")] = field{env: sf.Tag.Get("env"), v: sv.Field(j)}
+		}
+	}
+	return fields
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/auth"
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/store"
+	"github.com/danielgtaylor/huma/v2"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type (
+	ChangeRoleInput struct {
+		ID   string `path:"id"`
+		Body ChangeRoleRequestBody
+	}
+
+	ChangeRoleRequestBody struct {
+		Role string `json:"role" enum:"ADMIN,USER" validate:"required,oneof=ADMIN USER"`
+	}
+
+	ChangeStatusInput struct {
+		ID   string `path:"id"`
+		Body ChangeStatusRequestBody
+	}
+
+	ChangeStatusRequestBody struct {
+		Status string `json:"status" enum:"ACTIVE,DISABLED" validate:"required,oneof=ACTIVE DISABLED"`
+		Reason string `json:"reason,omitempty" maxLength:"500" validate:"max=500"`
+	}
+
+	DeleteUserInput struct {
+		ID string `path:"id"`
+	}
+)
+
+func RegisterAdminRoutes(api huma.API, h *UserHandler) {
+	huma.Register(api, huma.Operation{
+		OperationID: "change-user-role",
+		Method:      "PUT",
+		Path:        "/users/{id}/role",
+		Summary:     "Change a user's role",
+		Errors:      []int{400, 401, 403, 404, 409, 500},
+		Security:    auth.BearerSecurity,
+		Extensions:  auth.RequireRole(auth.RoleAdmin),
+	}, h.ChangeUserRole)
+	huma.Register(api, huma.Operation{
+		OperationID: "change-user-status",
+		Method:      "PUT",
+		Path:        "/users/{id}/status",
+		Summary:     "Enable or disable a user account",
+		Description: "Disabled users cannot log in or refresh tokens. Disabling revokes the user's sessions, including access tokens already issued.",
+		Errors:      []int{400, 401, 403, 404, 409, 500},
+		Security:    auth.BearerSecurity,
+		Extensions:  auth.RequireRole(auth.RoleAdmin),
+	}, h.ChangeUserStatus)
+	huma.Register(api, huma.Operation{
+		OperationID:   "delete-user",
+		Method:        "DELETE",
+		Path:          "/users/{id}",
+		Summary:       "Delete a user",
+		DefaultStatus: http.StatusNoContent,
+		Errors:        []int{400, 401, 403, 404, 409, 500},
+		Security:      auth.BearerSecurity,
+		Extensions:    auth.RequireRole(auth.RoleAdmin),
+	}, h.DeleteUser)
+}
+
+func (h *UserHandler) ChangeUserRole(ctx context.Context, in *ChangeRoleInput) (*GetUserOutput, error) {
+	objID, err := bson.ObjectIDFromHex(in.ID)
+	if err != nil {
+		return nil, huma.Error400BadRequest("invalid user ID")
+	}
+	if err := validateBody(in.Body); err != nil {
+		return nil, err
+	}
+
+	target, err := h.findUserForAdmin(ctx, objID, "ChangeUserRole")
+	if err != nil {
+		return nil, err
+	}
+	user, err := h.updateUserForAdmin(ctx, objID, store.UserUpdate{Role: &in.Body.Role}, "ChangeUserRole")
+	if err != nil {
+		return nil, err
+	}
+	h.recordAudit(ctx, model.AuditActionChangeRole, objID, map[string]any{
+		"from": target.Role,
+		"to":   in.Body.Role,
+	})
+	return &GetUserOutput{Body: newUserResponse(user)}, nil
+}
+
+func (h *UserHandler) ChangeUserStatus(ctx context.Context, in *ChangeStatusInput) (*GetUserOutput, error) {
+	objID, err := bson.ObjectIDFromHex(in.ID)
+	if err != nil {
+		return nil, huma.Error400BadRequest("invalid user ID")
+	}
+	if err := validateBody(in.Body); err != nil {
+		return nil, err
+	}
+
+	target, err := h.findUserForAdmin(ctx, objID, "ChangeUserStatus")
+	if err != nil {
+		return nil, err
+	}
+
+	update := store.UserUpdate{Status: &in.Body.Status}
+	if in.Body.Status == model.UserStatusDisabled {
+		update = store.RevokeSessions()
+		update.Status = &in.Body.Status
+	}
+
+	user, err := h.updateUserForAdmin(ctx, objID, update, "ChangeUserStatus")
+	if err != nil {
+		return nil, err
+	}
+	from := target.Status
+	if from == "" {
+		from = model.UserStatusActive
+	}
+	h.recordAudit(ctx, model.AuditActionChangeStatus, objID, map[string]any{
+		"from":   from,
+		"to":     in.Body.Status,
+		"reason": in.Body.Reason,
+	})
+	return &GetUserOutput{Body: newUserResponse(user)}, nil
+}
+
+func (h *UserHandler) DeleteUser(ctx context.Context, in *DeleteUserInput) (*struct{}, error) {
+	objID, err := bson.ObjectIDFromHex(in.ID)
+	if err != nil {
+		return nil, huma.Error400BadRequest("invalid user ID")
+	}
+
+	target, err := h.findUserForAdmin(ctx, objID, "DeleteUser")
+	if err != nil {
+		return nil, err
+	}
+	if err := h.users.DeleteKeepingAdmin(ctx, objID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, huma.Error404NotFound("user not found")
+		}
+		if errors.Is(err, store.ErrLastAdmin) {
+			return nil, huma.Error409Conflict("cannot remove the last remaining admin")
+		}
+		slog.ErrorContext(ctx, "delete user failed", "op", "DeleteUser", "user_id", in.ID, "err", err)
+		return nil, fmt.Errorf("delete user: %w", err)
+	}
+	h.recordAudit(ctx, model.AuditActionDeleteUser, objID, map[string]any{
+		"email": target.Email,
+		"role":  target.Role,
+	})
+	return nil, nil
+}
+
+func (h *UserHandler) findUserForAdmin(ctx context.Context, id bson.ObjectID, op string) (model.User, error) {
+	user, err := h.users.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return user, huma.Error404NotFound("user not found")
+		}
+		slog.ErrorContext(ctx, "find user failed", "op", op, "user_id", id.Hex(), "err", err)
+		return user, fmt.Errorf("find user: %w", err)
+	}
+	return user, nil
+}
+
+// updateUserForAdmin applies an admin's change, refusing one that would
+// leave no active admin.
+func (h *UserHandler) updateUserForAdmin(ctx context.Context, id bson.ObjectID, u store.UserUpdate, op string) (model.User, error) {
+	user, err := h.users.UpdateKeepingAdmin(ctx, id, u)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return user, huma.Error404NotFound("user not found")
+		}
+		if errors.Is(err, store.ErrLastAdmin) {
+			return user, huma.Error409Conflict("cannot remove the last remaining admin")
+		}
+		slog.ErrorContext(ctx, "update user failed", "op", op, "user_id", id.Hex(), "err", err)
+		return user, fmt.Errorf("update user: %w", err)
+	}
+	return user, nil
+}
+
+// recordAudit stores which admin performed action on target and when. A
+// failure is logged rather than returned because the action has already
+// been applied.
+func (h *UserHandler) recordAudit(ctx context.Context, action string, target bson.ObjectID, details map[string]any) {
+	actor, _ := auth.IdentityFromContext(ctx)
+	entry := model.AuditEntry{
+		Action:       action,
+		TargetUserID: target.Hex(),
+		ActorID:      actor.UserID,
+		ActorEmail:   actor.Email,
+		Details:      details,
+		At:           time.Now().UTC(),
+	}
+	if err := h.audit.Record(ctx, entry); err != nil {
+		slog.ErrorContext(ctx, "record audit entry failed", "op", "recordAudit", "action", action, "target_user_id", entry.TargetUserID, "actor_id", actor.UserID, "err", err)
+	}
+}
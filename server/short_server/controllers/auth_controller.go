@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/auth"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/ratelimit"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/store"
+	"github.com/danielgtaylor/huma/v2"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type (
+	LoginInput struct {
+		Body LoginRequestBody
+	}
+
+	LoginRequestBody struct {
+		Email    string `json:"email" validate:"required,email"`
+		Password string `json:"password" validate:"required"`
+	}
+
+	RefreshInput struct {
+		Body RefreshRequestBody
+	}
+
+	RefreshRequestBody struct {
+		RefreshToken string `json:"refresh_token" validate:"required"`
+	}
+
+	TokenOutput struct {
+		Body TokenResponseBody `json:"body"`
+	}
+
+	TokenResponseBody struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in" doc:"Access token lifetime in seconds"`
+	}
+)
+
+func RegisterAuthRoutes(api huma.API, h *UserHandler) {
+	huma.Register(api, huma.Operation{
+		OperationID: "login",
+		Method:      "POST",
+		Path:        "/login",
+		Summary:     "Log in with email and password",
+		Errors:      []int{400, 401, 403, 429, 500},
+		Metadata:    ratelimit.Policy(ratelimit.Per(5, time.Minute)),
+	}, h.Login)
+	huma.Register(api, huma.Operation{
+		OperationID: "refresh-token",
+		Method:      "POST",
+		Path:        "/refresh",
+		Summary:     "Exchange a refresh token for a new token pair",
+		Errors:      []int{400, 401, 403, 429, 500},
+		Metadata:    ratelimit.Policy(ratelimit.Per(20, time.Minute)),
+	}, h.Refresh)
+}
+
+func (h *UserHandler) Login(ctx context.Context, in *LoginInput) (*TokenOutput, error) {
+	if err := validateBody(in.Body); err != nil {
+		return nil, err
+	}
+	if h.tokens == nil {
+		slog.ErrorContext(ctx, "token manager not configured", "op", "Login")
+		return nil, huma.Error500InternalServerError("authentication is not configured")
+	}
+
+	normalizedEmail := normalizeEmail(in.Body.Email)
+
+	user, err := h.users.GetByEmailWithCredentials(ctx, normalizedEmail)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, huma.Error401Unauthorized("invalid email or password")
+		}
+		slog.ErrorContext(ctx, "find user failed", "op", "Login", "email", normalizedEmail, "err", err)
+		return nil, fmt.Errorf("find user: %w", err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(in.Body.Password)); err != nil {
+		return nil, huma.Error401Unauthorized("invalid email or password")
+	}
+	if user.IsDisabled() {
+		return nil, huma.Error403Forbidden("account is disabled")
+	}
+
+	pair, err := h.tokens.Issue(user.ID.Hex(), user.Email, user.Role)
+	if err != nil {
+		slog.ErrorContext(ctx, "issue tokens failed", "op", "Login", "user_id", user.ID.Hex(), "err", err)
+		return nil, huma.Error500InternalServerError("failed to issue tokens")
+	}
+
+	if _, err := h.users.Update(ctx, user.ID, store.UserUpdate{
+		Token:        &pair.AccessToken,
+		RefreshToken: &pair.RefreshToken,
+	}); err != nil {
+		slog.ErrorContext(ctx, "store tokens failed", "op", "Login", "user_id", user.ID.Hex(), "err", err)
+		return nil, fmt.Errorf("store tokens: %w", err)
+	}
+
+	return &TokenOutput{Body: newTokenResponse(pair)}, nil
+}
+
+func newTokenResponse(pair auth.TokenPair) TokenResponseBody {
+	return TokenResponseBody{
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(pair.ExpiresIn.Seconds()),
+	}
+}
+
+// Refresh rotates the caller's refresh token. Each refresh token is accepted
+// exactly once: presenting one that has already been rotated is treated as
+// theft and revokes every session of the user. Revoking clears the stored
+// access token too, so tokens already handed out stop working on protected
+// operations at once instead of when they expire.
+func (h *UserHandler) Refresh(ctx context.Context, in *RefreshInput) (*TokenOutput, error) {
+	if err := validateBody(in.Body); err != nil {
+		return nil, err
+	}
+	if h.tokens == nil {
+		slog.ErrorContext(ctx, "token manager not configured", "op", "Refresh")
+		return nil, huma.Error500InternalServerError("authentication is not configured")
+	}
+
+	claims, err := h.tokens.Parse(in.Body.RefreshToken, auth.TokenTypeRefresh)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("invalid refresh token")
+	}
+	objID, err := bson.ObjectIDFromHex(claims.Subject)
+	if err != nil {
+		return nil, huma.Error401Unauthorized("invalid refresh token")
+	}
+
+	user, err := h.users.GetWithCredentials(ctx, objID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, huma.Error401Unauthorized("invalid refresh token")
+		}
+		slog.ErrorContext(ctx, "find user failed", "op", "Refresh", "user_id", claims.Subject, "err", err)
+		return nil, fmt.Errorf("find user: %w", err)
+	}
+	if user.IsDisabled() {
+		return nil, huma.Error403Forbidden("account is disabled")
+	}
+	if user.RefreshToken == "" || user.RefreshToken != in.Body.RefreshToken {
+		return nil, h.refreshTokenReused(ctx, objID)
+	}
+
+	pair, err := h.tokens.Issue(user.ID.Hex(), user.Email, user.Role)
+	if err != nil {
+		slog.ErrorContext(ctx, "issue tokens failed", "op", "Refresh", "user_id", claims.Subject, "err", err)
+		return nil, huma.Error500InternalServerError("failed to issue tokens")
+	}
+
+	err = h.users.RotateRefreshToken(ctx, objID, in.Body.RefreshToken, pair.AccessToken, pair.RefreshToken)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, h.refreshTokenReused(ctx, objID)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "rotate refresh token failed", "op", "Refresh", "user_id", claims.Subject, "err", err)
+		return nil, fmt.Errorf("rotate refresh token: %w", err)
+	}
+
+	return &TokenOutput{Body: newTokenResponse(pair)}, nil
+}
+
+func (h *UserHandler) refreshTokenReused(ctx context.Context, userID bson.ObjectID) error {
+	slog.WarnContext(ctx, "refresh token reuse detected, revoking sessions", "op", "Refresh", "user_id", userID.Hex())
+	if _, err := h.users.Update(ctx, userID, store.RevokeSessions()); err != nil && !errors.Is(err, store.ErrNotFound) {
+		slog.ErrorContext(ctx, "revoke sessions failed", "op", "Refresh", "user_id", userID.Hex(), "err", err)
+		return fmt.Errorf("revoke sessions: %w", err)
+	}
+	return huma.Error401Unauthorized("refresh token reuse detected, please log in again")
+}
+
+// Account implements auth.Accounts so the auth middleware can check that a
+// token's user still exists, is active and holds the presented session.
+func (h *UserHandler) Account(ctx context.Context, userID string) (auth.Account, error) {
+	objID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return auth.Account{}, auth.ErrUnknownAccount
+	}
+	user, err := h.users.GetWithCredentials(ctx, objID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return auth.Account{}, auth.ErrUnknownAccount
+		}
+		return auth.Account{}, fmt.Errorf("find user: %w", err)
+	}
+	return auth.Account{Role: user.Role, Disabled: user.IsDisabled(), AccessToken: user.Token}, nil
+}
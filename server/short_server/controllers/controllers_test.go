@@ -2,15 +2,53 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/auth"
 	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/search"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/store"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// newTestGenreStore returns a catalog holding the genres the tests use.
+func newTestGenreStore() *store.MemoryGenreStore {
+	return store.NewMemoryGenreStore(model.Genre{GenreID: 1, GenreName: "Drama"})
+}
+
+// testRankings is the ranking scale the tests use; testMovie(title, rank)
+// names its ranking from it.
+var testRankings = []model.Ranking{
+	{RankingValue: 1, RankingName: "Excellent"},
+	{RankingValue: 2, RankingName: "Good"},
+	{RankingValue: 3, RankingName: "Okay"},
+	{RankingValue: 4, RankingName: "Bad"},
+	{RankingValue: 5, RankingName: "Terrible"},
+}
+
+func newTestMovieHandler() *MovieHandler {
+	index, titles := search.NewIndex(), search.NewTitleIndex()
+	movies := search.Track(store.NewMemoryMovieStore(), index, titles)
+	return NewMovieHandler(movies, newTestGenreStore(), store.NewMemoryRankingStore(testRankings...), index, titles)
+}
+
+func newTestUserHandler(tokens *auth.TokenManager) *UserHandler {
+	return NewUserHandler(store.NewMemoryUserStore(), store.NewMemoryAuditStore(), newTestGenreStore(), tokens)
+}
+
 func TestContronller(t *testing.T) {
 	t.Run("test controller get all moives", func(t *testing.T) {
-		out, err := GetMovie(context.Background(), &GetMovieInput{ID: "bad-id"})
+		out, err := newTestMovieHandler().GetMovie(context.Background(), &GetMovieInput{ID: "bad-id"})
 		if err == nil {
 			t.Fatal("expected error for invalid id")
 		}
@@ -21,7 +59,7 @@ func TestContronller(t *testing.T) {
 	})
 
 	t.Run("test controller get one user with invalid id", func(t *testing.T) {
-		out, err := GetUser(context.Background(), &GetUserInput{ID: "bad-id"})
+		out, err := newTestUserHandler(nil).GetUser(context.Background(), &GetUserInput{ID: "bad-id"})
 		if err == nil {
 			t.Fatal("expected error for invalid id")
 		}
@@ -33,7 +71,7 @@ func TestContronller(t *testing.T) {
 
 func TestAddMovie(t *testing.T) {
 	t.Run("returns error for invalid payload", func(t *testing.T) {
-		out, err := AddMovie(context.Background(), &AddMovieInput{
+		out, err := newTestMovieHandler().AddMovie(context.Background(), &AddMovieInput{
 			Body: model.Movie{
 				Title: "",
 			},
@@ -49,7 +87,7 @@ func TestAddMovie(t *testing.T) {
 
 func TestAddUser(t *testing.T) {
 	t.Run("returns error for invalid payload", func(t *testing.T) {
-		out, err := AddUser(context.Background(), &AddUserInput{
+		out, err := newTestUserHandler(nil).AddUser(context.Background(), &AddUserInput{
 			Body: AddUserRequestBody{
 				Email: "invalid",
 			},
@@ -63,6 +101,53 @@ func TestAddUser(t *testing.T) {
 	})
 }
 
+func TestAddUserAdminRole(t *testing.T) {
+	body := AddUserRequestBody{
+		FirstName:       "Jane",
+		LastName:        "Doe",
+		Email:           "jane@example.com",
+		Password:        "secret123",
+		Role:            auth.RoleAdmin,
+		FavouriteGenres: []model.Genre{{GenreID: 1, GenreName: "Drama"}},
+	}
+	for name, ctx := range map[string]context.Context{
+		"anonymous": context.Background(),
+		"user":      auth.WithIdentity(context.Background(), auth.Identity{UserID: "u1", Role: auth.RoleUser}),
+	} {
+		t.Run(name+" cannot create admin", func(t *testing.T) {
+			_, err := newTestUserHandler(nil).AddUser(ctx, &AddUserInput{Body: body})
+			var se huma.StatusError
+			if !errors.As(err, &se) || se.GetStatus() != http.StatusForbidden {
+				t.Fatalf("expected 403, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAdminUserManagement(t *testing.T) {
+	t.Run("rejects invalid ids", func(t *testing.T) {
+		if _, err := newTestUserHandler(nil).ChangeUserRole(context.Background(), &ChangeRoleInput{ID: "bad-id"}); err == nil {
+			t.Fatal("expected error for invalid id on role change")
+		}
+		if _, err := newTestUserHandler(nil).ChangeUserStatus(context.Background(), &ChangeStatusInput{ID: "bad-id"}); err == nil {
+			t.Fatal("expected error for invalid id on status change")
+		}
+		if _, err := newTestUserHandler(nil).DeleteUser(context.Background(), &DeleteUserInput{ID: "bad-id"}); err == nil {
+			t.Fatal("expected error for invalid id on delete")
+		}
+	})
+
+	t.Run("rejects unknown role and status", func(t *testing.T) {
+		id := bson.NewObjectID().Hex()
+		if _, err := newTestUserHandler(nil).ChangeUserRole(context.Background(), &ChangeRoleInput{ID: id, Body: ChangeRoleRequestBody{Role: "ROOT"}}); err == nil {
+			t.Fatal("expected error for unknown role")
+		}
+		if _, err := newTestUserHandler(nil).ChangeUserStatus(context.Background(), &ChangeStatusInput{ID: id, Body: ChangeStatusRequestBody{Status: "BANNED"}}); err == nil {
+			t.Fatal("expected error for unknown status")
+		}
+	})
+}
+
 func TestHashPassword(t *testing.T) {
 	plain := "secret123"
 	hash, err := HashPassword(plain)
@@ -76,3 +161,288 @@ func TestHashPassword(t *testing.T) {
 		t.Fatalf("hash does not match plaintext: %v", err)
 	}
 }
+
+func TestLogin(t *testing.T) {
+	t.Run("returns error for invalid payload", func(t *testing.T) {
+		out, err := newTestUserHandler(nil).Login(context.Background(), &LoginInput{
+			Body: LoginRequestBody{
+				Email: "invalid",
+			},
+		})
+		if err == nil {
+			t.Fatal("expected error for invalid login payload")
+		}
+		if out != nil {
+			t.Fatal("expected nil output")
+		}
+	})
+}
+
+func TestRefresh(t *testing.T) {
+	tm, err := auth.NewTokenManager("test-secret", time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("new token manager: %v", err)
+	}
+	h := newTestUserHandler(tm)
+
+	t.Run("returns error for missing token", func(t *testing.T) {
+		out, err := h.Refresh(context.Background(), &RefreshInput{})
+		if err == nil {
+			t.Fatal("expected error for missing refresh token")
+		}
+		if out != nil {
+			t.Fatal("expected nil output")
+		}
+	})
+
+	t.Run("rejects access token presented as refresh token", func(t *testing.T) {
+		pair, err := tm.Issue(bson.NewObjectID().Hex(), "a@b.com", "USER")
+		if err != nil {
+			t.Fatalf("issue: %v", err)
+		}
+		_, err = h.Refresh(context.Background(), &RefreshInput{
+			Body: RefreshRequestBody{RefreshToken: pair.AccessToken},
+		})
+		var se huma.StatusError
+		if !errors.As(err, &se) || se.GetStatus() != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %v", err)
+		}
+	})
+}
+
+func TestAdminOperationsDeclareSecurity(t *testing.T) {
+	_, api := humatest.New(t)
+	users := newTestUserHandler(nil)
+	RegisterMovRoutes(api, newTestMovieHandler())
+	RegisterUserRoutes(api, users)
+	RegisterAdminRoutes(api, users)
+
+	paths := api.OpenAPI().Paths
+	ops := map[string]*huma.Operation{
+		"POST /addmovies":     paths["/addmovies"].Post,
+		"POST /movies":        paths["/movies"].Post,
+		"PUT /movies/{id}":    paths["/movies/{id}"].Put,
+		"PATCH /movies/{id}":  paths["/movies/{id}"].Patch,
+		"DELETE /movies/{id}": paths["/movies/{id}"].Delete,
+		"GET /users":          paths["/users"].Get,
+	}
+	for name, op := range ops {
+		if len(op.Security) == 0 {
+			t.Fatalf("%s: expected security requirement", name)
+		}
+		if op.Extensions[auth.RoleExtension] != auth.RoleAdmin {
+			t.Fatalf("%s: expected admin role, got %v", name, op.Extensions[auth.RoleExtension])
+		}
+	}
+}
+
+func TestUserResponseHasNoSecrets(t *testing.T) {
+	secretFields := []string{"password", "token", "refresh_token"}
+
+	t.Run("dto declares no secret fields", func(t *testing.T) {
+		rt := reflect.TypeOf(UserResponse{})
+		for i := 0; i < rt.NumField(); i++ {
+			name, _, _ := strings.Cut(rt.Field(i).Tag.Get("json"), ",")
+			if slices.Contains(secretFields, name) {
+				t.Fatalf("UserResponse exposes secret field %q", name)
+			}
+		}
+	})
+
+	user := model.User{
+		ID:           bson.NewObjectID(),
+		Email:        "a@b.com",
+		Password:     "$2a$10$hashvalue",
+		Token:        "access-token-value",
+		RefreshToken: "refresh-token-value",
+	}
+
+	for name, v := range map[string]any{
+		"dto":   newUserResponse(user),
+		"model": user,
+	} {
+		t.Run(name+" never serializes secrets", func(t *testing.T) {
+			raw, err := json.Marshal(v)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			var fields map[string]any
+			if err := json.Unmarshal(raw, &fields); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			for _, f := range secretFields {
+				if _, ok := fields[f]; ok {
+					t.Fatalf("serialized %q: %s", f, raw)
+				}
+			}
+			for _, secret := range []string{user.Password, user.Token, user.RefreshToken} {
+				if strings.Contains(string(raw), secret) {
+					t.Fatalf("serialized secret value %q: %s", secret, raw)
+				}
+			}
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// Examples from RFC 7396 Appendix A.
+	cases := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tc := range cases {
+		var target, patch, want any
+		_ = json.Unmarshal([]byte(tc.target), &target)
+		_ = json.Unmarshal([]byte(tc.patch), &patch)
+		_ = json.Unmarshal([]byte(tc.want), &want)
+		if got := mergePatch(target, patch); !reflect.DeepEqual(got, want) {
+			t.Errorf("mergePatch(%s, %s) = %v, want %s", tc.target, tc.patch, got, tc.want)
+		}
+	}
+}
+
+func TestApplyMoviePatch(t *testing.T) {
+	movie := model.Movie{
+		ID:          bson.NewObjectID(),
+		ImdbID:      "tt0111161",
+		Title:       "The Shawshank Redemption",
+		PosterPath:  "https://example.com/poster.jpg",
+		YouTubeID:   "6hB3S9bIaco",
+		Genre:       []model.Genre{{GenreID: 1, GenreName: "Drama"}},
+		AdminReview: "Grate",
+		Ranking:     model.Ranking{RankingValue: 1, RankingName: "Excellent"},
+	}
+
+	t.Run("merges fields and keeps the rest", func(t *testing.T) {
+		got, err := applyMoviePatch(movie, map[string]any{
+			"admin_review": "Great",
+			"ranking":      map[string]any{"ranking_name": "Good"},
+			"_id":          bson.NewObjectID().Hex(),
+		})
+		if err != nil {
+			t.Fatalf("apply patch: %v", err)
+		}
+		if got.AdminReview != "Great" || got.Ranking.RankingName != "Good" || got.Ranking.RankingValue != 1 {
+			t.Fatalf("unexpected patch result: %+v", got)
+		}
+		if got.ID != movie.ID || got.Title != movie.Title {
+			t.Fatalf("expected untouched fields to be kept: %+v", got)
+		}
+	})
+
+	t.Run("null removal fails validation", func(t *testing.T) {
+		got, err := applyMoviePatch(movie, map[string]any{"title": nil})
+		if err != nil {
+			t.Fatalf("apply patch: %v", err)
+		}
+		if err := validateBody(got); err == nil {
+			t.Fatal("expected validation error after removing title")
+		}
+	})
+
+	t.Run("wrong type is rejected", func(t *testing.T) {
+		if _, err := applyMoviePatch(movie, map[string]any{"title": 5}); err == nil {
+			t.Fatal("expected error for non-string title")
+		}
+	})
+}
+
+func TestMovieWritesRejectInvalidID(t *testing.T) {
+	if _, err := newTestMovieHandler().ReplaceMovie(context.Background(), &ReplaceMovieInput{ID: "bad-id"}); err == nil {
+		t.Fatal("expected error for invalid id on replace")
+	}
+	if _, err := newTestMovieHandler().PatchMovie(context.Background(), &PatchMovieInput{ID: "bad-id"}); err == nil {
+		t.Fatal("expected error for invalid id on patch")
+	}
+	if _, err := newTestMovieHandler().DeleteMovie(context.Background(), &DeleteMovieInput{ID: "bad-id"}); err == nil {
+		t.Fatal("expected error for invalid id on delete")
+	}
+}
+
+func TestProfile(t *testing.T) {
+	t.Run("update requires an authenticated caller", func(t *testing.T) {
+		name := "Jane"
+		_, err := newTestUserHandler(nil).UpdateProfile(context.Background(), &UpdateProfileInput{
+			Body: UpdateProfileRequestBody{FirstName: &name},
+		})
+		var se huma.StatusError
+		if !errors.As(err, &se) || se.GetStatus() != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %v", err)
+		}
+	})
+
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{UserID: bson.NewObjectID().Hex(), Role: auth.RoleUser})
+
+	t.Run("update rejects empty body", func(t *testing.T) {
+		_, err := newTestUserHandler(nil).UpdateProfile(ctx, &UpdateProfileInput{})
+		var se huma.StatusError
+		if !errors.As(err, &se) || se.GetStatus() != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %v", err)
+		}
+	})
+
+	t.Run("update validates present fields", func(t *testing.T) {
+		short := "J"
+		_, err := newTestUserHandler(nil).UpdateProfile(ctx, &UpdateProfileInput{
+			Body: UpdateProfileRequestBody{FirstName: &short},
+		})
+		var se huma.StatusError
+		if !errors.As(err, &se) || se.GetStatus() != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %v", err)
+		}
+	})
+
+	t.Run("change password validates new password", func(t *testing.T) {
+		_, err := newTestUserHandler(nil).ChangePassword(ctx, &ChangePasswordInput{
+			Body: ChangePasswordRequestBody{CurrentPassword: "secret123", NewPassword: "123"},
+		})
+		var se huma.StatusError
+		if !errors.As(err, &se) || se.GetStatus() != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %v", err)
+		}
+	})
+}
+
+func TestCanonicalGenresNamesMissingSeed(t *testing.T) {
+	ctx := context.Background()
+	genres := []model.Genre{{GenreID: 1, GenreName: "Drama"}}
+
+	_, err := canonicalGenres(ctx, store.NewMemoryGenreStore(), "AddUser", genres)
+	var se huma.StatusError
+	if !errors.As(err, &se) || se.GetStatus() != http.StatusServiceUnavailable || !strings.Contains(err.Error(), "migrate up") {
+		t.Fatalf("expected a 503 naming the seed migration, got %v", err)
+	}
+
+	_, err = canonicalGenres(ctx, newTestGenreStore(), "AddUser", []model.Genre{{GenreID: 9, GenreName: "Horror"}})
+	if !errors.As(err, &se) || se.GetStatus() != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown genre in a seeded catalog, got %v", err)
+	}
+}
+
+func TestCanonicalRankingNamesMissingSeed(t *testing.T) {
+	ctx := context.Background()
+	r := model.Ranking{RankingValue: 1, RankingName: "Excellent"}
+
+	_, err := canonicalRanking(ctx, store.NewMemoryRankingStore(), "AddMovie", r)
+	var se huma.StatusError
+	if !errors.As(err, &se) || se.GetStatus() != http.StatusServiceUnavailable || !strings.Contains(err.Error(), "migrate up") {
+		t.Fatalf("expected a 503 naming the seed migration, got %v", err)
+	}
+
+	r.RankingValue = 9
+	_, err = canonicalRanking(ctx, store.NewMemoryRankingStore(testRankings...), "AddMovie", r)
+	if !errors.As(err, &se) || se.GetStatus() != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a value off a seeded scale, got %v", err)
+	}
+}
@@ -0,0 +1,254 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/auth"
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/store"
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type (
+	GetGenresOutput struct {
+		Body []model.Genre `json:"body"`
+	}
+
+	GetGenreInput struct {
+		ID int `path:"id"`
+	}
+
+	GetGenreOutput struct {
+		Body model.Genre `json:"body"`
+	}
+
+	AddGenreInput struct {
+		Body model.Genre
+	}
+
+	RenameGenreInput struct {
+		ID   int `path:"id"`
+		Body struct {
+			GenreName string `json:"genre_name" validate:"required,min=2,max=100"`
+		}
+	}
+
+	RenameGenreOutput struct {
+		Body RenameGenreBody `json:"body"`
+	}
+
+	// RenameGenreBody reports the renamed genre and how many embedded copies
+	// were brought in line with it.
+	RenameGenreBody struct {
+		model.Genre
+		MoviesUpdated int64 `json:"movies_updated"`
+		UsersUpdated  int64 `json:"users_updated"`
+	}
+
+	DeleteGenreInput struct {
+		ID int `path:"id"`
+	}
+)
+
+// GenreHandler serves the genre catalog. Movies and users embed copies of
+// catalog genres, so it also needs their stores to propagate renames and to
+// refuse deleting a genre that is still referenced.
+type GenreHandler struct {
+	genres store.GenreStore
+	movies store.MovieStore
+	users  store.UserStore
+}
+
+func NewGenreHandler(genres store.GenreStore, movies store.MovieStore, users store.UserStore) *GenreHandler {
+	return &GenreHandler{genres: genres, movies: movies, users: users}
+}
+
+func RegisterGenreRoutes(api huma.API, h *GenreHandler) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-genres",
+		Method:      "GET",
+		Path:        "/genres",
+		Summary:     "List genres",
+		Errors:      []int{500},
+	}, h.GetGenres)
+	huma.Register(api, huma.Operation{
+		OperationID: "get-genre",
+		Method:      "GET",
+		Path:        "/genres/{id}",
+		Summary:     "Get one genre by ID",
+		Errors:      []int{404, 500},
+	}, h.GetGenre)
+	huma.Register(api, huma.Operation{
+		OperationID:   "add-genre",
+		Method:        "POST",
+		Path:          "/genres",
+		Summary:       "Add one genre",
+		DefaultStatus: http.StatusCreated,
+		Errors:        []int{400, 401, 403, 409, 500},
+		Security:      auth.BearerSecurity,
+		Extensions:    auth.RequireRole(auth.RoleAdmin),
+	}, h.AddGenre)
+	huma.Register(api, huma.Operation{
+		OperationID: "rename-genre",
+		Method:      "PUT",
+		Path:        "/genres/{id}",
+		Summary:     "Rename one genre",
+		Description: "Renames the genre and every copy of it embedded in movies and users. Repeating a rename that failed part way finishes the propagation.",
+		Errors:      []int{400, 401, 403, 404, 409, 500},
+		Security:    auth.BearerSecurity,
+		Extensions:  auth.RequireRole(auth.RoleAdmin),
+	}, h.RenameGenre)
+	huma.Register(api, huma.Operation{
+		OperationID:   "delete-genre",
+		Method:        "DELETE",
+		Path:          "/genres/{id}",
+		Summary:       "Delete one genre",
+		Description:   "Fails with 409 while any movie or user still references the genre.",
+		DefaultStatus: http.StatusNoContent,
+		Errors:        []int{401, 403, 404, 409, 500},
+		Security:      auth.BearerSecurity,
+		Extensions:    auth.RequireRole(auth.RoleAdmin),
+	}, h.DeleteGenre)
+}
+
+func (h *GenreHandler) GetGenres(ctx context.Context, in *struct{}) (*GetGenresOutput, error) {
+	genres, err := h.genres.List(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "list genres failed", "op", "GetGenres", "err", err)
+		return nil, fmt.Errorf("list genres: %w", err)
+	}
+	return &GetGenresOutput{Body: genres}, nil
+}
+
+func (h *GenreHandler) GetGenre(ctx context.Context, in *GetGenreInput) (*GetGenreOutput, error) {
+	genre, err := h.genres.Get(ctx, in.ID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, huma.Error404NotFound("genre not found")
+		}
+		slog.ErrorContext(ctx, "find genre failed", "op", "GetGenre", "genre_id", in.ID, "err", err)
+		return nil, fmt.Errorf("find genre: %w", err)
+	}
+	return &GetGenreOutput{Body: genre}, nil
+}
+
+func (h *GenreHandler) AddGenre(ctx context.Context, in *AddGenreInput) (*GetGenreOutput, error) {
+	if err := validateBody(in.Body); err != nil {
+		return nil, err
+	}
+
+	if err := h.genres.Insert(ctx, in.Body); err != nil {
+		if errors.Is(err, store.ErrDuplicateKey) {
+			return nil, huma.Error409Conflict("a genre with this ID or name already exists")
+		}
+		slog.ErrorContext(ctx, "insert genre failed", "op", "AddGenre", "genre_id", in.Body.GenreID, "err", err)
+		return nil, fmt.Errorf("insert genre: %w", err)
+	}
+	return &GetGenreOutput{Body: in.Body}, nil
+}
+
+// RenameGenre renames the catalog entry first and then the embedded copies.
+// If propagation fails the catalog already has the new name, and a retry of
+// the same request updates whatever copies were missed.
+func (h *GenreHandler) RenameGenre(ctx context.Context, in *RenameGenreInput) (*RenameGenreOutput, error) {
+	if err := validateBody(in.Body); err != nil {
+		return nil, err
+	}
+
+	genre := model.Genre{GenreID: in.ID, GenreName: in.Body.GenreName}
+	if err := h.genres.Update(ctx, genre); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, huma.Error404NotFound("genre not found")
+		}
+		if errors.Is(err, store.ErrDuplicateKey) {
+			return nil, huma.Error409Conflict("a genre with this name already exists")
+		}
+		slog.ErrorContext(ctx, "update genre failed", "op", "RenameGenre", "genre_id", in.ID, "err", err)
+		return nil, fmt.Errorf("update genre: %w", err)
+	}
+
+	out := RenameGenreBody{Genre: genre}
+	var err error
+	if out.MoviesUpdated, err = h.movies.RenameGenre(ctx, genre.GenreID, genre.GenreName); err != nil {
+		slog.ErrorContext(ctx, "rename genre in movies failed", "op", "RenameGenre", "genre_id", in.ID, "err", err)
+		return nil, fmt.Errorf("rename genre in movies: %w", err)
+	}
+	if out.UsersUpdated, err = h.users.RenameGenre(ctx, genre.GenreID, genre.GenreName); err != nil {
+		slog.ErrorContext(ctx, "rename genre in users failed", "op", "RenameGenre", "genre_id", in.ID, "err", err)
+		return nil, fmt.Errorf("rename genre in users: %w", err)
+	}
+	return &RenameGenreOutput{Body: out}, nil
+}
+
+// DeleteGenre refuses to orphan embedded copies. The check and the delete are
+// not atomic, so a movie written in between can still reference a deleted
+// genre; later writes of that movie are then rejected until it is fixed.
+func (h *GenreHandler) DeleteGenre(ctx context.Context, in *DeleteGenreInput) (*struct{}, error) {
+	movies, err := h.movies.CountWithGenre(ctx, in.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "count movies with genre failed", "op", "DeleteGenre", "genre_id", in.ID, "err", err)
+		return nil, fmt.Errorf("count movies with genre: %w", err)
+	}
+	users, err := h.users.CountWithGenre(ctx, in.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "count users with genre failed", "op", "DeleteGenre", "genre_id", in.ID, "err", err)
+		return nil, fmt.Errorf("count users with genre: %w", err)
+	}
+	if movies > 0 || users > 0 {
+		return nil, huma.Error409Conflict(fmt.Sprintf("genre is referenced by %d movies and %d users", movies, users))
+	}
+
+	if err := h.genres.Delete(ctx, in.ID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, huma.Error404NotFound("genre not found")
+		}
+		slog.ErrorContext(ctx, "delete genre failed", "op", "DeleteGenre", "genre_id", in.ID, "err", err)
+		return nil, fmt.Errorf("delete genre: %w", err)
+	}
+	return nil, nil
+}
+
+// canonicalGenres checks every genre against the catalog and returns them
+// with their catalog names, so embedded copies never drift from it. Unknown
+// IDs are a 400 listing each one; repeated IDs are collapsed. An empty
+// catalog means the seed migration has not run, which is a 503 saying so
+// rather than blaming the request.
+func canonicalGenres(ctx context.Context, catalog store.GenreStore, op string, genres []model.Genre) ([]model.Genre, error) {
+	out := make([]model.Genre, 0, len(genres))
+	seen := make(map[int]bool, len(genres))
+	var unknown []error
+	for _, g := range genres {
+		if seen[g.GenreID] {
+			continue
+		}
+		seen[g.GenreID] = true
+
+		canonical, err := catalog.Get(ctx, g.GenreID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				unknown = append(unknown, fmt.Errorf("unknown genre_id %d", g.GenreID))
+				continue
+			}
+			slog.ErrorContext(ctx, "find genre failed", "op", op, "genre_id", g.GenreID, "err", err)
+			return nil, fmt.Errorf("find genre: %w", err)
+		}
+		out = append(out, canonical)
+	}
+	if len(unknown) > 0 {
+		catalogued, err := catalog.List(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "list genres failed", "op", op, "err", err)
+			return nil, fmt.Errorf("list genres: %w", err)
+		}
+		if len(catalogued) == 0 {
+			slog.ErrorContext(ctx, "genre catalog is empty, run migrate up", "op", op)
+			return nil, huma.Error503ServiceUnavailable("the genre catalog has not been seeded; run `migrate up` (migration 2, seed_genres)")
+		}
+		return nil, huma.Error400BadRequest("unknown genre", unknown...)
+	}
+	return out, nil
+}
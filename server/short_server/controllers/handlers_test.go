@@ -0,0 +1,681 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/auth"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/config"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/idempotency"
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/search"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/store"
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// testServer wires every route onto a humatest API backed by memory stores.
+type testServer struct {
+	api    humatest.TestAPI
+	users  *store.MemoryUserStore
+	movies *store.MemoryMovieStore
+	audit  *store.MemoryAuditStore
+	tokens *auth.TokenManager
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	tm, err := auth.NewTokenManager("test-secret", time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("token manager: %v", err)
+	}
+	_, api := humatest.New(t)
+
+	s := &testServer{
+		api:    api,
+		users:  store.NewMemoryUserStore(),
+		movies: store.NewMemoryMovieStore(),
+		audit:  store.NewMemoryAuditStore(),
+		tokens: tm,
+	}
+	genres := newTestGenreStore()
+	users := NewUserHandler(s.users, s.audit, genres, tm)
+	api.UseMiddleware(auth.Middleware(api, tm, users), idempotency.New(config.Default().Idempotency, idempotency.NewMemoryStore()).Middleware(api))
+	rankings := store.NewMemoryRankingStore(testRankings...)
+	index, titles := search.NewIndex(), search.NewTitleIndex()
+	movies := search.Track(s.movies, index, titles)
+	RegisterMovRoutes(api, NewMovieHandler(movies, genres, rankings, index, titles))
+	RegisterGenreRoutes(api, NewGenreHandler(genres, movies, s.users))
+	RegisterRankingRoutes(api, NewRankingHandler(rankings))
+	RegisterUserRoutes(api, users)
+	RegisterProfileRoutes(api, users)
+	RegisterAdminRoutes(api, users)
+	RegisterAuthRoutes(api, users)
+	return s
+}
+
+// seedUser stores a user with the given role and password directly.
+func (s *testServer) seedUser(t *testing.T, email, role, password string) bson.ObjectID {
+	t.Helper()
+	hashed, err := HashPassword(password)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	u := model.User{
+		FirstName: "Test", LastName: "User", Email: email, Password: hashed, Role: role,
+		FavouriteGenres: []model.Genre{},
+	}
+	assignUserIdentityAndTimestamps(&u)
+	if err := s.users.Insert(context.Background(), u); err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	return u.ID
+}
+
+func (s *testServer) login(t *testing.T, email, password string) TokenResponseBody {
+	t.Helper()
+	resp := s.api.Post("/login", map[string]any{"email": email, "password": password})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("login: %d %s", resp.Code, resp.Body.String())
+	}
+	var body TokenResponseBody
+	decode(t, resp, &body)
+	return body
+}
+
+func bearer(token string) string {
+	return "Authorization: Bearer " + token
+}
+
+func decode(t *testing.T, resp *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.Unmarshal(resp.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %q: %v", resp.Body.String(), err)
+	}
+}
+
+func testMovie(title string, rank int) map[string]any {
+	return map[string]any{
+		"imdb_id":      "tt" + strings.ReplaceAll(title, " ", ""),
+		"title":        title,
+		"poster_path":  "https://example.com/poster.jpg",
+		"youtube_id":   "yt123",
+		"genre":        []map[string]any{{"genre_id": 1, "genre_name": "Drama"}},
+		"admin_review": "Solid",
+		"ranking":      map[string]any{"ranking_value": rank, "ranking_name": testRankings[rank-1].RankingName},
+	}
+}
+
+func TestMovieCRUD(t *testing.T) {
+	s := newTestServer(t)
+	s.seedUser(t, "admin@example.com", auth.RoleAdmin, "secret1")
+	admin := bearer(s.login(t, "admin@example.com", "secret1").AccessToken)
+
+	var ids []string
+	for i, title := range []string{"Alien", "Brazil", "Casablanca"} {
+		resp := s.api.Post("/movies", admin, testMovie(title, i+1))
+		if resp.Code != http.StatusCreated {
+			t.Fatalf("create %s: %d %s", title, resp.Code, resp.Body.String())
+		}
+		var m model.Movie
+		decode(t, resp, &m)
+		ids = append(ids, m.ID.Hex())
+	}
+
+	t.Run("anonymous cannot create", func(t *testing.T) {
+		if resp := s.api.Post("/movies", testMovie("Dune", 1)); resp.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d", resp.Code)
+		}
+	})
+
+	t.Run("duplicate imdb_id is a conflict naming the existing movie", func(t *testing.T) {
+		resp := s.api.Post("/addmovies", admin, testMovie("Alien", 5))
+		if resp.Code != http.StatusConflict {
+			t.Fatalf("expected 409, got %d %s", resp.Code, resp.Body.String())
+		}
+		var body MovieConflictError
+		decode(t, resp, &body)
+		if body.ExistingID != ids[0] {
+			t.Fatalf("expected existing_id %s, got %+v", ids[0], body)
+		}
+
+		replace := testMovie("Brazil", 2)
+		replace["imdb_id"] = "ttAlien"
+		if resp := s.api.Put("/movies/"+ids[1], admin, replace); resp.Code != http.StatusConflict {
+			t.Fatalf("expected 409 on replace, got %d %s", resp.Code, resp.Body.String())
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		resp := s.api.Get("/movies/" + ids[1])
+		if resp.Code != http.StatusOK {
+			t.Fatalf("get: %d %s", resp.Code, resp.Body.String())
+		}
+		var m model.Movie
+		decode(t, resp, &m)
+		if m.Title != "Brazil" {
+			t.Fatalf("unexpected movie %+v", m)
+		}
+	})
+
+	t.Run("list pages through every movie", func(t *testing.T) {
+		var titles []string
+		path := "/movies?limit=2&sort=title"
+		for path != "" {
+			resp := s.api.Get(path)
+			if resp.Code != http.StatusOK {
+				t.Fatalf("list: %d %s", resp.Code, resp.Body.String())
+			}
+			var body ListMoviesBody
+			decode(t, resp, &body)
+			for _, m := range body.Items {
+				titles = append(titles, m.Title)
+			}
+			path = ""
+			if body.Next != "" {
+				path = "/movies?limit=2&sort=title&after=" + body.Next
+			}
+		}
+		if strings.Join(titles, ",") != "Alien,Brazil,Casablanca" {
+			t.Fatalf("unexpected titles %v", titles)
+		}
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		resp := s.api.Put("/movies/"+ids[0], admin, testMovie("Aliens", 5))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("replace: %d %s", resp.Code, resp.Body.String())
+		}
+		var m model.Movie
+		decode(t, resp, &m)
+		if m.Title != "Aliens" || m.Ranking.RankingValue != 5 {
+			t.Fatalf("unexpected movie %+v", m)
+		}
+	})
+
+	t.Run("patch", func(t *testing.T) {
+		resp := s.api.Patch("/movies/"+ids[2], admin, "Content-Type: application/merge-patch+json",
+			strings.NewReader(`{"admin_review":"Classic"}`))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("patch: %d %s", resp.Code, resp.Body.String())
+		}
+		var m model.Movie
+		decode(t, resp, &m)
+		if m.AdminReview != "Classic" || m.Title != "Casablanca" {
+			t.Fatalf("unexpected movie %+v", m)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if resp := s.api.Delete("/movies/"+ids[1], admin); resp.Code != http.StatusNoContent {
+			t.Fatalf("delete: %d %s", resp.Code, resp.Body.String())
+		}
+		if resp := s.api.Get("/movies/" + ids[1]); resp.Code != http.StatusNotFound {
+			t.Fatalf("expected 404 after delete, got %d", resp.Code)
+		}
+		if resp := s.api.Delete("/movies/"+ids[1], admin); resp.Code != http.StatusNotFound {
+			t.Fatalf("expected 404 on second delete, got %d", resp.Code)
+		}
+	})
+}
+
+func TestAddMovieIdempotencyKey(t *testing.T) {
+	s := newTestServer(t)
+	s.seedUser(t, "admin@example.com", auth.RoleAdmin, "secret1")
+	admin := bearer(s.login(t, "admin@example.com", "secret1").AccessToken)
+	key := idempotency.HeaderName + ": 3f1c2a"
+
+	var first, retry model.Movie
+	resp := s.api.Post("/addmovies", admin, key, testMovie("Alien", 1))
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", resp.Code, resp.Body.String())
+	}
+	decode(t, resp, &first)
+
+	resp = s.api.Post("/addmovies", admin, key, testMovie("Alien", 1))
+	if resp.Code != http.StatusCreated || resp.Header().Get(idempotency.ReplayedHeader) != "true" {
+		t.Fatalf("retry: %d %v", resp.Code, resp.Header())
+	}
+	decode(t, resp, &retry)
+	if retry.ID != first.ID {
+		t.Fatalf("retry created a second movie: %s and %s", first.ID.Hex(), retry.ID.Hex())
+	}
+
+	if resp := s.api.Post("/addmovies", admin, key, testMovie("Brazil", 1)); resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a reused key, got %d %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestGenreCatalog(t *testing.T) {
+	s := newTestServer(t)
+	s.seedUser(t, "admin@example.com", auth.RoleAdmin, "secret1")
+	admin := bearer(s.login(t, "admin@example.com", "secret1").AccessToken)
+
+	if resp := s.api.Post("/genres", admin, map[string]any{"genre_id": 2, "genre_name": "Comedy"}); resp.Code != http.StatusCreated {
+		t.Fatalf("create genre: %d %s", resp.Code, resp.Body.String())
+	}
+
+	t.Run("names are unique ignoring case", func(t *testing.T) {
+		resp := s.api.Post("/genres", admin, map[string]any{"genre_id": 3, "genre_name": "comedy"})
+		if resp.Code != http.StatusConflict {
+			t.Fatalf("expected 409, got %d %s", resp.Code, resp.Body.String())
+		}
+	})
+
+	t.Run("anonymous cannot create", func(t *testing.T) {
+		if resp := s.api.Post("/genres", map[string]any{"genre_id": 3, "genre_name": "Horror"}); resp.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d", resp.Code)
+		}
+	})
+
+	movie := testMovie("Airplane", 4)
+	movie["genre"] = []map[string]any{{"genre_id": 2, "genre_name": "COMEDY!!"}}
+	resp := s.api.Post("/movies", admin, movie)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("create movie: %d %s", resp.Code, resp.Body.String())
+	}
+	var m model.Movie
+	decode(t, resp, &m)
+
+	t.Run("movie genres take the catalog name", func(t *testing.T) {
+		if len(m.Genre) != 1 || m.Genre[0].GenreName != "Comedy" {
+			t.Fatalf("expected canonical genre, got %+v", m.Genre)
+		}
+	})
+
+	t.Run("unknown genre IDs are rejected", func(t *testing.T) {
+		bad := testMovie("Solaris", 3)
+		bad["genre"] = []map[string]any{{"genre_id": 99, "genre_name": "Space"}}
+		if resp := s.api.Post("/movies", admin, bad); resp.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d %s", resp.Code, resp.Body.String())
+		}
+		user := map[string]any{
+			"first_name": "New", "last_name": "User", "email": "new@example.com",
+			"password": "secret1", "role": auth.RoleUser,
+			"favourite_genres": []map[string]any{{"genre_id": 99, "genre_name": "Space"}},
+		}
+		if resp := s.api.Post("/users", user); resp.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d %s", resp.Code, resp.Body.String())
+		}
+	})
+
+	t.Run("rename propagates to movies", func(t *testing.T) {
+		resp := s.api.Put("/genres/2", admin, map[string]any{"genre_name": "Comedies"})
+		if resp.Code != http.StatusOK {
+			t.Fatalf("rename: %d %s", resp.Code, resp.Body.String())
+		}
+		var body RenameGenreBody
+		decode(t, resp, &body)
+		if body.GenreName != "Comedies" || body.MoviesUpdated != 1 {
+			t.Fatalf("unexpected rename result %+v", body)
+		}
+
+		var got model.Movie
+		decode(t, s.api.Get("/movies/"+m.ID.Hex()), &got)
+		if got.Genre[0].GenreName != "Comedies" {
+			t.Fatalf("expected renamed genre, got %+v", got.Genre)
+		}
+	})
+
+	t.Run("referenced genres cannot be deleted", func(t *testing.T) {
+		if resp := s.api.Delete("/genres/2", admin); resp.Code != http.StatusConflict {
+			t.Fatalf("expected 409, got %d %s", resp.Code, resp.Body.String())
+		}
+		if resp := s.api.Delete("/movies/"+m.ID.Hex(), admin); resp.Code != http.StatusNoContent {
+			t.Fatalf("delete movie: %d %s", resp.Code, resp.Body.String())
+		}
+		if resp := s.api.Delete("/genres/2", admin); resp.Code != http.StatusNoContent {
+			t.Fatalf("delete genre: %d %s", resp.Code, resp.Body.String())
+		}
+		if resp := s.api.Get("/genres/2"); resp.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", resp.Code)
+		}
+	})
+}
+
+func TestRankingScale(t *testing.T) {
+	s := newTestServer(t)
+	s.seedUser(t, "admin@example.com", auth.RoleAdmin, "secret1")
+	admin := bearer(s.login(t, "admin@example.com", "secret1").AccessToken)
+
+	t.Run("list", func(t *testing.T) {
+		resp := s.api.Get("/rankings")
+		if resp.Code != http.StatusOK {
+			t.Fatalf("list: %d %s", resp.Code, resp.Body.String())
+		}
+		var got []model.Ranking
+		decode(t, resp, &got)
+		if len(got) != len(testRankings) || got[0] != testRankings[0] {
+			t.Fatalf("unexpected scale %+v", got)
+		}
+	})
+
+	for name, ranking := range map[string]map[string]any{
+		"unknown value":    {"ranking_value": 999, "ranking_name": "Bad"},
+		"mismatched name":  {"ranking_value": 1, "ranking_name": "Bad"},
+		"name not in list": {"ranking_value": 2, "ranking_name": "Meh"},
+	} {
+		t.Run(name+" is rejected", func(t *testing.T) {
+			movie := testMovie("Dune", 1)
+			movie["ranking"] = ranking
+			if resp := s.api.Post("/movies", admin, movie); resp.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d %s", resp.Code, resp.Body.String())
+			}
+		})
+	}
+
+	t.Run("name takes the scale's case", func(t *testing.T) {
+		movie := testMovie("Dune", 1)
+		movie["ranking"] = map[string]any{"ranking_value": 2, "ranking_name": "GOOD"}
+		resp := s.api.Post("/movies", admin, movie)
+		if resp.Code != http.StatusCreated {
+			t.Fatalf("create: %d %s", resp.Code, resp.Body.String())
+		}
+		var m model.Movie
+		decode(t, resp, &m)
+		if m.Ranking.RankingName != "Good" {
+			t.Fatalf("expected canonical ranking, got %+v", m.Ranking)
+		}
+
+		patch := s.api.Patch("/movies/"+m.ID.Hex(), admin, "Content-Type: application/merge-patch+json",
+			strings.NewReader(`{"ranking":{"ranking_value":4}}`))
+		if patch.Code != http.StatusBadRequest {
+			t.Fatalf("expected a patch leaving value and name apart to fail, got %d %s", patch.Code, patch.Body.String())
+		}
+	})
+}
+
+func TestMovieSearch(t *testing.T) {
+	s := newTestServer(t)
+	s.seedUser(t, "admin@example.com", auth.RoleAdmin, "secret1")
+	admin := bearer(s.login(t, "admin@example.com", "secret1").AccessToken)
+
+	for i, title := range []string{"Space Odyssey", "Alien", "Spaceballs", "Heat"} {
+		movie := testMovie(title, i+1)
+		movie["admin_review"] = "A film set in space"
+		if title == "Heat" {
+			movie["admin_review"] = "Heist"
+		}
+		if resp := s.api.Post("/movies", admin, movie); resp.Code != http.StatusCreated {
+			t.Fatalf("create %s: %d %s", title, resp.Code, resp.Body.String())
+		}
+	}
+
+	var titles []string
+	path := "/movies/search?q=space&limit=2"
+	for path != "" {
+		resp := s.api.Get(path)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("search: %d %s", resp.Code, resp.Body.String())
+		}
+		var body SearchMoviesBody
+		decode(t, resp, &body)
+		for _, hit := range body.Items {
+			titles = append(titles, hit.Title)
+		}
+		path = ""
+		if body.Next != "" {
+			if !strings.Contains(resp.Header().Get("Link"), "q=space") {
+				t.Fatalf("expected Link to keep the query, got %q", resp.Header().Get("Link"))
+			}
+			path = "/movies/search?q=space&limit=2&after=" + body.Next
+		}
+	}
+	if len(titles) != 3 || titles[0] != "Space Odyssey" {
+		t.Fatalf("expected the title match first and no Heat, got %v", titles)
+	}
+
+	t.Run("a full last page has no cursor", func(t *testing.T) {
+		resp := s.api.Get("/movies/search?q=space&limit=3")
+		var body SearchMoviesBody
+		decode(t, resp, &body)
+		if len(body.Items) != 3 || body.Next != "" || resp.Header().Get("Link") != "" {
+			t.Fatalf("expected three hits and no next page, got %d items, next %q", len(body.Items), body.Next)
+		}
+	})
+
+	t.Run("cursor is tied to its query", func(t *testing.T) {
+		resp := s.api.Get("/movies/search?q=space&limit=1")
+		var body SearchMoviesBody
+		decode(t, resp, &body)
+		if resp := s.api.Get("/movies/search?q=heist&after=" + body.Next); resp.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", resp.Code)
+		}
+	})
+
+	t.Run("cursor is tied to its genre", func(t *testing.T) {
+		resp := s.api.Get("/movies/search?q=space&genre=1&limit=1")
+		var body SearchMoviesBody
+		decode(t, resp, &body)
+		if body.Next == "" {
+			t.Fatal("expected a next page")
+		}
+		if resp := s.api.Get("/movies/search?q=space&after=" + body.Next); resp.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 without the genre, got %d", resp.Code)
+		}
+		if resp := s.api.Get("/movies/search?q=space&genre=1&after=" + body.Next); resp.Code != http.StatusOK {
+			t.Fatalf("expected the same genre to page on, got %d %s", resp.Code, resp.Body.String())
+		}
+	})
+
+	t.Run("q is required", func(t *testing.T) {
+		if resp := s.api.Get("/movies/search"); resp.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected 422, got %d %s", resp.Code, resp.Body.String())
+		}
+	})
+}
+
+func TestMovieSuggest(t *testing.T) {
+	s := newTestServer(t)
+	s.seedUser(t, "admin@example.com", auth.RoleAdmin, "secret1")
+	admin := bearer(s.login(t, "admin@example.com", "secret1").AccessToken)
+
+	var stardust model.Movie
+	for _, title := range []string{"Star Wars", "Stardust", "Lone Star", "Alien"} {
+		resp := s.api.Post("/movies", admin, testMovie(title, 1))
+		if resp.Code != http.StatusCreated {
+			t.Fatalf("create %s: %d %s", title, resp.Code, resp.Body.String())
+		}
+		if title == "Stardust" {
+			decode(t, resp, &stardust)
+		}
+	}
+
+	suggest := func(t *testing.T, path string) []search.Suggestion {
+		t.Helper()
+		resp := s.api.Get(path)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("suggest: %d %s", resp.Code, resp.Body.String())
+		}
+		var got []search.Suggestion
+		decode(t, resp, &got)
+		return got
+	}
+
+	got := suggest(t, "/movies/suggest?prefix=STAR&limit=2")
+	if len(got) != 2 || got[0].Title != "Star Wars" || got[1].ID != stardust.ID || got[1].PosterPath == "" {
+		t.Fatalf("unexpected suggestions %+v", got)
+	}
+
+	t.Run("writes refresh the index", func(t *testing.T) {
+		movie := testMovie("Stardust", 1)
+		movie["title"] = "Stardust Memories"
+		if resp := s.api.Put("/movies/"+stardust.ID.Hex(), admin, movie); resp.Code != http.StatusOK {
+			t.Fatalf("replace: %d %s", resp.Code, resp.Body.String())
+		}
+		if got := suggest(t, "/movies/suggest?prefix=memories"); len(got) != 1 || got[0].Title != "Stardust Memories" {
+			t.Fatalf("expected the new title, got %+v", got)
+		}
+	})
+}
+
+func TestRevokedAdminCannotCreateAdmins(t *testing.T) {
+	s := newTestServer(t)
+	s.seedUser(t, "root@example.com", auth.RoleAdmin, "secret1")
+	root := bearer(s.login(t, "root@example.com", "secret1").AccessToken)
+	demotedID := s.seedUser(t, "demoted@example.com", auth.RoleAdmin, "secret1")
+	demoted := bearer(s.login(t, "demoted@example.com", "secret1").AccessToken)
+	disabledID := s.seedUser(t, "disabled@example.com", auth.RoleAdmin, "secret1")
+	disabled := bearer(s.login(t, "disabled@example.com", "secret1").AccessToken)
+
+	if resp := s.api.Put("/users/"+demotedID.Hex()+"/role", root, map[string]any{"role": auth.RoleUser}); resp.Code != http.StatusOK {
+		t.Fatalf("demote: %d %s", resp.Code, resp.Body.String())
+	}
+	if resp := s.api.Put("/users/"+disabledID.Hex()+"/status", root, map[string]any{"status": model.UserStatusDisabled}); resp.Code != http.StatusOK {
+		t.Fatalf("disable: %d %s", resp.Code, resp.Body.String())
+	}
+
+	for name, token := range map[string]string{"demoted": demoted, "disabled": disabled} {
+		resp := s.api.Post("/users", token, map[string]any{
+			"first_name": "Eve", "last_name": "Admin", "email": name + "-eve@example.com",
+			"password": "secret1", "role": auth.RoleAdmin, "favourite_genres": []any{},
+		})
+		if resp.Code != http.StatusForbidden {
+			t.Fatalf("%s admin token: expected 403 creating an admin, got %d %s", name, resp.Code, resp.Body.String())
+		}
+	}
+}
+
+func TestUserLifecycle(t *testing.T) {
+	s := newTestServer(t)
+	adminID := s.seedUser(t, "admin@example.com", auth.RoleAdmin, "secret1")
+	admin := bearer(s.login(t, "admin@example.com", "secret1").AccessToken)
+
+	resp := s.api.Post("/users", map[string]any{
+		"first_name": "Jane", "last_name": "Doe", "email": "Jane@Example.com",
+		"password": "secret1", "role": auth.RoleUser, "favourite_genres": []any{},
+	})
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("signup: %d %s", resp.Code, resp.Body.String())
+	}
+	var created UserResponse
+	decode(t, resp, &created)
+	userID := created.ID.Hex()
+
+	t.Run("duplicate email is a conflict", func(t *testing.T) {
+		resp := s.api.Post("/users", map[string]any{
+			"first_name": "Jane", "last_name": "Doe", "email": "jane@example.com",
+			"password": "secret1", "role": auth.RoleUser, "favourite_genres": []any{},
+		})
+		if resp.Code != http.StatusConflict {
+			t.Fatalf("expected 409, got %d %s", resp.Code, resp.Body.String())
+		}
+	})
+
+	tokens := s.login(t, "jane@example.com", "secret1")
+
+	t.Run("refresh rotates and detects reuse", func(t *testing.T) {
+		resp := s.api.Post("/refresh", map[string]any{"refresh_token": tokens.RefreshToken})
+		if resp.Code != http.StatusOK {
+			t.Fatalf("refresh: %d %s", resp.Code, resp.Body.String())
+		}
+		var rotated TokenResponseBody
+		decode(t, resp, &rotated)
+		if resp := s.api.Patch("/users/me", bearer(rotated.AccessToken), map[string]any{"first_name": "Jane"}); resp.Code != http.StatusOK {
+			t.Fatalf("expected the rotated access token to work, got %d", resp.Code)
+		}
+		resp = s.api.Post("/refresh", map[string]any{"refresh_token": tokens.RefreshToken})
+		if resp.Code != http.StatusUnauthorized {
+			t.Fatalf("expected reuse to be rejected, got %d", resp.Code)
+		}
+		if resp := s.api.Patch("/users/me", bearer(rotated.AccessToken), map[string]any{"first_name": "Jane"}); resp.Code != http.StatusUnauthorized {
+			t.Fatalf("expected reuse to revoke outstanding access tokens, got %d", resp.Code)
+		}
+	})
+
+	user := bearer(s.login(t, "jane@example.com", "secret1").AccessToken)
+
+	t.Run("profile update", func(t *testing.T) {
+		resp := s.api.Patch("/users/me", user, map[string]any{"first_name": "Janet"})
+		if resp.Code != http.StatusOK {
+			t.Fatalf("update profile: %d %s", resp.Code, resp.Body.String())
+		}
+		var got UserResponse
+		decode(t, resp, &got)
+		if got.FirstName != "Janet" || got.LastName != "Doe" {
+			t.Fatalf("unexpected profile %+v", got)
+		}
+	})
+
+	t.Run("change password", func(t *testing.T) {
+		resp := s.api.Post("/users/me/password", user, map[string]any{
+			"current_password": "secret1", "new_password": "secret2",
+		})
+		if resp.Code != http.StatusNoContent {
+			t.Fatalf("change password: %d %s", resp.Code, resp.Body.String())
+		}
+		if resp := s.api.Post("/login", map[string]any{"email": "jane@example.com", "password": "secret1"}); resp.Code != http.StatusUnauthorized {
+			t.Fatalf("expected old password to fail, got %d", resp.Code)
+		}
+		user = bearer(s.login(t, "jane@example.com", "secret2").AccessToken)
+	})
+
+	t.Run("only the owner or an admin reads a user", func(t *testing.T) {
+		if resp := s.api.Get("/users/"+userID, user); resp.Code != http.StatusOK {
+			t.Fatalf("owner: expected 200, got %d %s", resp.Code, resp.Body.String())
+		}
+		if resp := s.api.Get("/users/"+userID, admin); resp.Code != http.StatusOK {
+			t.Fatalf("admin: expected 200, got %d", resp.Code)
+		}
+		if resp := s.api.Get("/users/" + userID); resp.Code != http.StatusUnauthorized {
+			t.Fatalf("anonymous: expected 401, got %d", resp.Code)
+		}
+		if resp := s.api.Get("/users/"+adminID.Hex(), user); resp.Code != http.StatusForbidden {
+			t.Fatalf("other user: expected 403, got %d", resp.Code)
+		}
+	})
+
+	t.Run("admin changes role and disables", func(t *testing.T) {
+		resp := s.api.Put("/users/"+userID+"/role", admin, map[string]any{"role": auth.RoleAdmin})
+		if resp.Code != http.StatusOK {
+			t.Fatalf("change role: %d %s", resp.Code, resp.Body.String())
+		}
+		if resp := s.api.Get("/users", user); resp.Code != http.StatusOK {
+			t.Fatalf("expected the new role to apply to an existing token, got %d", resp.Code)
+		}
+		resp = s.api.Put("/users/"+userID+"/status", admin, map[string]any{"status": model.UserStatusDisabled, "reason": "spam"})
+		if resp.Code != http.StatusOK {
+			t.Fatalf("change status: %d %s", resp.Code, resp.Body.String())
+		}
+		if resp := s.api.Get("/users", user); resp.Code != http.StatusUnauthorized {
+			t.Fatalf("expected a disabled user's access token to be rejected, got %d", resp.Code)
+		}
+		if resp := s.api.Post("/login", map[string]any{"email": "jane@example.com", "password": "secret2"}); resp.Code != http.StatusForbidden {
+			t.Fatalf("expected disabled login to be forbidden, got %d", resp.Code)
+		}
+	})
+
+	t.Run("last admin cannot be deleted", func(t *testing.T) {
+		if resp := s.api.Delete("/users/"+adminID.Hex(), admin); resp.Code != http.StatusConflict {
+			t.Fatalf("expected 409, got %d %s", resp.Code, resp.Body.String())
+		}
+	})
+
+	t.Run("admin deletes user", func(t *testing.T) {
+		if resp := s.api.Delete("/users/"+userID, admin); resp.Code != http.StatusNoContent {
+			t.Fatalf("delete: %d %s", resp.Code, resp.Body.String())
+		}
+		if resp := s.api.Get("/users/"+userID, admin); resp.Code != http.StatusNotFound {
+			t.Fatalf("expected 404 after delete, got %d", resp.Code)
+		}
+	})
+
+	t.Run("audit trail", func(t *testing.T) {
+		var actions []string
+		for _, e := range s.audit.Entries() {
+			if e.ActorID != adminID.Hex() {
+				t.Fatalf("unexpected actor in %+v", e)
+			}
+			actions = append(actions, e.Action)
+		}
+		want := []string{model.AuditActionChangeRole, model.AuditActionChangeStatus, model.AuditActionDeleteUser}
+		if strings.Join(actions, ",") != strings.Join(want, ",") {
+			t.Fatalf("got actions %v, want %v", actions, want)
+		}
+	})
+}
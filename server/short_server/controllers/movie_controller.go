@@ -2,24 +2,27 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"time"
 
-	"github.com/beheryahmed1991/ClipsStream/server/short_server/database"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/auth"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/idempotency"
 	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/search"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/store"
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/go-playground/validator/v10"
 	"go.mongodb.org/mongo-driver/v2/bson"
-	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
 type (
 	// what datas come out from the endpoint
 	GetMoviesOutput struct {
-		Body []model.Movie `json:"body"`
+		Link string         `header:"Link"`
+		Body ListMoviesBody `json:"body"`
 	}
 
 	GetMovieOutput struct {
@@ -33,123 +36,371 @@ type (
 
 	// for post
 	AddMovieInput struct {
+		idempotency.KeyHeader
 		Body model.Movie
 	}
 	AddMovieOutput struct {
 		Body model.Movie `json:"body"`
 	}
+
+	ReplaceMovieInput struct {
+		ID   string `path:"id"`
+		Body model.Movie
+	}
+
+	// PatchMovieInput carries an RFC 7396 JSON Merge Patch document.
+	PatchMovieInput struct {
+		ID   string         `path:"id"`
+		Body map[string]any `contentType:"application/merge-patch+json"`
+	}
+
+	DeleteMovieInput struct {
+		ID string `path:"id"`
+	}
 )
 
 var (
 	validate = validator.New()
 )
 
-func RegisterMovRoutes(api huma.API) {
-	huma.Get(api, "/movies", GetMovies)
-	//huma.Get(api, "/movies/{id}", GetMovie)
+// MovieHandler serves the movie endpoints from a MovieStore, search from a
+// Searcher and title type-ahead from a Suggester. Genres and the ranking
+// written to a movie must exist in the genre catalog and the ranking scale.
+type MovieHandler struct {
+	movies   store.MovieStore
+	genres   store.GenreStore
+	rankings store.RankingStore
+	searcher search.Searcher
+	titles   search.Suggester
+}
+
+func NewMovieHandler(movies store.MovieStore, genres store.GenreStore, rankings store.RankingStore, searcher search.Searcher, titles search.Suggester) *MovieHandler {
+	return &MovieHandler{movies: movies, genres: genres, rankings: rankings, searcher: searcher, titles: titles}
+}
+
+func RegisterMovRoutes(api huma.API, h *MovieHandler) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-movies",
+		Method:      "GET",
+		Path:        "/movies",
+		Summary:     "List movies",
+		Description: "Returns movies a page at a time. Follow the next cursor (or the Link header) to fetch the following page.",
+		Errors:      []int{400, 500},
+	}, h.GetMovies)
+	huma.Register(api, huma.Operation{
+		OperationID: "search-movies",
+		Method:      "GET",
+		Path:        "/movies/search",
+		Summary:     "Search movies",
+		Description: "Matches any of the query words in titles and admin reviews, with title matches weighing more. Results are sorted by relevance; follow the next cursor (or the Link header) to fetch the following page.",
+		Errors:      []int{400, 500},
+	}, h.SearchMovies)
+	huma.Register(api, huma.Operation{
+		OperationID: "suggest-movies",
+		Method:      "GET",
+		Path:        "/movies/suggest",
+		Summary:     "Suggest movie titles",
+		Description: "Returns titles starting with the prefix, then titles with a later word starting with it, for search-as-you-type.",
+	}, h.SuggestMovies)
 	huma.Register(api, huma.Operation{
 		OperationID: "get-movie",
 		Method:      "GET",
 		Path:        "/movies/{id}",
 		Summary:     "Get one movie by ID",
 		Errors:      []int{400, 404, 500},
-	}, GetMovie)
+	}, h.GetMovie)
 	huma.Register(api, huma.Operation{
 		OperationID:   "add-movie",
 		Method:        "POST",
 		Path:          "/addmovies",
 		Summary:       "Add one movie",
 		DefaultStatus: http.StatusCreated,
-		Errors:        []int{400, 500},
-	}, AddMovie)
+		Errors:        []int{400, 401, 403, 409, 422, 500, 503},
+		Security:      auth.BearerSecurity,
+		Extensions:    auth.RequireRole(auth.RoleAdmin),
+	}, h.AddMovie)
+	huma.Register(api, huma.Operation{
+		OperationID:   "create-movie",
+		Method:        "POST",
+		Path:          "/movies",
+		Summary:       "Create one movie",
+		DefaultStatus: http.StatusCreated,
+		Errors:        []int{400, 401, 403, 409, 422, 500, 503},
+		Security:      auth.BearerSecurity,
+		Extensions:    auth.RequireRole(auth.RoleAdmin),
+	}, h.AddMovie)
+	huma.Register(api, huma.Operation{
+		OperationID: "replace-movie",
+		Method:      "PUT",
+		Path:        "/movies/{id}",
+		Summary:     "Replace one movie",
+		Errors:      []int{400, 401, 403, 404, 409, 500, 503},
+		Security:    auth.BearerSecurity,
+		Extensions:  auth.RequireRole(auth.RoleAdmin),
+	}, h.ReplaceMovie)
+	huma.Register(api, huma.Operation{
+		OperationID: "patch-movie",
+		Method:      "PATCH",
+		Path:        "/movies/{id}",
+		Summary:     "Partially update one movie with a JSON Merge Patch",
+		Errors:      []int{400, 401, 403, 404, 409, 500, 503},
+		Security:    auth.BearerSecurity,
+		Extensions:  auth.RequireRole(auth.RoleAdmin),
+		// The body has no application/json schema for huma to check; the
+		// merged movie is validated by the handler instead.
+		SkipValidateBody: true,
+	}, h.PatchMovie)
+	huma.Register(api, huma.Operation{
+		OperationID:   "delete-movie",
+		Method:        "DELETE",
+		Path:          "/movies/{id}",
+		Summary:       "Delete one movie",
+		DefaultStatus: http.StatusNoContent,
+		Errors:        []int{400, 401, 403, 404, 500},
+		Security:      auth.BearerSecurity,
+		Extensions:    auth.RequireRole(auth.RoleAdmin),
+	}, h.DeleteMovie)
 }
 
-func getMovieCol() (*mongo.Collection, error) {
-	return database.OpenCollection("movies")
-}
+func (h *MovieHandler) GetMovies(ctx context.Context, in *ListMoviesInput) (*GetMoviesOutput, error) {
+	if in.Limit <= 0 {
+		in.Limit = 20
+	}
+	q, err := in.storeQuery()
+	if err != nil {
+		return nil, huma.Error400BadRequest("invalid after cursor", err)
+	}
+	// Fetch one extra document to learn whether another page exists.
+	q.Limit = in.Limit + 1
 
-func GetMovies(ctx context.Context, in *struct{}) (*GetMoviesOutput, error) {
-	col, err := getMovieCol()
+	movies, err := h.movies.List(ctx, q)
 	if err != nil {
-		slog.Error("open movies collection failed", "op", "GetMovies", "err", err)
-		return nil, fmt.Errorf("open movies collection: %w", err)
+		slog.ErrorContext(ctx, "list movies failed", "op", "GetMovies", "err", err)
+		return nil, fmt.Errorf("list movies: %w", err)
 	}
 
-	qctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
+	out := &GetMoviesOutput{}
+	if len(movies) > in.Limit {
+		movies = movies[:in.Limit]
+		out.Body.Next = in.cursorFor(movies[len(movies)-1])
+		out.Link = in.nextLink(out.Body.Next)
+	}
+	out.Body.Items = movies
+	return out, nil
+}
+
+func (h *MovieHandler) GetMovie(ctx context.Context, in *GetMovieInput) (*GetMovieOutput, error) {
+	objID, err := bson.ObjectIDFromHex(in.ID)
+	if err != nil {
+		return nil, huma.Error400BadRequest("invalid movie ID")
+	}
 
-	cursor, err := col.Find(qctx, bson.M{})
+	movie, err := h.movies.Get(ctx, objID)
 	if err != nil {
-		slog.Error("find movies failed", "op", "GetMovies", "err", err)
-		return nil, fmt.Errorf("find movies: %w", err)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, huma.Error404NotFound("movie not found")
+		}
+		slog.ErrorContext(ctx, "find movie failed", "op", "GetMovie", "movie_id", in.ID, "err", err)
+		return nil, fmt.Errorf("find movie: %w", err)
 	}
-	defer cursor.Close(qctx)
 
-	movies := make([]model.Movie, 0)
+	return &GetMovieOutput{Body: movie}, nil
+}
 
-	if err := cursor.All(qctx, &movies); err != nil {
-		slog.Error("decode movies failed", "op", "GetMovies", "err", err)
-		return nil, fmt.Errorf("decode movies: %w", err)
+// function to add moive
+func (h *MovieHandler) AddMovie(ctx context.Context, in *AddMovieInput) (*AddMovieOutput, error) {
+	if err := validateBody(in.Body); err != nil {
+		return nil, err
 	}
 
-	return &GetMoviesOutput{Body: movies}, nil
+	movie, err := h.canonicalMovie(ctx, "AddMovie", in.Body)
+	if err != nil {
+		return nil, err
+	}
+	movie.ID = bson.NewObjectID()
+
+	if err := h.movies.Insert(ctx, movie); err != nil {
+		if errors.Is(err, store.ErrDuplicateKey) {
+			return nil, h.imdbIDConflict(ctx, "AddMovie", movie.ImdbID)
+		}
+		slog.ErrorContext(ctx, "insert movie failed", "op", "AddMovie", "err", err)
+		return nil, fmt.Errorf("insert movie: %w", err)
+	}
+	return &AddMovieOutput{
+		Body: movie,
+	}, nil
+
 }
 
-func GetMovie(ctx context.Context, in *GetMovieInput) (*GetMovieOutput, error) {
-	col, err := getMovieCol()
+func (h *MovieHandler) ReplaceMovie(ctx context.Context, in *ReplaceMovieInput) (*GetMovieOutput, error) {
+	objID, err := bson.ObjectIDFromHex(in.ID)
+	if err != nil {
+		return nil, huma.Error400BadRequest("invalid movie ID")
+	}
+	if err := validateBody(in.Body); err != nil {
+		return nil, err
+	}
+
+	movie, err := h.canonicalMovie(ctx, "ReplaceMovie", in.Body)
 	if err != nil {
-		slog.Error("open movies collection failed", "op", "GetMovie", "movie_id", in.ID, "err", err)
-		return nil, fmt.Errorf("open collection movies %w", err)
+		return nil, err
+	}
+	movie.ID = objID
+	if err := h.movies.Replace(ctx, movie); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, huma.Error404NotFound("movie not found")
+		}
+		if errors.Is(err, store.ErrDuplicateKey) {
+			return nil, h.imdbIDConflict(ctx, "ReplaceMovie", movie.ImdbID)
+		}
+		slog.ErrorContext(ctx, "replace movie failed", "op", "ReplaceMovie", "movie_id", in.ID, "err", err)
+		return nil, fmt.Errorf("replace movie: %w", err)
 	}
+	return &GetMovieOutput{Body: movie}, nil
+}
+
+// PatchMovie applies a JSON Merge Patch to the stored movie and validates the
+// merged document with the same rules as a full replace.
+func (h *MovieHandler) PatchMovie(ctx context.Context, in *PatchMovieInput) (*GetMovieOutput, error) {
 	objID, err := bson.ObjectIDFromHex(in.ID)
 	if err != nil {
 		return nil, huma.Error400BadRequest("invalid movie ID")
 	}
-	qctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
 
-	var movie model.Movie
-	if err := col.FindOne(qctx, bson.M{"_id": objID}).Decode(&movie); err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
+	current, err := h.movies.Get(ctx, objID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
 			return nil, huma.Error404NotFound("movie not found")
 		}
-		slog.Error("find movie failed", "op", "GetMovie", "movie_id", in.ID, "err", err)
+		slog.ErrorContext(ctx, "find movie failed", "op", "PatchMovie", "movie_id", in.ID, "err", err)
 		return nil, fmt.Errorf("find movie: %w", err)
 	}
 
+	movie, err := applyMoviePatch(current, in.Body)
+	if err != nil {
+		return nil, huma.Error400BadRequest("invalid merge patch", err)
+	}
+	movie.ID = objID
+	if err := validateBody(movie); err != nil {
+		return nil, err
+	}
+	if movie, err = h.canonicalMovie(ctx, "PatchMovie", movie); err != nil {
+		return nil, err
+	}
+
+	if err := h.movies.Replace(ctx, movie); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, huma.Error404NotFound("movie not found")
+		}
+		if errors.Is(err, store.ErrDuplicateKey) {
+			return nil, h.imdbIDConflict(ctx, "PatchMovie", movie.ImdbID)
+		}
+		slog.ErrorContext(ctx, "replace movie failed", "op", "PatchMovie", "movie_id", in.ID, "err", err)
+		return nil, fmt.Errorf("replace movie: %w", err)
+	}
 	return &GetMovieOutput{Body: movie}, nil
 }
 
-// function to add moive
-func AddMovie(ctx context.Context, in *AddMovieInput) (*AddMovieOutput, error) {
-	if err := validate.Struct(in.Body); err != nil {
-		var ve validator.ValidationErrors
-		if errors.As(err, &ve) {
-			details := make([]error, len(ve))
-			for i, fe := range ve {
-				details[i] = fmt.Errorf("field '%s' failed '%s'", fe.Field(), fe.Tag())
-			}
-			return nil, huma.Error400BadRequest("validation failed", details...)
+func (h *MovieHandler) DeleteMovie(ctx context.Context, in *DeleteMovieInput) (*struct{}, error) {
+	objID, err := bson.ObjectIDFromHex(in.ID)
+	if err != nil {
+		return nil, huma.Error400BadRequest("invalid movie ID")
+	}
+
+	if err := h.movies.Delete(ctx, objID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, huma.Error404NotFound("movie not found")
 		}
-		return nil, huma.Error400BadRequest("validation failed")
+		slog.ErrorContext(ctx, "delete movie failed", "op", "DeleteMovie", "movie_id", in.ID, "err", err)
+		return nil, fmt.Errorf("delete movie: %w", err)
 	}
-	col, err := getMovieCol()
+	return nil, nil
+}
+
+// applyMoviePatch merges patch into the JSON form of movie. The _id field is
+// immutable and ignored.
+func applyMoviePatch(movie model.Movie, patch map[string]any) (model.Movie, error) {
+	raw, err := json.Marshal(movie)
 	if err != nil {
-		slog.Error("open movies collection failed", "op", "AddMovie", "err", err)
-		return nil, fmt.Errorf("open movies collection: %w", err)
+		return model.Movie{}, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return model.Movie{}, err
 	}
-	qctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
 
-	movie := in.Body
-	movie.ID = bson.NewObjectID()
+	delete(patch, "_id")
+	merged, err := json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		return model.Movie{}, err
+	}
 
-	if _, err := col.InsertOne(qctx, movie); err != nil {
-		slog.Error("insert movie failed", "op", "AddMovie", "err", err)
-		return nil, fmt.Errorf("insert movie: %w", err)
+	var out model.Movie
+	if err := json.Unmarshal(merged, &out); err != nil {
+		return model.Movie{}, err
 	}
-	return &AddMovieOutput{
-		Body: movie,
-	}, nil
+	return out, nil
+}
 
+// mergePatch implements the MergePatch algorithm from RFC 7396: objects are
+// merged recursively, null removes a member and any other value replaces it.
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// canonicalMovie replaces the movie's genres and ranking with their reference
+// entries, or returns a 400 if any of them is unknown.
+func (h *MovieHandler) canonicalMovie(ctx context.Context, op string, movie model.Movie) (model.Movie, error) {
+	genres, err := canonicalGenres(ctx, h.genres, op, movie.Genre)
+	if err != nil {
+		return movie, err
+	}
+	ranking, err := canonicalRanking(ctx, h.rankings, op, movie.Ranking)
+	if err != nil {
+		return movie, err
+	}
+	movie.Genre = genres
+	movie.Ranking = ranking
+	return movie, nil
+}
+
+// MovieConflictError is the 409 body for a write that would give a second
+// movie the same imdb_id. ExistingID names the movie that already has it, so
+// the client can update that one instead.
+type MovieConflictError struct {
+	huma.ErrorModel
+	ExistingID string `json:"existing_id,omitempty" doc:"ID of the movie that already has this imdb_id"`
+}
+
+// imdbIDConflict looks up the movie holding imdbID for the 409 body. If the
+// lookup fails the conflict is still reported, just without the ID.
+func (h *MovieHandler) imdbIDConflict(ctx context.Context, op, imdbID string) error {
+	conflict := &MovieConflictError{ErrorModel: huma.ErrorModel{
+		Status: http.StatusConflict,
+		Title:  http.StatusText(http.StatusConflict),
+		Detail: fmt.Sprintf("a movie with imdb_id %q already exists", imdbID),
+	}}
+	existing, err := h.movies.GetByImdbID(ctx, imdbID)
+	switch {
+	case err == nil:
+		conflict.ExistingID = existing.ID.Hex()
+	case !errors.Is(err, store.ErrNotFound):
+		slog.ErrorContext(ctx, "find conflicting movie failed", "op", op, "imdb_id", imdbID, "err", err)
+	}
+	return conflict
 }
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/store"
+	"github.com/danielgtaylor/huma/v2"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	movieSortID          = "_id"
+	movieSortTitle       = "title"
+	movieSortTitleDesc   = "-title"
+	movieSortRanking     = "ranking"
+	movieSortRankingDesc = "-ranking"
+)
+
+type (
+	ListMoviesInput struct {
+		Limit   int    `query:"limit" minimum:"1" maximum:"100" default:"20" doc:"Maximum number of movies to return"`
+		After   string `query:"after" doc:"Opaque cursor taken from the next field of a previous page"`
+		GenreID int    `query:"genre" doc:"Only movies tagged with this genre_id"`
+		MinRank int    `query:"min_rank" doc:"Minimum ranking.ranking_value (inclusive)"`
+		MaxRank int    `query:"max_rank" doc:"Maximum ranking.ranking_value (inclusive)"`
+		Title   string `query:"title" maxLength:"500" doc:"Case-insensitive title prefix"`
+		Sort    string `query:"sort" enum:"_id,title,-title,ranking,-ranking" default:"_id" doc:"Sort order; prefix with - for descending"`
+
+		requestURL url.URL
+	}
+
+	ListMoviesBody struct {
+		Items []model.Movie `json:"items"`
+		Next  string        `json:"next,omitempty" doc:"Cursor for the next page, empty on the last page"`
+	}
+
+	// movieCursor is the keyset position of the last movie on a page. It is
+	// serialized as base64url JSON so clients treat it as opaque.
+	movieCursor struct {
+		Sort  string `json:"s"`
+		ID    string `json:"id"`
+		Title string `json:"t,omitempty"`
+		Rank  int    `json:"r,omitempty"`
+	}
+)
+
+// Resolve captures the request URL so the handler can build the Link header.
+func (in *ListMoviesInput) Resolve(ctx huma.Context) []error {
+	in.requestURL = ctx.URL()
+	if in.MinRank != 0 && in.MaxRank != 0 && in.MinRank > in.MaxRank {
+		return []error{&huma.ErrorDetail{
+			Location: "query.min_rank",
+			Message:  "min_rank must not be greater than max_rank",
+			Value:    in.MinRank,
+		}}
+	}
+	return nil
+}
+
+// storeQuery translates the query parameters and the optional cursor into a
+// store.MovieQuery.
+func (in *ListMoviesInput) storeQuery() (store.MovieQuery, error) {
+	q := store.MovieQuery{
+		GenreID:     in.GenreID,
+		MinRank:     in.MinRank,
+		MaxRank:     in.MaxRank,
+		TitlePrefix: in.Title,
+		Limit:       in.Limit,
+	}
+	switch in.Sort {
+	case movieSortTitle, movieSortTitleDesc:
+		q.SortBy = store.MovieSortTitle
+	case movieSortRanking, movieSortRankingDesc:
+		q.SortBy = store.MovieSortRanking
+	default:
+		q.SortBy = store.MovieSortID
+	}
+	q.Desc = strings.HasPrefix(in.Sort, "-")
+
+	if in.After != "" {
+		var cur movieCursor
+		if err := decodeCursor(in.After, &cur); err != nil {
+			return q, err
+		}
+		if cur.Sort != in.Sort {
+			return q, errors.New("cursor was issued for a different sort order")
+		}
+		id, err := bson.ObjectIDFromHex(cur.ID)
+		if err != nil {
+			return q, errors.New("malformed cursor")
+		}
+		q.After = &store.MovieCursor{ID: id, Title: cur.Title, Rank: cur.Rank}
+	}
+	return q, nil
+}
+
+func (in *ListMoviesInput) cursorFor(m model.Movie) string {
+	return encodeCursor(movieCursor{
+		Sort:  in.Sort,
+		ID:    m.ID.Hex(),
+		Title: m.Title,
+		Rank:  m.Ranking.RankingValue,
+	})
+}
+
+func (in *ListMoviesInput) nextLink(next string) string {
+	return nextPageLink(in.requestURL, in.Limit, next)
+}
+
+// nextPageLink returns an RFC 8288 Link header pointing at the page after
+// next, preserving every other query parameter of u.
+func nextPageLink(u url.URL, limit int, next string) string {
+	q := u.Query()
+	q.Set("after", next)
+	q.Set("limit", strconv.Itoa(limit))
+	u.RawQuery = q.Encode()
+	u.Scheme, u.Host = "", ""
+	return "<" + u.String() + `>; rel="next"`
+}
+
+// encodeCursor serializes a page cursor as base64url JSON.
+func encodeCursor(c any) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string, c any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return errors.New("malformed cursor")
+	}
+	if err := json.Unmarshal(raw, c); err != nil {
+		return errors.New("malformed cursor")
+	}
+	return nil
+}
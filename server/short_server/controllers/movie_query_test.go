@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/store"
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestStoreQuery(t *testing.T) {
+	t.Run("maps params and sort", func(t *testing.T) {
+		in := &ListMoviesInput{Limit: 10, Sort: movieSortRankingDesc, GenreID: 3, MinRank: 2, MaxRank: 4, Title: "star"}
+		got, err := in.storeQuery()
+		if err != nil {
+			t.Fatalf("store query: %v", err)
+		}
+		want := store.MovieQuery{
+			GenreID: 3, MinRank: 2, MaxRank: 4, TitlePrefix: "star",
+			SortBy: store.MovieSortRanking, Desc: true, Limit: 10,
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("decodes cursor", func(t *testing.T) {
+		id := bson.NewObjectID()
+		in := &ListMoviesInput{Sort: movieSortTitle}
+		in.After = in.cursorFor(model.Movie{ID: id, Title: "Alien", Ranking: model.Ranking{RankingValue: 3}})
+		got, err := in.storeQuery()
+		if err != nil {
+			t.Fatalf("store query: %v", err)
+		}
+		want := &store.MovieCursor{ID: id, Title: "Alien", Rank: 3}
+		if !reflect.DeepEqual(got.After, want) {
+			t.Fatalf("got %+v, want %+v", got.After, want)
+		}
+	})
+
+	t.Run("rejects cursor from another sort", func(t *testing.T) {
+		in := &ListMoviesInput{Sort: movieSortTitle}
+		in.After = (&ListMoviesInput{Sort: movieSortID}).cursorFor(model.Movie{ID: bson.NewObjectID()})
+		if _, err := in.storeQuery(); err == nil {
+			t.Fatal("expected error for mismatched cursor")
+		}
+	})
+
+	t.Run("rejects malformed cursor", func(t *testing.T) {
+		in := &ListMoviesInput{Sort: movieSortID, After: "%%%"}
+		if _, err := in.storeQuery(); err == nil {
+			t.Fatal("expected error for malformed cursor")
+		}
+	})
+}
+
+func TestNextLink(t *testing.T) {
+	in := &ListMoviesInput{Limit: 5, Sort: movieSortTitle}
+	in.requestURL = url.URL{Scheme: "http", Host: "example.com", Path: "/api/movies", RawQuery: "sort=title&genre=2"}
+	got := in.nextLink("abc")
+	want := `</api/movies?after=abc&genre=2&limit=5&sort=title>; rel="next"`
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestGetMoviesParams(t *testing.T) {
+	_, api := humatest.New(t)
+	RegisterMovRoutes(api, newTestMovieHandler())
+
+	t.Run("query params appear in the OpenAPI document", func(t *testing.T) {
+		names := []string{}
+		for _, p := range api.OpenAPI().Paths["/movies"].Get.Parameters {
+			names = append(names, p.Name)
+		}
+		for _, want := range []string{"limit", "after", "genre", "min_rank", "max_rank", "title", "sort"} {
+			if !strings.Contains(strings.Join(names, ","), want) {
+				t.Fatalf("missing query param %q in %v", want, names)
+			}
+		}
+	})
+
+	t.Run("rejects unknown sort", func(t *testing.T) {
+		if resp := api.Get("/movies?sort=year"); resp.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected 422, got %d", resp.Code)
+		}
+	})
+
+	t.Run("rejects inverted ranking range", func(t *testing.T) {
+		if resp := api.Get("/movies?min_rank=5&max_rank=1"); resp.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected 422, got %d", resp.Code)
+		}
+	})
+
+	t.Run("rejects malformed cursor", func(t *testing.T) {
+		if resp := api.Get("/movies?after=garbage"); resp.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", resp.Code)
+		}
+	})
+}
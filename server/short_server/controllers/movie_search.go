@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/search"
+	"github.com/danielgtaylor/huma/v2"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type (
+	SearchMoviesInput struct {
+		Q       string `query:"q" required:"true" minLength:"1" maxLength:"200" doc:"Words to look for in titles and admin reviews"`
+		GenreID int    `query:"genre" doc:"Only movies tagged with this genre_id"`
+		Limit   int    `query:"limit" minimum:"1" maximum:"100" default:"20" doc:"Maximum number of movies to return"`
+		After   string `query:"after" doc:"Opaque cursor taken from the next field of a previous page"`
+
+		requestURL url.URL
+	}
+
+	SearchMoviesOutput struct {
+		Link string           `header:"Link"`
+		Body SearchMoviesBody `json:"body"`
+	}
+
+	SearchMoviesBody struct {
+		Items []SearchHit `json:"items"`
+		Next  string      `json:"next,omitempty" doc:"Cursor for the next page, empty on the last page"`
+	}
+
+	// SearchHit is a movie with its relevance to the query, higher first.
+	SearchHit struct {
+		model.Movie
+		Score float64 `json:"score"`
+	}
+
+	SuggestMoviesInput struct {
+		Prefix string `query:"prefix" required:"true" minLength:"1" maxLength:"100" doc:"Beginning of a title or of any word in it; case, accents and punctuation are ignored"`
+		Limit  int    `query:"limit" minimum:"1" maximum:"20" default:"10" doc:"Maximum number of titles to return"`
+	}
+
+	SuggestMoviesOutput struct {
+		Body []search.Suggestion `json:"body"`
+	}
+
+	// searchCursor is the position of the last hit on a page, tied to the
+	// query and genre filter it was issued for.
+	searchCursor struct {
+		Q       string  `json:"q"`
+		GenreID int     `json:"g,omitempty"`
+		Score   float64 `json:"s"`
+		ID      string  `json:"id"`
+	}
+)
+
+// Resolve captures the request URL so the handler can build the Link header.
+func (in *SearchMoviesInput) Resolve(ctx huma.Context) []error {
+	in.requestURL = ctx.URL()
+	return nil
+}
+
+func (in *SearchMoviesInput) query() (search.Query, error) {
+	q := search.Query{Text: in.Q, GenreID: in.GenreID, Limit: in.Limit}
+	if in.After == "" {
+		return q, nil
+	}
+	var cur searchCursor
+	if err := decodeCursor(in.After, &cur); err != nil {
+		return q, err
+	}
+	if cur.Q != in.Q || cur.GenreID != in.GenreID {
+		return q, errors.New("cursor was issued for a different query")
+	}
+	id, err := bson.ObjectIDFromHex(cur.ID)
+	if err != nil {
+		return q, errors.New("malformed cursor")
+	}
+	q.After = &search.Cursor{Score: cur.Score, ID: id}
+	return q, nil
+}
+
+// SearchMovies returns movies matching the query words, most relevant first.
+func (h *MovieHandler) SearchMovies(ctx context.Context, in *SearchMoviesInput) (*SearchMoviesOutput, error) {
+	q, err := in.query()
+	if err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+
+	// Fetch one extra hit to learn whether another page exists.
+	q.Limit = in.Limit + 1
+
+	hits, err := h.searcher.Search(ctx, q)
+	if err != nil {
+		slog.ErrorContext(ctx, "search movies failed", "op", "SearchMovies", "err", err)
+		return nil, fmt.Errorf("search movies: %w", err)
+	}
+
+	out := &SearchMoviesOutput{}
+	if len(hits) > in.Limit {
+		hits = hits[:in.Limit]
+		last := hits[len(hits)-1]
+		out.Body.Next = encodeCursor(searchCursor{Q: in.Q, GenreID: in.GenreID, Score: last.Score, ID: last.Movie.ID.Hex()})
+		out.Link = nextPageLink(in.requestURL, in.Limit, out.Body.Next)
+	}
+	out.Body.Items = make([]SearchHit, len(hits))
+	for i, hit := range hits {
+		out.Body.Items[i] = SearchHit{Movie: hit.Movie, Score: hit.Score}
+	}
+	return out, nil
+}
+
+// SuggestMovies returns titles for type-ahead from the in-memory title index,
+// without touching the database.
+func (h *MovieHandler) SuggestMovies(ctx context.Context, in *SuggestMoviesInput) (*SuggestMoviesOutput, error) {
+	return &SuggestMoviesOutput{Body: h.titles.Suggest(in.Prefix, in.Limit)}, nil
+}
@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/auth"
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/ratelimit"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/store"
+	"github.com/danielgtaylor/huma/v2"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type (
+	UpdateProfileInput struct {
+		Body UpdateProfileRequestBody
+	}
+
+	// UpdateProfileRequestBody only changes the fields that are present.
+	UpdateProfileRequestBody struct {
+		FirstName       *string        `json:"first_name,omitempty" validate:"omitempty,min=2,max=100"`
+		LastName        *string        `json:"last_name,omitempty" validate:"omitempty,min=2,max=100"`
+		FavouriteGenres *[]model.Genre `json:"favourite_genres,omitempty" validate:"omitempty,dive"`
+	}
+
+	ChangePasswordInput struct {
+		Body ChangePasswordRequestBody
+	}
+
+	ChangePasswordRequestBody struct {
+		CurrentPassword string `json:"current_password" validate:"required"`
+		NewPassword     string `json:"new_password" validate:"required,min=6"`
+	}
+)
+
+func RegisterProfileRoutes(api huma.API, h *UserHandler) {
+	huma.Register(api, huma.Operation{
+		OperationID: "update-profile",
+		Method:      "PATCH",
+		Path:        "/users/me",
+		Summary:     "Update the caller's names and favourite genres",
+		Errors:      []int{400, 401, 404, 500, 503},
+		Security:    auth.BearerSecurity,
+		Extensions:  auth.RequireRole(auth.RoleUser),
+	}, h.UpdateProfile)
+	huma.Register(api, huma.Operation{
+		OperationID:   "change-password",
+		Method:        "POST",
+		Path:          "/users/me/password",
+		Summary:       "Change the caller's password",
+		Description:   "Requires the current password. All existing refresh tokens are revoked.",
+		DefaultStatus: http.StatusNoContent,
+		Errors:        []int{400, 401, 403, 404, 429, 500},
+		Metadata:      ratelimit.Policy(ratelimit.Per(5, time.Minute)),
+		Security:      auth.BearerSecurity,
+		Extensions:    auth.RequireRole(auth.RoleUser),
+	}, h.ChangePassword)
+}
+
+func (h *UserHandler) UpdateProfile(ctx context.Context, in *UpdateProfileInput) (*GetUserOutput, error) {
+	userID, err := currentUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateBody(in.Body); err != nil {
+		return nil, err
+	}
+	if in.Body.FirstName == nil && in.Body.LastName == nil && in.Body.FavouriteGenres == nil {
+		return nil, huma.Error400BadRequest("no profile fields to update")
+	}
+	if in.Body.FavouriteGenres != nil {
+		genres, err := canonicalGenres(ctx, h.genres, "UpdateProfile", *in.Body.FavouriteGenres)
+		if err != nil {
+			return nil, err
+		}
+		in.Body.FavouriteGenres = &genres
+	}
+
+	user, err := h.users.Update(ctx, userID, store.UserUpdate{
+		FirstName:       in.Body.FirstName,
+		LastName:        in.Body.LastName,
+		FavouriteGenres: in.Body.FavouriteGenres,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, huma.Error404NotFound("user not found")
+		}
+		slog.ErrorContext(ctx, "update profile failed", "op", "UpdateProfile", "user_id", userID.Hex(), "err", err)
+		return nil, fmt.Errorf("update profile: %w", err)
+	}
+
+	return &GetUserOutput{Body: newUserResponse(user)}, nil
+}
+
+// ChangePassword re-hashes the caller's password and clears the stored
+// tokens, so every refresh token issued before the change stops working.
+func (h *UserHandler) ChangePassword(ctx context.Context, in *ChangePasswordInput) (*struct{}, error) {
+	userID, err := currentUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateBody(in.Body); err != nil {
+		return nil, err
+	}
+
+	user, err := h.users.GetWithCredentials(ctx, userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, huma.Error404NotFound("user not found")
+		}
+		slog.ErrorContext(ctx, "find user failed", "op", "ChangePassword", "user_id", userID.Hex(), "err", err)
+		return nil, fmt.Errorf("find user: %w", err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(in.Body.CurrentPassword)); err != nil {
+		return nil, huma.Error403Forbidden("current password is incorrect")
+	}
+
+	hashedPassword, err := HashPassword(in.Body.NewPassword)
+	if err != nil {
+		slog.ErrorContext(ctx, "hash password failed", "op", "ChangePassword", "user_id", userID.Hex(), "err", err)
+		return nil, huma.Error500InternalServerError("failed to secure user password")
+	}
+
+	update := store.RevokeSessions()
+	update.Password = &hashedPassword
+	if _, err := h.users.Update(ctx, userID, update); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, huma.Error404NotFound("user not found")
+		}
+		slog.ErrorContext(ctx, "update password failed", "op", "ChangePassword", "user_id", userID.Hex(), "err", err)
+		return nil, fmt.Errorf("update password: %w", err)
+	}
+	return nil, nil
+}
+
+// currentUserID returns the ObjectID of the authenticated caller.
+func currentUserID(ctx context.Context) (bson.ObjectID, error) {
+	id, ok := auth.IdentityFromContext(ctx)
+	if !ok {
+		return bson.ObjectID{}, huma.Error401Unauthorized("authentication required")
+	}
+	objID, err := bson.ObjectIDFromHex(id.UserID)
+	if err != nil {
+		return bson.ObjectID{}, huma.Error401Unauthorized("invalid token subject")
+	}
+	return objID, nil
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/store"
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type GetRankingsOutput struct {
+	Body []model.Ranking `json:"body"`
+}
+
+// RankingHandler serves the ranking scale so clients can build their pickers
+// from the same data movie writes are checked against.
+type RankingHandler struct {
+	rankings store.RankingStore
+}
+
+func NewRankingHandler(rankings store.RankingStore) *RankingHandler {
+	return &RankingHandler{rankings: rankings}
+}
+
+func RegisterRankingRoutes(api huma.API, h *RankingHandler) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-rankings",
+		Method:      "GET",
+		Path:        "/rankings",
+		Summary:     "List the ranking scale",
+		Errors:      []int{500},
+	}, h.GetRankings)
+}
+
+func (h *RankingHandler) GetRankings(ctx context.Context, in *struct{}) (*GetRankingsOutput, error) {
+	rankings, err := h.rankings.List(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "list rankings failed", "op", "GetRankings", "err", err)
+		return nil, fmt.Errorf("list rankings: %w", err)
+	}
+	return &GetRankingsOutput{Body: rankings}, nil
+}
+
+// canonicalRanking checks r against the scale and returns the scale's entry.
+// The value must exist and the name must be the one the scale gives it,
+// ignoring case. An empty scale is a 503 pointing at the seed migration.
+func canonicalRanking(ctx context.Context, scale store.RankingStore, op string, r model.Ranking) (model.Ranking, error) {
+	canonical, err := scale.Get(ctx, r.RankingValue)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return model.Ranking{}, unknownRanking(ctx, scale, op, r.RankingValue)
+		}
+		slog.ErrorContext(ctx, "find ranking failed", "op", op, "ranking_value", r.RankingValue, "err", err)
+		return model.Ranking{}, fmt.Errorf("find ranking: %w", err)
+	}
+	if !strings.EqualFold(canonical.RankingName, r.RankingName) {
+		return model.Ranking{}, huma.Error400BadRequest(fmt.Sprintf("ranking_value %d is %q, not %q", r.RankingValue, canonical.RankingName, r.RankingName))
+	}
+	return canonical, nil
+}
+
+func unknownRanking(ctx context.Context, scale store.RankingStore, op string, value int) error {
+	rankings, err := scale.List(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "list rankings failed", "op", op, "err", err)
+		return fmt.Errorf("list rankings: %w", err)
+	}
+	if len(rankings) == 0 {
+		slog.ErrorContext(ctx, "ranking scale is empty, run migrate up", "op", op)
+		return huma.Error503ServiceUnavailable("the ranking scale has not been seeded; run `migrate up` (migration 3, seed_rankings)")
+	}
+	return huma.Error400BadRequest(fmt.Sprintf("unknown ranking_value %d", value))
+}
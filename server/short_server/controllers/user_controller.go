@@ -9,22 +9,23 @@ import (
 	"strings"
 	"time"
 
-	"github.com/beheryahmed1991/ClipsStream/server/short_server/database"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/auth"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/idempotency"
 	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/ratelimit"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/store"
 	"github.com/danielgtaylor/huma/v2"
-	"github.com/go-playground/validator/v10"
 	"go.mongodb.org/mongo-driver/v2/bson"
-	"go.mongodb.org/mongo-driver/v2/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type (
 	GetUsersOutput struct {
-		Body []model.User `json:"body"`
+		Body []UserResponse `json:"body"`
 	}
 
 	GetUserOutput struct {
-		Body model.User `json:"body"`
+		Body UserResponse `json:"body"`
 	}
 
 	GetUserInput struct {
@@ -32,11 +33,27 @@ type (
 	}
 
 	AddUserInput struct {
+		idempotency.KeyHeader
 		Body AddUserRequestBody
 	}
 
 	AddUserOutput struct {
-		Body model.User `json:"body"`
+		Body UserResponse `json:"body"`
+	}
+
+	// UserResponse is the only shape a user is serialized in. It deliberately
+	// has no password or token fields.
+	UserResponse struct {
+		ID              bson.ObjectID `json:"_id"`
+		UserID          string        `json:"user_id"`
+		FirstName       string        `json:"first_name"`
+		LastName        string        `json:"last_name"`
+		Email           string        `json:"email"`
+		Role            string        `json:"role"`
+		Status          string        `json:"status"`
+		CreatedAt       time.Time     `json:"created_at"`
+		UpdatedAt       time.Time     `json:"updated_at"`
+		FavouriteGenres []model.Genre `json:"favourite_genres"`
 	}
 	//DTO
 	AddUserRequestBody struct {
@@ -45,139 +62,152 @@ type (
 		Email           string        `json:"email" validate:"required,email"`
 		Password        string        `json:"password" validate:"required,min=6"`
 		Role            string        `json:"role" validate:"required,oneof=ADMIN USER"`
-		Token           string        `json:"token" bson:"token"`
-		RefreshToken    string        `json:"refresh_token" bson:"refresh_token"`
 		FavouriteGenres []model.Genre `json:"favourite_genres" validate:"required,dive"`
 	}
 )
 
-func RegisterUserRoutes(api huma.API) {
-	huma.Get(api, "/users", GetUsers)
+// UserHandler serves the user, profile, admin and authentication endpoints.
+type UserHandler struct {
+	users  store.UserStore
+	audit  store.AuditStore
+	genres store.GenreStore
+	tokens *auth.TokenManager
+}
+
+func NewUserHandler(users store.UserStore, audit store.AuditStore, genres store.GenreStore, tokens *auth.TokenManager) *UserHandler {
+	return &UserHandler{users: users, audit: audit, genres: genres, tokens: tokens}
+}
+
+func RegisterUserRoutes(api huma.API, h *UserHandler) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-users",
+		Method:      "GET",
+		Path:        "/users",
+		Summary:     "List users",
+		Errors:      []int{401, 403, 500},
+		Security:    auth.BearerSecurity,
+		Extensions:  auth.RequireRole(auth.RoleAdmin),
+	}, h.GetUsers)
 	huma.Register(api, huma.Operation{
 		OperationID: "get-user",
 		Method:      "GET",
 		Path:        "/users/{id}",
 		Summary:     "Get one user by ID",
-		Errors:      []int{400, 404, 500},
-	}, GetUser)
+		Description: "Users may read their own account; admins may read any.",
+		Errors:      []int{400, 401, 403, 404, 500},
+		Security:    auth.BearerSecurity,
+	}, h.GetUser)
 	huma.Register(api, huma.Operation{
 		OperationID:   "add-user",
 		Method:        "POST",
 		Path:          "/users",
 		Summary:       "Add one user",
 		DefaultStatus: http.StatusCreated,
-		Errors:        []int{400, 409, 500},
-	}, AddUser)
+		Description:   "Only an authenticated admin may create another ADMIN account.",
+		Errors:        []int{400, 403, 409, 422, 429, 500, 503},
+		Metadata:      ratelimit.Policy(ratelimit.Per(5, time.Hour)),
+	}, h.AddUser)
 }
 
-func getUserCol() (*mongo.Collection, error) {
-	return database.OpenCollection("users")
-}
-
-func GetUsers(ctx context.Context, in *struct{}) (*GetUsersOutput, error) {
-	col, err := getUserCol()
-	if err != nil {
-		slog.Error("open users collection failed", "op", "GetUsers", "err", err)
-		return nil, fmt.Errorf("open users collection: %w", err)
-	}
-
-	qctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
-	cursor, err := col.Find(qctx, bson.M{})
+func (h *UserHandler) GetUsers(ctx context.Context, in *struct{}) (*GetUsersOutput, error) {
+	users, err := h.users.List(ctx)
 	if err != nil {
-		slog.Error("find users failed", "op", "GetUsers", "err", err)
-		return nil, fmt.Errorf("find users: %w", err)
+		slog.ErrorContext(ctx, "list users failed", "op", "GetUsers", "err", err)
+		return nil, fmt.Errorf("list users: %w", err)
 	}
-	defer cursor.Close(qctx)
 
-	users := make([]model.User, 0)
-	if err := cursor.All(qctx, &users); err != nil {
-		slog.Error("decode users failed", "op", "GetUsers", "err", err)
-		return nil, fmt.Errorf("decode users: %w", err)
+	out := make([]UserResponse, len(users))
+	for i, u := range users {
+		out[i] = newUserResponse(u)
 	}
-
-	return &GetUsersOutput{Body: users}, nil
+	return &GetUsersOutput{Body: out}, nil
 }
 
-func GetUser(ctx context.Context, in *GetUserInput) (*GetUserOutput, error) {
+func (h *UserHandler) GetUser(ctx context.Context, in *GetUserInput) (*GetUserOutput, error) {
 	objID, err := bson.ObjectIDFromHex(in.ID)
 	if err != nil {
 		return nil, huma.Error400BadRequest("invalid user ID")
 	}
-	col, err := getUserCol()
-	if err != nil {
-		slog.Error("open users collection failed", "op", "GetUser", "user_id", in.ID, "err", err)
-		return nil, fmt.Errorf("open users collection: %w", err)
+	if caller, _ := auth.IdentityFromContext(ctx); caller.UserID != objID.Hex() && caller.Role != auth.RoleAdmin {
+		return nil, huma.Error403Forbidden("you may only view your own account")
 	}
 
-	qctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
-	var user model.User
-	if err := col.FindOne(qctx, bson.M{"_id": objID}).Decode(&user); err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
+	user, err := h.users.Get(ctx, objID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
 			return nil, huma.Error404NotFound("user not found")
 		}
-		slog.Error("find user failed", "op", "GetUser", "user_id", in.ID, "err", err)
+		slog.ErrorContext(ctx, "find user failed", "op", "GetUser", "user_id", in.ID, "err", err)
 		return nil, fmt.Errorf("find user: %w", err)
 	}
 
-	return &GetUserOutput{Body: user}, nil
+	return &GetUserOutput{Body: newUserResponse(user)}, nil
 }
 
-func AddUser(ctx context.Context, in *AddUserInput) (*AddUserOutput, error) {
-	if err := validate.Struct(in.Body); err != nil {
-		var ve validator.ValidationErrors
-		if errors.As(err, &ve) {
-			details := make([]error, len(ve))
-			for i, fe := range ve {
-				details[i] = fmt.Errorf("field '%s' failed '%s'", fe.Field(), fe.Tag())
-			}
-			return nil, huma.Error400BadRequest("validation failed", details...)
+func (h *UserHandler) AddUser(ctx context.Context, in *AddUserInput) (*AddUserOutput, error) {
+	if err := validateBody(in.Body); err != nil {
+		return nil, err
+	}
+	if in.Body.Role == auth.RoleAdmin {
+		if caller, ok := auth.IdentityFromContext(ctx); !ok || caller.Role != auth.RoleAdmin {
+			return nil, huma.Error403Forbidden("only admins can create admin accounts")
 		}
-		return nil, huma.Error400BadRequest("validation failed")
 	}
 
-	col, err := getUserCol()
+	genres, err := canonicalGenres(ctx, h.genres, "AddUser", in.Body.FavouriteGenres)
 	if err != nil {
-		slog.Error("open users collection failed", "op", "AddUser", "err", err)
-		return nil, fmt.Errorf("open users collection: %w", err)
+		return nil, err
 	}
 
-	qctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
 	hashedPassword, err := HashPassword(in.Body.Password)
 	if err != nil {
-		slog.Error("hash password failed", "op", "AddUser", "email", in.Body.Email, "err", err)
+		slog.ErrorContext(ctx, "hash password failed", "op", "AddUser", "email", in.Body.Email, "err", err)
 		return nil, huma.Error500InternalServerError("failed to secure user password")
 	}
-	normalizedEmail := strings.ToLower(strings.TrimSpace(in.Body.Email))
 
 	user := model.User{
 		FirstName:       in.Body.FirstName,
 		LastName:        in.Body.LastName,
-		Email:           normalizedEmail,
+		Email:           normalizeEmail(in.Body.Email),
 		Password:        hashedPassword,
 		Role:            in.Body.Role,
-		Token:           in.Body.Token,
-		RefreshToken:    in.Body.RefreshToken,
-		FavouriteGenres: in.Body.FavouriteGenres,
+		Status:          model.UserStatusActive,
+		FavouriteGenres: genres,
 	}
 	assignUserIdentityAndTimestamps(&user)
 
-	if _, err := col.InsertOne(qctx, user); err != nil {
-		if isDuplicateKeyError(err) {
+	if err := h.users.Insert(ctx, user); err != nil {
+		if errors.Is(err, store.ErrDuplicateKey) {
 			return nil, huma.Error409Conflict("user already registered")
 		}
-		slog.Error("insert user failed", "op", "AddUser", "email", user.Email, "err", err)
+		slog.ErrorContext(ctx, "insert user failed", "op", "AddUser", "email", user.Email, "err", err)
 		return nil, fmt.Errorf("insert user: %w", err)
 	}
 
-	// Do not return password hash to clients.
-	user.Password = ""
-	return &AddUserOutput{Body: user}, nil
+	return &AddUserOutput{Body: newUserResponse(user)}, nil
+}
+
+func newUserResponse(u model.User) UserResponse {
+	genres := u.FavouriteGenres
+	if genres == nil {
+		genres = []model.Genre{}
+	}
+	status := u.Status
+	if status == "" {
+		status = model.UserStatusActive
+	}
+	return UserResponse{
+		ID:              u.ID,
+		UserID:          u.UserID,
+		FirstName:       u.FirstName,
+		LastName:        u.LastName,
+		Email:           u.Email,
+		Role:            u.Role,
+		Status:          status,
+		CreatedAt:       u.CreatedAt,
+		UpdatedAt:       u.UpdatedAt,
+		FavouriteGenres: genres,
+	}
 }
 
 func assignUserIdentityAndTimestamps(user *model.User) {
@@ -198,23 +228,6 @@ func HashPassword(password string) (string, error) {
 	return string(hash), nil
 }
 
-func isDuplicateKeyError(err error) bool {
-	var writeErr mongo.WriteException
-	if errors.As(err, &writeErr) {
-		for _, e := range writeErr.WriteErrors {
-			if e.Code == 11000 {
-				return true
-			}
-		}
-	}
-
-	var bulkWriteErr mongo.BulkWriteException
-	if errors.As(err, &bulkWriteErr) {
-		for _, e := range bulkWriteErr.WriteErrors {
-			if e.Code == 11000 {
-				return true
-			}
-		}
-	}
-	return false
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-playground/validator/v10"
+)
+
+// validateBody runs the struct validation rules on v and converts any failure
+// into a 400 response listing each failed field.
+func validateBody(v any) error {
+	if err := validate.Struct(v); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			details := make([]error, len(ve))
+			for i, fe := range ve {
+				details[i] = fmt.Errorf("field '%s' failed '%s'", fe.Field(), fe.Tag())
+			}
+			return huma.Error400BadRequest("validation failed", details...)
+		}
+		return huma.Error400BadRequest("validation failed")
+	}
+	return nil
+}
@@ -4,98 +4,91 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
-	"os"
-	"sync"
-	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/config"
+	"go.mongodb.org/mongo-driver/v2/event"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
 )
 
 var (
-	clientOnce   sync.Once
-	client       *mongo.Client
-	clientErr    error
-	connectMongo = func(uri string) (*mongo.Client, error) {
-		return mongo.Connect(options.Client().ApplyURI(uri))
+	connectMongo = func(opts *options.ClientOptions) (*mongo.Client, error) {
+		return mongo.Connect(opts)
 	}
 	pingMongo = func(c *mongo.Client, ctx context.Context) error {
 		return c.Ping(ctx, readpref.Primary())
 	}
 )
 
-// LoadEnv loads environment variables from a ".env" file.
-// It logs a warning if the file cannot be read but does not stop execution.
-func LoadEnv() {
-	if err := godotenv.Load(".env"); err != nil {
-		log.Printf("warning: .env file not loaded: %v", err)
-	}
+// DB is a connected MongoDB client bound to the configured database.
+type DB struct {
+	client *mongo.Client
+	name   string
 }
 
-// InstanceDB initializes and returns the package-level singleton MongoDB client.
-//
-// On the first call, it loads environment variables, reads MONGODB_URI, creates a client,
-// and verifies connectivity with a 10-second ping; any initialization error is recorded
-// and returned on this and subsequent calls. Subsequent calls return the same client
-// instance and the stored initialization error, if any.
-func InstanceDB() (*mongo.Client, error) {
-	clientOnce.Do(func() {
-		LoadEnv()
-
-		uri := os.Getenv("MONGODB_URI")
-		if uri == "" {
-			clientErr = errors.New("MONGODB_URI is not set")
-			return
-		}
+// clientOptions translates cfg into driver options and installs monitors.
+func clientOptions(cfg config.MongoConfig, monitors []*event.CommandMonitor) *options.ClientOptions {
+	opts := options.Client().
+		ApplyURI(cfg.URI).
+		SetMinPoolSize(cfg.MinPoolSize).
+		SetMaxPoolSize(cfg.MaxPoolSize).
+		SetConnectTimeout(cfg.ConnectTimeout).
+		SetServerSelectionTimeout(cfg.ConnectTimeout)
+	if len(monitors) > 0 {
+		opts.SetMonitor(combineMonitors(monitors))
+	}
+	return opts
+}
 
-		client, clientErr = connectMongo(uri)
-		if clientErr != nil {
-			clientErr = fmt.Errorf("connect mongo: %w", clientErr)
-			return
-		}
+// Connect creates a MongoDB client from cfg and verifies connectivity with a
+// ping bounded by cfg.ConnectTimeout. Every command the client runs is
+// reported to monitors. The client is disconnected again if the ping fails,
+// so callers only own a DB on success.
+func Connect(ctx context.Context, cfg config.MongoConfig, monitors ...*event.CommandMonitor) (*DB, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
+	c, err := connectMongo(clientOptions(cfg, monitors))
+	if err != nil {
+		return nil, fmt.Errorf("connect mongo: %w", err)
+	}
 
-		if err := pingMongo(client, ctx); err != nil {
-			clientErr = fmt.Errorf("ping mongo: %w", err)
-		}
-	})
+	pingCtx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+	defer cancel()
+	if err := pingMongo(c, pingCtx); err != nil {
+		_ = c.Disconnect(ctx)
+		return nil, fmt.Errorf("ping mongo: %w", err)
+	}
 
-	return client, clientErr
+	return &DB{client: c, name: cfg.Database}, nil
 }
 
-// OpenCollection obtains a MongoDB collection handle for the named collection from the
-// database specified by the DATABASE_NAME environment variable.
-// It returns an error if collectionName is empty, if DATABASE_NAME is not set, or if
-// obtaining the MongoDB client via InstanceDB fails.
-func OpenCollection(collectionName string) (*mongo.Collection, error) {
+// Collection returns a handle for the named collection in the configured
+// database. It returns an error if collectionName is empty.
+func (d *DB) Collection(collectionName string) (*mongo.Collection, error) {
 	if collectionName == "" {
 		return nil, errors.New("collectionName is required")
 	}
+	return d.client.Database(d.name).Collection(collectionName), nil
+}
 
-	dbClient, err := InstanceDB()
-	if err != nil {
-		return nil, err
-	}
-
-	LoadEnv()
-	databaseName := os.Getenv("DATABASE_NAME")
-	if databaseName == "" {
-		return nil, errors.New("DATABASE_NAME is not set")
-	}
+// Database returns the configured database, for operations that span
+// collections such as index management.
+func (d *DB) Database() *mongo.Database {
+	return d.client.Database(d.name)
+}
 
-	return dbClient.Database(databaseName).Collection(collectionName), nil
+// Ping checks that the primary is reachable. The caller bounds it with ctx.
+func (d *DB) Ping(ctx context.Context) error {
+	return pingMongo(d.client, ctx)
 }
 
-// Disconnect closes the global MongoDB client if one has been initialized.
-// It returns any error produced by the client's Disconnect call.
-func Disconnect(ctx context.Context) error {
-	if client == nil {
+// Disconnect closes the underlying client. It is safe to call on a nil DB.
+func (d *DB) Disconnect(ctx context.Context) error {
+	if d == nil || d.client == nil {
 		return nil
 	}
-	return client.Disconnect(ctx)
-}
\ No newline at end of file
+	return d.client.Disconnect(ctx)
+}
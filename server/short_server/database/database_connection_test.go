@@ -1,48 +1,76 @@
 package database
 
 import (
+	"context"
 	"errors"
-	"sync"
 	"testing"
+	"time"
 
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/config"
+	"go.mongodb.org/mongo-driver/v2/event"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
-func resetDBState() {
-	clientOnce = sync.Once{}
-	client = nil
-	clientErr = nil
+func testMongoConfig() config.MongoConfig {
+	cfg := config.Default().Mongo
+	cfg.URI = "mongodb://fake"
+	cfg.Database = "clips"
+	return cfg
 }
 
 // test for create connection
-func TestInstance(t *testing.T) {
-	resetDBState()
+func TestConnect(t *testing.T) {
 	origConnect := connectMongo
-	origPing := pingMongo
-	defer func() {
-		connectMongo = origConnect
-		pingMongo = origPing
-	}()
-	t.Setenv("MONGODB_URI", "mongodb://fake")
-	connectMongo = func(uri string) (*mongo.Client, error) {
+	defer func() { connectMongo = origConnect }()
+
+	var got *options.ClientOptions
+	connectMongo = func(opts *options.ClientOptions) (*mongo.Client, error) {
+		got = opts
 		return nil, errors.New("connect fails")
-		//test fail
-		//return &mongo.Client{}, nil
+	}
+	cfg := testMongoConfig()
+	cfg.MaxPoolSize = 7
+	cfg.ConnectTimeout = 3 * time.Second
 
+	var started []string
+	record := func(name string) *event.CommandMonitor {
+		return &event.CommandMonitor{Started: func(context.Context, *event.CommandStartedEvent) {
+			started = append(started, name)
+		}}
 	}
-	c, err := InstanceDB()
-	//test fail
-	//if err != nil {
 
-	if err == nil || c != nil {
-		t.Fatalf("expectd connect error, got client=%v err=%v", c, err)
+	db, err := Connect(context.Background(), cfg, record("a"), record("b"))
+	if err == nil || db != nil {
+		t.Fatalf("expectd connect error, got db=%v err=%v", db, err)
+	}
+	if got == nil || *got.MaxPoolSize != 7 || *got.ConnectTimeout != 3*time.Second {
+		t.Fatalf("config not applied to client options: %+v", got)
 	}
+	got.Monitor.Started(context.Background(), &event.CommandStartedEvent{})
+	got.Monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{})
+	if len(started) != 2 || started[0] != "a" || started[1] != "b" {
+		t.Fatalf("expected every monitor to be called in order, got %v", started)
+	}
+}
 
+func TestConnectRejectsInvalidConfig(t *testing.T) {
+	origConnect := connectMongo
+	defer func() { connectMongo = origConnect }()
+	connectMongo = func(opts *options.ClientOptions) (*mongo.Client, error) {
+		t.Fatal("connect should not be attempted")
+		return nil, nil
+	}
+
+	cfg := testMongoConfig()
+	cfg.URI = ""
+	if _, err := Connect(context.Background(), cfg); err == nil {
+		t.Fatal("expected error for missing uri")
+	}
 }
 
 func TestOpenColl(t *testing.T) {
-	resetDBState()
-	col, err := OpenCollection("")
+	col, err := (&DB{}).Collection("")
 	//fail	if err != nil { check of the empty files
 	if err == nil {
 		t.Fatalf("expectd error for empty collection, got nil. collection= %v", col)
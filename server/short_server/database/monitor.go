@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/event"
+)
+
+// combineMonitors fans driver events out to every monitor, since a client
+// accepts only one.
+func combineMonitors(monitors []*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				if m.Started != nil {
+					m.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				if m.Succeeded != nil {
+					m.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				if m.Failed != nil {
+					m.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}
+
+// CommandCollection extracts the target collection from a command document
+// seen by a command monitor, or returns "" for commands such as ping that
+// do not target one. CRUD commands carry the collection as the value of
+// their first element (e.g. {find: "movies"}); getMore carries it in a
+// separate field.
+func CommandCollection(name string, cmd bson.Raw) string {
+	if name == "getMore" {
+		if v, err := cmd.LookupErr("collection"); err == nil {
+			if s, ok := v.StringValueOK(); ok {
+				return s
+			}
+		}
+		return ""
+	}
+	elems, err := cmd.Elements()
+	if err != nil || len(elems) == 0 {
+		return ""
+	}
+	s, _ := elems[0].Value().StringValueOK()
+	return s
+}
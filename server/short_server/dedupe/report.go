@@ -0,0 +1,58 @@
+package dedupe
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Report describes what a run deleted, or would delete in a dry run.
+type Report struct {
+	Strategy  string    `json:"strategy"`
+	DryRun    bool      `json:"dry_run"`
+	StartedAt time.Time `json:"started_at"`
+	Removed   int       `json:"removed"`
+	Groups    []Group   `json:"groups"`
+}
+
+// Group is one normalized email shared by several users.
+type Group struct {
+	Email    string          `json:"email"`
+	Survivor bson.ObjectID   `json:"survivor_id"`
+	Removed  []bson.ObjectID `json:"removed_ids"`
+	// MergedGenres are the favourite genres copied to the survivor.
+	MergedGenres []model.Genre `json:"merged_genres,omitempty"`
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes one row per removed user.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	action := "deleted"
+	if r.DryRun {
+		action = "would_delete"
+	}
+	_ = cw.Write([]string{"email", "survivor_id", "removed_id", "action", "strategy", "merged_genre_ids"})
+	for _, g := range r.Groups {
+		genres := make([]string, len(g.MergedGenres))
+		for i, genre := range g.MergedGenres {
+			genres[i] = strconv.Itoa(genre.GenreID)
+		}
+		for _, id := range g.Removed {
+			_ = cw.Write([]string{g.Email, g.Survivor.Hex(), id.Hex(), action, r.Strategy, strings.Join(genres, " ")})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
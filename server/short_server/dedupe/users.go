@@ -0,0 +1,313 @@
+// Package dedupe removes users registered more than once under the same
+// email, so the unique email index can be built.
+package dedupe
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Keep strategies choose which user of a duplicate group survives. When the
+// group has an active admin, only its active admins are considered, so
+// deduplication never removes the only account able to administer the
+// service.
+const (
+	// KeepOldest keeps the earliest created user.
+	KeepOldest = "oldest"
+	// KeepNewest keeps the latest created user.
+	KeepNewest = "newest"
+	// KeepMostComplete keeps the user with the most non-empty fields,
+	// falling back to the oldest on a tie.
+	KeepMostComplete = "most-complete"
+)
+
+// ArchiveCollection is the default collection deleted users are copied to.
+const ArchiveCollection = "users_dedupe_archive"
+
+// Options controls Users.
+type Options struct {
+	// Keep is one of the Keep strategies; empty means KeepOldest.
+	Keep string
+	// DryRun reports what would change without writing anything.
+	DryRun bool
+}
+
+// Users finds groups of users whose emails match after trimming and
+// lowercasing, keeps one user per group as chosen by opts.Keep, merges the
+// others' favourite genres into it, copies the others to archive and deletes
+// them, then normalizes every stored email. The report lists each group
+// whether or not opts.DryRun is set.
+func Users(ctx context.Context, users, archive *mongo.Collection, opts Options) (*Report, error) {
+	if opts.Keep == "" {
+		opts.Keep = KeepOldest
+	}
+	if !slices.Contains([]string{KeepOldest, KeepNewest, KeepMostComplete}, opts.Keep) {
+		return nil, fmt.Errorf("unknown keep strategy %q: want %s, %s or %s", opts.Keep, KeepOldest, KeepNewest, KeepMostComplete)
+	}
+
+	report := &Report{Strategy: opts.Keep, DryRun: opts.DryRun, StartedAt: time.Now().UTC()}
+	cur, err := users.Aggregate(ctx, duplicatesPipeline())
+	if err != nil {
+		return report, fmt.Errorf("find duplicate users: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var g duplicateGroup
+		if err := cur.Decode(&g); err != nil {
+			return report, fmt.Errorf("decode duplicate group: %w", err)
+		}
+		if len(g.IDs) <= 1 {
+			continue
+		}
+		group, err := dedupeGroup(ctx, users, archive, g, opts)
+		if err != nil {
+			return report, fmt.Errorf("dedupe %q: %w", g.Email, err)
+		}
+		report.Groups = append(report.Groups, group)
+		report.Removed += len(group.Removed)
+	}
+	if err := cur.Err(); err != nil {
+		return report, fmt.Errorf("find duplicate users: %w", err)
+	}
+
+	if !opts.DryRun {
+		if err := NormalizeEmails(ctx, users); err != nil {
+			return report, fmt.Errorf("normalize emails: %w", err)
+		}
+	}
+	return report, nil
+}
+
+type duplicateGroup struct {
+	Email string          `bson:"_id"`
+	IDs   []bson.ObjectID `bson:"ids"`
+	Count int             `bson:"count"`
+}
+
+// duplicatesPipeline groups users by normalized email and keeps the groups
+// with more than one member, listing their IDs.
+func duplicatesPipeline() mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"email": bson.M{
+				"$type": "string",
+				"$ne":   "",
+			},
+		}}},
+		{{Key: "$addFields", Value: bson.M{
+			"norm_email": bson.M{
+				"$toLower": bson.M{
+					"$trim": bson.M{"input": "$email"},
+				},
+			},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$norm_email",
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{
+			"count": bson.M{"$gt": 1},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+}
+
+// userDoc is a stored user with the fields the strategies look at. Raw keeps
+// the whole document for the archive.
+type userDoc struct {
+	ID              bson.ObjectID `bson:"_id"`
+	CreatedAt       time.Time     `bson:"created_at"`
+	Role            string        `bson:"role"`
+	Status          string        `bson:"status"`
+	FavouriteGenres []model.Genre `bson:"favourite_genres"`
+	Raw             bson.Raw      `bson:"-"`
+}
+
+func dedupeGroup(ctx context.Context, users, archive *mongo.Collection, g duplicateGroup, opts Options) (Group, error) {
+	cur, err := users.Find(ctx, bson.M{"_id": bson.M{"$in": g.IDs}}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return Group{}, fmt.Errorf("load users: %w", err)
+	}
+	var docs []userDoc
+	for cur.Next(ctx) {
+		var d userDoc
+		if err := cur.Decode(&d); err != nil {
+			_ = cur.Close(ctx)
+			return Group{}, fmt.Errorf("decode user: %w", err)
+		}
+		d.Raw = slices.Clone(cur.Current)
+		docs = append(docs, d)
+	}
+	if err := cur.Close(ctx); err != nil {
+		return Group{}, fmt.Errorf("load users: %w", err)
+	}
+
+	survivor, removed := chooseSurvivor(docs, opts.Keep)
+	genres, added := mergeGenres(survivor.FavouriteGenres, removed)
+	group := Group{Email: g.Email, Survivor: survivor.ID, MergedGenres: added}
+	for _, d := range removed {
+		group.Removed = append(group.Removed, d.ID)
+	}
+	if opts.DryRun {
+		return group, nil
+	}
+
+	// Archive before deleting so an interrupted run loses nothing; the
+	// upserts make a re-run safe.
+	archivedAt := time.Now().UTC()
+	for _, d := range removed {
+		doc, err := archiveDoc(d, survivor.ID, g.Email, archivedAt)
+		if err != nil {
+			return group, err
+		}
+		if _, err := archive.ReplaceOne(ctx, bson.M{"_id": d.ID}, doc, options.Replace().SetUpsert(true)); err != nil {
+			return group, fmt.Errorf("archive user %s: %w", d.ID.Hex(), err)
+		}
+	}
+	if len(added) > 0 {
+		if _, err := users.UpdateOne(ctx, bson.M{"_id": survivor.ID}, bson.M{"$set": bson.M{"favourite_genres": genres}}); err != nil {
+			return group, fmt.Errorf("merge genres into %s: %w", survivor.ID.Hex(), err)
+		}
+	}
+	if _, err := users.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": group.Removed}}); err != nil {
+		return group, fmt.Errorf("delete duplicates: %w", err)
+	}
+	return group, nil
+}
+
+// chooseSurvivor picks the user to keep, an active admin if there is one;
+// docs are in _id order.
+func chooseSurvivor(docs []userDoc, keep string) (userDoc, []userDoc) {
+	eligible := activeAdmin
+	if !slices.ContainsFunc(docs, activeAdmin) {
+		eligible = func(userDoc) bool { return true }
+	}
+	best := slices.IndexFunc(docs, eligible)
+	for i := best + 1; i < len(docs); i++ {
+		if !eligible(docs[i]) {
+			continue
+		}
+		switch keep {
+		case KeepNewest:
+			if !createdAt(docs[i]).Before(createdAt(docs[best])) {
+				best = i
+			}
+		case KeepMostComplete:
+			ci, cb := completeness(docs[i].Raw), completeness(docs[best].Raw)
+			if ci > cb || ci == cb && createdAt(docs[i]).Before(createdAt(docs[best])) {
+				best = i
+			}
+		default:
+			if createdAt(docs[i]).Before(createdAt(docs[best])) {
+				best = i
+			}
+		}
+	}
+	removed := make([]userDoc, 0, len(docs)-1)
+	removed = append(removed, docs[:best]...)
+	removed = append(removed, docs[best+1:]...)
+	return docs[best], removed
+}
+
+// activeAdmin reports whether d is an admin who has not been disabled.
+func activeAdmin(d userDoc) bool {
+	return d.Role == model.RoleAdmin && d.Status != model.UserStatusDisabled
+}
+
+// createdAt falls back to the ObjectID timestamp for users stored without
+// created_at.
+func createdAt(d userDoc) time.Time {
+	if d.CreatedAt.IsZero() {
+		return d.ID.Timestamp()
+	}
+	return d.CreatedAt
+}
+
+// completeness counts the top-level fields that hold a non-empty value.
+func completeness(doc bson.Raw) int {
+	elems, err := doc.Elements()
+	if err != nil {
+		return 0
+	}
+	n := 0
+	for _, e := range elems {
+		v := e.Value()
+		switch v.Type {
+		case bson.TypeNull, bson.TypeUndefined:
+			continue
+		case bson.TypeString:
+			if v.StringValue() == "" {
+				continue
+			}
+		case bson.TypeArray, bson.TypeEmbeddedDocument:
+			// An empty document or array encodes as its 4-byte length and a
+			// terminating zero.
+			if len(v.Value) <= 5 {
+				continue
+			}
+		}
+		n++
+	}
+	return n
+}
+
+// mergeGenres appends the genres of removed that the survivor lacks, by
+// GenreID, and returns the merged list and the additions.
+func mergeGenres(survivor []model.Genre, removed []userDoc) ([]model.Genre, []model.Genre) {
+	merged := slices.Clone(survivor)
+	if merged == nil {
+		merged = []model.Genre{}
+	}
+	var added []model.Genre
+	for _, d := range removed {
+		for _, g := range d.FavouriteGenres {
+			if !slices.ContainsFunc(merged, func(m model.Genre) bool { return m.GenreID == g.GenreID }) {
+				merged = append(merged, g)
+				added = append(added, g)
+			}
+		}
+	}
+	return merged, added
+}
+
+// archiveDoc is the removed user as stored, plus a dedupe field recording
+// why it was removed, so it can be restored by dropping that field.
+func archiveDoc(d userDoc, survivor bson.ObjectID, email string, at time.Time) (bson.D, error) {
+	var doc bson.D
+	if err := bson.Unmarshal(d.Raw, &doc); err != nil {
+		return nil, fmt.Errorf("copy user %s: %w", d.ID.Hex(), err)
+	}
+	return append(doc, bson.E{Key: "dedupe", Value: bson.M{
+		"survivor_id": survivor,
+		"email":       email,
+		"archived_at": at,
+	}}), nil
+}
+
+// NormalizeEmails trims and lowercases every stored email.
+func NormalizeEmails(ctx context.Context, col *mongo.Collection) error {
+	_, err := col.UpdateMany(
+		ctx,
+		bson.M{"email": bson.M{"$type": "string"}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{
+				"email": bson.M{
+					"$toLower": bson.M{
+						"$trim": bson.M{"input": "$email"},
+					},
+				},
+			}}},
+		},
+	)
+	return err
+}
@@ -0,0 +1,161 @@
+package dedupe
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestDuplicatesPipelineCollectsIDs(t *testing.T) {
+	var group bson.M
+	for _, stage := range duplicatesPipeline() {
+		if stage[0].Key == "$group" {
+			group = stage[0].Value.(bson.M)
+		}
+	}
+	if !reflect.DeepEqual(group["ids"], bson.M{"$push": "$_id"}) {
+		t.Fatalf("the $group stage must push every _id into ids, got %v", group)
+	}
+}
+
+func userDocOf(t *testing.T, created time.Time, fields bson.D) userDoc {
+	t.Helper()
+	doc := append(bson.D{{Key: "_id", Value: bson.NewObjectID()}, {Key: "created_at", Value: created}}, fields...)
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var d userDoc
+	if err := bson.Unmarshal(raw, &d); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	d.Raw = raw
+	return d
+}
+
+func TestChooseSurvivor(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sparse := userDocOf(t, base.Add(time.Hour), bson.D{{Key: "email", Value: "a@x.com"}, {Key: "first_name", Value: ""}})
+	oldest := userDocOf(t, base, bson.D{{Key: "email", Value: "a@x.com"}})
+	complete := userDocOf(t, base.Add(2*time.Hour), bson.D{
+		{Key: "email", Value: "a@x.com"}, {Key: "first_name", Value: "Ann"}, {Key: "last_name", Value: "Lee"},
+		{Key: "favourite_genres", Value: bson.A{bson.M{"genre_id": 1, "genre_name": "Drama"}}},
+	})
+	docs := []userDoc{sparse, oldest, complete}
+
+	for keep, want := range map[string]userDoc{
+		KeepOldest:       oldest,
+		KeepNewest:       complete,
+		KeepMostComplete: complete,
+	} {
+		survivor, removed := chooseSurvivor(docs, keep)
+		if survivor.ID != want.ID || len(removed) != 2 {
+			t.Errorf("%s: kept %s, removed %d", keep, survivor.ID.Hex(), len(removed))
+		}
+		for _, r := range removed {
+			if r.ID == survivor.ID {
+				t.Errorf("%s: survivor listed as removed", keep)
+			}
+		}
+	}
+
+	tie := []userDoc{sparse, oldest}
+	if survivor, _ := chooseSurvivor(tie, KeepMostComplete); survivor.ID != oldest.ID {
+		t.Fatal("most-complete must fall back to the oldest on a tie")
+	}
+}
+
+func TestChooseSurvivorKeepsActiveAdmin(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	oldest := userDocOf(t, base, bson.D{{Key: "role", Value: "USER"}})
+	disabledAdmin := userDocOf(t, base.Add(time.Hour), bson.D{{Key: "role", Value: "ADMIN"}, {Key: "status", Value: "DISABLED"}})
+	admin := userDocOf(t, base.Add(2*time.Hour), bson.D{{Key: "role", Value: "ADMIN"}})
+	newest := userDocOf(t, base.Add(3*time.Hour), bson.D{{Key: "role", Value: "USER"}, {Key: "first_name", Value: "Ann"}})
+	docs := []userDoc{oldest, disabledAdmin, admin, newest}
+
+	for _, keep := range []string{KeepOldest, KeepNewest, KeepMostComplete} {
+		if survivor, removed := chooseSurvivor(docs, keep); survivor.ID != admin.ID || len(removed) != 3 {
+			t.Errorf("%s: kept %s instead of the active admin", keep, survivor.ID.Hex())
+		}
+	}
+	if survivor, _ := chooseSurvivor([]userDoc{oldest, disabledAdmin}, KeepNewest); survivor.ID != disabledAdmin.ID {
+		t.Fatal("without an active admin the strategy alone must decide")
+	}
+}
+
+func TestCreatedAtFallsBackToObjectID(t *testing.T) {
+	d := userDoc{ID: bson.NewObjectIDFromTimestamp(time.Unix(1700000000, 0))}
+	if got := createdAt(d); !got.Equal(time.Unix(1700000000, 0)) {
+		t.Fatalf("createdAt = %v", got)
+	}
+}
+
+func TestMergeGenres(t *testing.T) {
+	drama, comedy, horror := model.Genre{GenreID: 1, GenreName: "Drama"}, model.Genre{GenreID: 2, GenreName: "Comedy"}, model.Genre{GenreID: 3, GenreName: "Horror"}
+	removed := []userDoc{
+		{FavouriteGenres: []model.Genre{{GenreID: 1, GenreName: "drama"}, comedy}},
+		{FavouriteGenres: []model.Genre{comedy, horror}},
+	}
+	merged, added := mergeGenres([]model.Genre{drama}, removed)
+	if !reflect.DeepEqual(merged, []model.Genre{drama, comedy, horror}) || !reflect.DeepEqual(added, []model.Genre{comedy, horror}) {
+		t.Fatalf("merged %v, added %v", merged, added)
+	}
+	if merged, added := mergeGenres(nil, nil); merged == nil || added != nil {
+		t.Fatalf("a survivor without genres must get an empty list, got %v, %v", merged, added)
+	}
+}
+
+func TestArchiveDoc(t *testing.T) {
+	d := userDocOf(t, time.Now(), bson.D{{Key: "email", Value: "A@x.com"}})
+	survivor := bson.NewObjectID()
+	doc, err := archiveDoc(d, survivor, "a@x.com", time.Now())
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if doc[0].Key != "_id" || doc[0].Value != d.ID {
+		t.Fatalf("archived document must keep its _id, got %v", doc[0])
+	}
+	last := doc[len(doc)-1]
+	if last.Key != "dedupe" || last.Value.(bson.M)["survivor_id"] != survivor {
+		t.Fatalf("expected a dedupe field naming the survivor, got %v", last)
+	}
+}
+
+func TestReport(t *testing.T) {
+	survivor, removed := bson.NewObjectID(), bson.NewObjectID()
+	r := &Report{Strategy: KeepOldest, DryRun: true, Removed: 1, Groups: []Group{{
+		Email: "a@x.com", Survivor: survivor, Removed: []bson.ObjectID{removed},
+		MergedGenres: []model.Genre{{GenreID: 2}, {GenreID: 3}},
+	}}}
+
+	var buf bytes.Buffer
+	if err := r.WriteCSV(&buf); err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	rows, _ := csv.NewReader(&buf).ReadAll()
+	want := []string{"a@x.com", survivor.Hex(), removed.Hex(), "would_delete", "oldest", "2 3"}
+	if len(rows) != 2 || !reflect.DeepEqual(rows[1], want) {
+		t.Fatalf("csv rows = %v", rows)
+	}
+
+	buf.Reset()
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"survivor_id": "`+survivor.Hex()+`"`) {
+		t.Fatalf("unexpected json %s", buf.String())
+	}
+}
+
+func TestUsersRejectsUnknownStrategy(t *testing.T) {
+	if _, err := Users(context.Background(), nil, nil, Options{Keep: "random"}); err == nil {
+		t.Fatal("expected an unknown strategy to be rejected")
+	}
+}
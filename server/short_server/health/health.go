@@ -0,0 +1,118 @@
+// Package health serves the liveness and readiness probes used by the
+// orchestrator.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+	StatusDraining    = "draining"
+)
+
+// Check is one dependency probed by /readyz.
+type Check struct {
+	Name string
+	Fn   func(ctx context.Context) error
+}
+
+type (
+	// ReadinessResponse is the /readyz body.
+	ReadinessResponse struct {
+		Status string                 `json:"status"`
+		Checks map[string]CheckResult `json:"checks"`
+	}
+
+	CheckResult struct {
+		Status    string  `json:"status"`
+		LatencyMS float64 `json:"latency_ms"`
+		Error     string  `json:"error,omitempty"`
+	}
+)
+
+// Handler answers /healthz and /readyz.
+type Handler struct {
+	checks   []Check
+	timeout  time.Duration
+	draining func() bool
+}
+
+// New returns a Handler that runs checks in parallel, each bounded by
+// timeout. draining reports whether the server is shutting down; it may be
+// nil.
+func New(timeout time.Duration, draining func() bool, checks ...Check) *Handler {
+	if draining == nil {
+		draining = func() bool { return false }
+	}
+	return &Handler{checks: checks, timeout: timeout, draining: draining}
+}
+
+// Liveness reports that the process is up. It never touches dependencies,
+// so a slow database does not get the process restarted.
+func (h *Handler) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": StatusOK})
+}
+
+// Readiness probes every dependency and answers 503 if any of them fails or
+// the server is draining.
+func (h *Handler) Readiness(w http.ResponseWriter, r *http.Request) {
+	resp := ReadinessResponse{Status: StatusOK, Checks: h.runChecks(r.Context())}
+	for _, c := range resp.Checks {
+		if c.Status != StatusOK {
+			resp.Status = StatusUnavailable
+		}
+	}
+	if h.draining() {
+		resp.Status = StatusDraining
+	}
+
+	code := http.StatusOK
+	if resp.Status != StatusOK {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, resp)
+}
+
+func (h *Handler) runChecks(ctx context.Context) map[string]CheckResult {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]CheckResult, len(h.checks))
+	)
+	for _, c := range h.checks {
+		wg.Go(func() {
+			ctx, cancel := context.WithTimeout(ctx, h.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.Fn(ctx)
+			res := CheckResult{
+				Status:    StatusOK,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				res.Status = StatusUnavailable
+				res.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[c.Name] = res
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+	return results
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
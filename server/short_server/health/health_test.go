@@ -0,0 +1,72 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func readiness(t *testing.T, h *Handler) (int, ReadinessResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.Readiness(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var body ReadinessResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, body
+}
+
+func TestLiveness(t *testing.T) {
+	h := New(time.Second, nil, Check{Name: "mongo", Fn: func(context.Context) error {
+		t.Fatal("liveness must not run checks")
+		return nil
+	}})
+	rec := httptest.NewRecorder()
+	h.Liveness(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	ok := Check{Name: "mongo", Fn: func(context.Context) error { return nil }}
+	failing := Check{Name: "mongo", Fn: func(context.Context) error { return errors.New("no primary") }}
+	slow := Check{Name: "mongo", Fn: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	tests := []struct {
+		name       string
+		check      Check
+		draining   bool
+		wantCode   int
+		wantStatus string
+		wantCheck  string
+	}{
+		{name: "ready", check: ok, wantCode: http.StatusOK, wantStatus: StatusOK, wantCheck: StatusOK},
+		{name: "dependency down", check: failing, wantCode: http.StatusServiceUnavailable, wantStatus: StatusUnavailable, wantCheck: StatusUnavailable},
+		{name: "dependency times out", check: slow, wantCode: http.StatusServiceUnavailable, wantStatus: StatusUnavailable, wantCheck: StatusUnavailable},
+		{name: "draining", check: ok, draining: true, wantCode: http.StatusServiceUnavailable, wantStatus: StatusDraining, wantCheck: StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(20*time.Millisecond, func() bool { return tt.draining }, tt.check)
+			code, body := readiness(t, h)
+			if code != tt.wantCode || body.Status != tt.wantStatus {
+				t.Fatalf("got %d %q, want %d %q", code, body.Status, tt.wantCode, tt.wantStatus)
+			}
+			if got := body.Checks["mongo"]; got.Status != tt.wantCheck {
+				t.Fatalf("unexpected check result %+v", got)
+			}
+			if tt.wantCheck != StatusOK && body.Checks["mongo"].Error == "" {
+				t.Fatal("expected the failing check to report its error")
+			}
+		})
+	}
+}
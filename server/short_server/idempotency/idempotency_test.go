@@ -0,0 +1,151 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/config"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/humatest"
+)
+
+type createInput struct {
+	KeyHeader
+	Body struct {
+		Name string `json:"name"`
+	}
+}
+
+type createOutput struct {
+	Location string `header:"Location"`
+	Body     struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+}
+
+type testAPI struct {
+	api   humatest.TestAPI
+	store *MemoryStore
+	h     *Handler
+	calls int
+	fail  bool
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	ta := &testAPI{store: NewMemoryStore()}
+	ta.h = New(config.Default().Idempotency, ta.store)
+	clock := time.Unix(1000, 0)
+	ta.h.now = func() time.Time { return clock }
+
+	_, ta.api = humatest.New(t)
+	ta.api.UseMiddleware(ta.h.Middleware(ta.api))
+	huma.Register(ta.api, huma.Operation{
+		OperationID: "create", Method: http.MethodPost, Path: "/things", DefaultStatus: http.StatusCreated,
+	}, func(ctx context.Context, in *createInput) (*createOutput, error) {
+		ta.calls++
+		if ta.fail {
+			return nil, huma.Error500InternalServerError("boom")
+		}
+		out := &createOutput{Location: "/things/" + strconv.Itoa(ta.calls)}
+		out.Body.ID = ta.calls
+		out.Body.Name = in.Body.Name
+		return out, nil
+	})
+	huma.Register(ta.api, huma.Operation{
+		OperationID: "plain", Method: http.MethodPost, Path: "/plain",
+	}, func(ctx context.Context, in *struct{}) (*struct{}, error) {
+		ta.calls++
+		return nil, nil
+	})
+	return ta
+}
+
+func TestReplay(t *testing.T) {
+	ta := newTestAPI(t)
+	first := ta.api.Post("/things", HeaderName+": k1", map[string]any{"name": "a"})
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", first.Code, first.Body)
+	}
+	retry := ta.api.Post("/things", HeaderName+": k1", map[string]any{"name": "a"})
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Fatalf("expected the first response replayed, got %d: %s", retry.Code, retry.Body)
+	}
+	if retry.Header().Get(ReplayedHeader) != "true" || retry.Header().Get("Location") != "/things/1" {
+		t.Fatalf("unexpected replay headers %v", retry.Header())
+	}
+	if ta.calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", ta.calls)
+	}
+
+	if resp := ta.api.Post("/things", HeaderName+": k2", map[string]any{"name": "a"}); resp.Code != http.StatusCreated || ta.calls != 2 {
+		t.Fatalf("a new key must run the handler, got %d after %d calls", resp.Code, ta.calls)
+	}
+	ta.api.Post("/things", map[string]any{"name": "a"})
+	ta.api.Post("/things", map[string]any{"name": "a"})
+	if ta.calls != 4 {
+		t.Fatalf("requests without a key must always run, got %d calls", ta.calls)
+	}
+}
+
+func TestKeyReusedWithDifferentBody(t *testing.T) {
+	ta := newTestAPI(t)
+	ta.api.Post("/things", HeaderName+": k1", map[string]any{"name": "a"})
+	resp := ta.api.Post("/things", HeaderName+": k1", map[string]any{"name": "b"})
+	if resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", resp.Code, resp.Body)
+	}
+	if ta.calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", ta.calls)
+	}
+}
+
+func TestKeyInFlight(t *testing.T) {
+	ta := newTestAPI(t)
+	ta.api.Post("/things", HeaderName+": k1", map[string]any{"name": "a"})
+	rec := ta.store.records["create|anonymous|k1"]
+	rec.Done = false
+	ta.store.records[rec.Key] = rec
+
+	if resp := ta.api.Post("/things", HeaderName+": k1", map[string]any{"name": "a"}); resp.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", resp.Code)
+	}
+}
+
+func TestServerErrorReleasesKey(t *testing.T) {
+	ta := newTestAPI(t)
+	ta.fail = true
+	if resp := ta.api.Post("/things", HeaderName+": k1", map[string]any{"name": "a"}); resp.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", resp.Code)
+	}
+	ta.fail = false
+	if resp := ta.api.Post("/things", HeaderName+": k1", map[string]any{"name": "a"}); resp.Code != http.StatusCreated || resp.Header().Get(ReplayedHeader) != "" {
+		t.Fatalf("expected a fresh attempt after a 5xx, got %d %v", resp.Code, resp.Header())
+	}
+}
+
+func TestExpiredRecordIsReused(t *testing.T) {
+	ta := newTestAPI(t)
+	ta.api.Post("/things", HeaderName+": k1", map[string]any{"name": "a"})
+	later := time.Unix(1000, 0).Add(config.Default().Idempotency.TTL)
+	ta.h.now = func() time.Time { return later }
+	if resp := ta.api.Post("/things", HeaderName+": k1", map[string]any{"name": "b"}); resp.Code != http.StatusCreated || ta.calls != 2 {
+		t.Fatalf("expected expired key to be reusable, got %d after %d calls", resp.Code, ta.calls)
+	}
+}
+
+func TestIgnoredAndInvalidKeys(t *testing.T) {
+	ta := newTestAPI(t)
+	ta.api.Post("/plain", HeaderName+": k1")
+	ta.api.Post("/plain", HeaderName+": k1")
+	if ta.calls != 2 {
+		t.Fatalf("operations without KeyHeader must ignore the header, got %d calls", ta.calls)
+	}
+	if resp := ta.api.Post("/things", HeaderName+": caf\xc3\xa9", map[string]any{"name": "a"}); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a non-ASCII key, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,47 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps records in process memory with the same semantics as
+// MongoStore, for tests and single-instance use. Expired records are only
+// dropped when their key is reused.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record)}
+}
+
+func (s *MemoryStore) Reserve(ctx context.Context, rec Record, now time.Time) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.records[rec.Key]; ok && !existing.expired(now) {
+		return existing, false, nil
+	}
+	s.records[rec.Key] = rec
+	return Record{}, true, nil
+}
+
+func (s *MemoryStore) Complete(ctx context.Context, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.records[rec.Key]; ok && existing.Fingerprint == rec.Fingerprint {
+		s.records[rec.Key] = rec
+	}
+	return nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.records[key]; ok && !existing.Done {
+		delete(s.records, key)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/auth"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/config"
+	"github.com/danielgtaylor/huma/v2"
+)
+
+const (
+	// HeaderName is the request header carrying the client's key.
+	HeaderName = "Idempotency-Key"
+	// ReplayedHeader is set to "true" on responses replayed from the store.
+	ReplayedHeader = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+)
+
+// KeyHeader opts an operation in when embedded in its input struct, and
+// documents the header in the OpenAPI spec.
+type KeyHeader struct {
+	IdempotencyKey string `header:"Idempotency-Key" maxLength:"255" doc:"Optional client-generated key, e.g. a UUID. Retrying with the same key and body replays the first response instead of repeating the request."`
+}
+
+// Handler enforces Idempotency-Key semantics with a Store.
+type Handler struct {
+	store       Store
+	ttl         time.Duration
+	lockTimeout time.Duration
+	now         func() time.Time
+}
+
+func New(cfg config.IdempotencyConfig, store Store) *Handler {
+	return &Handler{store: store, ttl: cfg.TTL, lockTimeout: cfg.LockTimeout, now: time.Now}
+}
+
+// Middleware applies to operations whose input embeds KeyHeader and to
+// requests that send the header; everything else passes straight through.
+// Keys are scoped to the operation and the authenticated user, so two users
+// cannot collide. The first request with a key reserves it and its response
+// is stored unless it is a 5xx, which releases the key for another attempt.
+// A retry with the same body gets the stored response with
+// Idempotent-Replayed: true; a different body is rejected with 422, and a
+// retry while the first request is still running with 409. Register it
+// after the auth and rate limit middlewares. If the store fails, the request
+// runs without idempotency.
+func (h *Handler) Middleware(api huma.API) func(huma.Context, func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		key := ctx.Header(HeaderName)
+		op := ctx.Operation()
+		if key == "" || !acceptsKey(op) {
+			next(ctx)
+			return
+		}
+		if !validKey(key) {
+			_ = huma.WriteErr(api, ctx, http.StatusBadRequest, "invalid "+HeaderName+" header",
+				&huma.ErrorDetail{Location: "header." + HeaderName, Message: "must be 1-255 printable ASCII characters", Value: key})
+			return
+		}
+
+		body, err := io.ReadAll(bodyReader(ctx, op))
+		if err != nil {
+			_ = huma.WriteErr(api, ctx, http.StatusBadRequest, "unable to read request body", err)
+			return
+		}
+
+		now := h.now()
+		rec := Record{
+			Key:         scope(ctx, op) + key,
+			Fingerprint: fingerprint(ctx, body),
+			CreatedAt:   now.UTC(),
+			ExpiresAt:   now.Add(h.lockTimeout).UTC(),
+		}
+		rc := &recorder{humaContext: ctx, body: bytes.NewReader(body), header: http.Header{}}
+
+		existing, reserved, err := h.store.Reserve(ctx.Context(), rec, now)
+		if err != nil {
+			slog.ErrorContext(ctx.Context(), "reserve idempotency key failed", "op", op.OperationID, "err", err)
+			next(rc)
+			return
+		}
+		if !reserved {
+			switch {
+			case existing.Fingerprint != rec.Fingerprint:
+				_ = huma.WriteErr(api, ctx, http.StatusUnprocessableEntity, HeaderName+" was already used for a different request")
+			case !existing.Done:
+				_ = huma.WriteErr(api, ctx, http.StatusConflict, "a request with this "+HeaderName+" is still in progress, retry later")
+			default:
+				replay(ctx, existing)
+			}
+			return
+		}
+
+		finished := false
+		defer func() {
+			if finished {
+				return
+			}
+			// The handler failed or panicked; free the key for a retry.
+			if err := h.store.Release(context.WithoutCancel(ctx.Context()), rec.Key); err != nil {
+				slog.ErrorContext(ctx.Context(), "release idempotency key failed", "op", op.OperationID, "err", err)
+			}
+		}()
+
+		next(rc)
+
+		status := rc.status
+		if status == 0 {
+			status = ctx.Status()
+		}
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		rec.Done = true
+		rec.Status = status
+		rec.Header = rc.header
+		rec.Body = rc.buf.Bytes()
+		rec.ExpiresAt = h.now().Add(h.ttl).UTC()
+		if err := h.store.Complete(context.WithoutCancel(ctx.Context()), rec); err != nil {
+			slog.ErrorContext(ctx.Context(), "store idempotent response failed", "op", op.OperationID, "err", err)
+			return
+		}
+		finished = true
+	}
+}
+
+func acceptsKey(op *huma.Operation) bool {
+	for _, p := range op.Parameters {
+		if p.In == "header" && http.CanonicalHeaderKey(p.Name) == HeaderName {
+			return true
+		}
+	}
+	return false
+}
+
+func validKey(key string) bool {
+	if len(key) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// bodyReader stops reading one byte past the operation's body limit, which
+// is enough for huma to reject an oversized body when it reads the copy.
+func bodyReader(ctx huma.Context, op *huma.Operation) io.Reader {
+	if op.MaxBodyBytes > 0 {
+		return io.LimitReader(ctx.BodyReader(), op.MaxBodyBytes+1)
+	}
+	return ctx.BodyReader()
+}
+
+func scope(ctx huma.Context, op *huma.Operation) string {
+	if id, ok := auth.IdentityFromContext(ctx.Context()); ok {
+		return op.OperationID + "|user:" + id.UserID + "|"
+	}
+	return op.OperationID + "|anonymous|"
+}
+
+func fingerprint(ctx huma.Context, body []byte) string {
+	u := ctx.URL()
+	sum := sha256.New()
+	io.WriteString(sum, ctx.Method()+" "+u.Path+"?"+u.RawQuery+"\n")
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+func replay(ctx huma.Context, rec Record) {
+	for name, values := range rec.Header {
+		for _, v := range values {
+			ctx.AppendHeader(name, v)
+		}
+	}
+	ctx.SetHeader(ReplayedHeader, "true")
+	ctx.SetStatus(rec.Status)
+	_, _ = ctx.BodyWriter().Write(rec.Body)
+}
+
+// humaContext lets recorder embed huma.Context without its field name
+// shadowing the Context method.
+type humaContext = huma.Context
+
+// recorder hands the buffered request body to huma and captures the status,
+// headers and body the operation writes.
+type recorder struct {
+	humaContext
+	body   io.Reader
+	status int
+	header http.Header
+	buf    bytes.Buffer
+}
+
+func (r *recorder) BodyReader() io.Reader {
+	return r.body
+}
+
+func (r *recorder) SetStatus(code int) {
+	r.status = code
+	r.humaContext.SetStatus(code)
+}
+
+func (r *recorder) SetHeader(name, value string) {
+	r.header.Set(name, value)
+	r.humaContext.SetHeader(name, value)
+}
+
+func (r *recorder) AppendHeader(name, value string) {
+	r.header.Add(name, value)
+	r.humaContext.AppendHeader(name, value)
+}
+
+func (r *recorder) BodyWriter() io.Writer {
+	return io.MultiWriter(r.humaContext.BodyWriter(), &r.buf)
+}
@@ -0,0 +1,79 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const defaultQueryTimeout = 10 * time.Second
+
+// MongoStore keeps records in a collection whose TTL index on expires_at
+// (declared in the schema package) removes them once they expire. The TTL
+// monitor only runs about once a minute, so the store also ignores expired
+// records itself.
+type MongoStore struct {
+	col     *mongo.Collection
+	timeout time.Duration
+}
+
+func NewMongoStore(col *mongo.Collection, timeout time.Duration) *MongoStore {
+	if timeout <= 0 {
+		timeout = defaultQueryTimeout
+	}
+	return &MongoStore{col: col, timeout: timeout}
+}
+
+func (s *MongoStore) Reserve(ctx context.Context, rec Record, now time.Time) (Record, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	// The upsert only matches an expired record under the key, which it takes
+	// over; a live record makes it insert a second _id and fail.
+	_, err := s.col.ReplaceOne(ctx,
+		bson.M{"_id": rec.Key, "expires_at": bson.M{"$lte": now}},
+		rec,
+		options.Replace().SetUpsert(true),
+	)
+	if err == nil {
+		return Record{}, true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return Record{}, false, fmt.Errorf("reserve idempotency key: %w", err)
+	}
+
+	var existing Record
+	err = s.col.FindOne(ctx, bson.M{"_id": rec.Key}).Decode(&existing)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Released between the two calls; report it as still in flight and
+		// let the client retry.
+		return Record{Key: rec.Key, Fingerprint: rec.Fingerprint, ExpiresAt: rec.ExpiresAt}, false, nil
+	}
+	if err != nil {
+		return Record{}, false, fmt.Errorf("find idempotency key: %w", err)
+	}
+	return existing, false, nil
+}
+
+func (s *MongoStore) Complete(ctx context.Context, rec Record) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	if _, err := s.col.ReplaceOne(ctx, bson.M{"_id": rec.Key, "fingerprint": rec.Fingerprint}, rec); err != nil {
+		return fmt.Errorf("complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (s *MongoStore) Release(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	if _, err := s.col.DeleteOne(ctx, bson.M{"_id": key, "done": false}); err != nil {
+		return fmt.Errorf("release idempotency key: %w", err)
+	}
+	return nil
+}
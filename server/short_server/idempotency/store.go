@@ -0,0 +1,43 @@
+// Package idempotency lets clients retry unsafe operations with an
+// Idempotency-Key header: the first response is stored and replayed to every
+// retry that carries the same key and body, so a retry after a timeout does
+// not repeat the side effect.
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Record is the stored outcome of the first request made with a key.
+type Record struct {
+	Key string `bson:"_id"`
+	// Fingerprint identifies the request that reserved the key, so reuse of
+	// the key for a different request can be rejected.
+	Fingerprint string `bson:"fingerprint"`
+	// Done is false while the request holding the key is still in flight.
+	Done      bool        `bson:"done"`
+	Status    int         `bson:"status,omitempty"`
+	Header    http.Header `bson:"header,omitempty"`
+	Body      []byte      `bson:"body,omitempty"`
+	CreatedAt time.Time   `bson:"created_at"`
+	// ExpiresAt is when the record may be forgotten: the lock timeout while
+	// in flight, the replay TTL once Done.
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+func (r Record) expired(now time.Time) bool {
+	return !now.Before(r.ExpiresAt)
+}
+
+// Store persists records. Implementations must be safe for concurrent use.
+type Store interface {
+	// Reserve saves rec unless an unexpired record with the same key exists,
+	// in which case that record is returned and reserved is false.
+	Reserve(ctx context.Context, rec Record, now time.Time) (existing Record, reserved bool, err error)
+	// Complete replaces the reservation for rec.Key with the finished record.
+	Complete(ctx context.Context, rec Record) error
+	// Release drops an unfinished reservation so the key can be retried.
+	Release(ctx context.Context, key string) error
+}
@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AccessLog replaces gin.Logger. It accepts the caller's X-Request-ID or
+// generates one, echoes it in the response, stores it in the request context
+// for ContextHandler, and writes one JSON line per request once the handler
+// chain has finished, carrying the span recorded with SetSpanContext. Install
+// it before every other middleware.
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		info := &RequestInfo{ID: requestID(c.GetHeader(RequestIDHeader))}
+		c.Header(RequestIDHeader, info.ID)
+		c.Request = c.Request.WithContext(WithRequestInfo(c.Request.Context(), info))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx := c.Request.Context()
+		if sc := info.SpanContext(); sc.IsValid() {
+			ctx = trace.ContextWithSpanContext(ctx, sc)
+		}
+		slog.LogAttrs(ctx, level, "http request",
+			slog.String("method", c.Request.Method),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("user_id", info.UserID()),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
+
+// Recovery replaces gin.Recovery so panics are logged as JSON with the
+// request's IDs instead of as a plain-text stack dump.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		slog.ErrorContext(c.Request.Context(), "panic recovered", "op", c.FullPath(), "err", err, "stack", string(debug.Stack()))
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
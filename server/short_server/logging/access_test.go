@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// captureLogs routes the default logger through ContextHandler into a buffer.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(NewContextHandler(slog.NewJSONHandler(&buf, nil))))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, raw := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var m map[string]any
+		if err := json.Unmarshal(raw, &m); err != nil {
+			t.Fatalf("decode %q: %v", raw, err)
+		}
+		lines = append(lines, m)
+	}
+	return lines
+}
+
+func newTestEngine() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(AccessLog(), Recovery())
+	r.GET("/movies/:id", func(c *gin.Context) {
+		SetUserID(c.Request.Context(), "user-1")
+		slog.ErrorContext(c.Request.Context(), "find movie failed", "op", "GetMovie")
+		c.String(http.StatusNotFound, "missing")
+	})
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+	return r
+}
+
+func TestAccessLog(t *testing.T) {
+	buf := captureLogs(t)
+	r := newTestEngine()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/movies/42", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	r.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(RequestIDHeader); got != "abc-123" {
+		t.Fatalf("expected request id to be echoed, got %q", got)
+	}
+	lines := logLines(t, buf)
+	if len(lines) != 2 {
+		t.Fatalf("expected handler line and access line, got %d", len(lines))
+	}
+	if lines[0]["request_id"] != "abc-123" {
+		t.Fatalf("handler log line missing request id: %v", lines[0])
+	}
+
+	access := lines[1]
+	want := map[string]any{
+		"msg": "http request", "method": "GET", "route": "/movies/:id",
+		"status": float64(404), "bytes": float64(len("missing")),
+		"user_id": "user-1", "request_id": "abc-123",
+	}
+	for k, v := range want {
+		if access[k] != v {
+			t.Errorf("access log %s = %v, want %v", k, access[k], v)
+		}
+	}
+	if _, ok := access["latency_ms"]; !ok {
+		t.Error("access log missing latency_ms")
+	}
+}
+
+func TestAccessLogGeneratesRequestID(t *testing.T) {
+	for _, incoming := range []string{"", "has space", strings.Repeat("x", maxRequestIDLen+1)} {
+		captureLogs(t)
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/movies/1", nil)
+		req.Header.Set(RequestIDHeader, incoming)
+		newTestEngine().ServeHTTP(rec, req)
+
+		got := rec.Header().Get(RequestIDHeader)
+		if got == "" || got == incoming || len(got) != 32 {
+			t.Fatalf("incoming %q: expected a generated id, got %q", incoming, got)
+		}
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	buf := captureLogs(t)
+	rec := httptest.NewRecorder()
+	newTestEngine().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	lines := logLines(t, buf)
+	if len(lines) != 2 || lines[0]["msg"] != "panic recovered" || lines[1]["level"] != "ERROR" {
+		t.Fatalf("unexpected log lines %v", lines)
+	}
+	if lines[0]["request_id"] == nil || lines[0]["request_id"] != lines[1]["request_id"] {
+		t.Fatalf("panic and access lines should share the request id: %v", lines)
+	}
+}
@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestContextHandlerAddsTraceIDs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewContextHandler(slog.NewJSONHandler(&buf, nil))).With("component", "test")
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x4b, 0xf9},
+		SpanID:  trace.SpanID{0x00, 0xf0},
+	})
+	logger.ErrorContext(trace.ContextWithSpanContext(context.Background(), sc), "find movies failed")
+	logger.ErrorContext(context.Background(), "no span")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("expected two lines, got %q", buf.String())
+	}
+	var withSpan, withoutSpan map[string]any
+	_ = json.Unmarshal(lines[0], &withSpan)
+	_ = json.Unmarshal(lines[1], &withoutSpan)
+
+	if withSpan["trace_id"] != sc.TraceID().String() || withSpan["span_id"] != sc.SpanID().String() {
+		t.Fatalf("trace ids missing: %v", withSpan)
+	}
+	if withSpan["component"] != "test" {
+		t.Fatalf("attributes from With lost: %v", withSpan)
+	}
+	if _, ok := withoutSpan["trace_id"]; ok {
+		t.Fatalf("unexpected trace id without span: %v", withoutSpan)
+	}
+}
@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen caps client-supplied IDs so they cannot bloat every log line.
+const maxRequestIDLen = 128
+
+// RequestInfo is the per-request state shared between the access log, which
+// runs outermost, and inner middlewares that learn more about the request,
+// such as the authenticated user and the request's span.
+type RequestInfo struct {
+	ID string
+
+	mu     sync.Mutex
+	userID string
+	span   trace.SpanContext
+}
+
+type requestInfoKey struct{}
+
+// WithRequestInfo returns a context carrying info.
+func WithRequestInfo(ctx context.Context, info *RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFromContext returns the request info stored by the access log.
+func RequestInfoFromContext(ctx context.Context) (*RequestInfo, bool) {
+	info, ok := ctx.Value(requestInfoKey{}).(*RequestInfo)
+	return info, ok
+}
+
+// RequestID returns the current request's ID, or "" outside a request.
+func RequestID(ctx context.Context) string {
+	if info, ok := RequestInfoFromContext(ctx); ok {
+		return info.ID
+	}
+	return ""
+}
+
+// SetUserID records the authenticated caller for the access log. It is a
+// no-op outside a request.
+func SetUserID(ctx context.Context, userID string) {
+	if info, ok := RequestInfoFromContext(ctx); ok {
+		info.mu.Lock()
+		info.userID = userID
+		info.mu.Unlock()
+	}
+}
+
+// UserID returns the caller recorded with SetUserID.
+func (i *RequestInfo) UserID() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.userID
+}
+
+// SetSpanContext records the request's server span for the access log, which
+// runs before the span starts. It is a no-op outside a request.
+func SetSpanContext(ctx context.Context, sc trace.SpanContext) {
+	if info, ok := RequestInfoFromContext(ctx); ok {
+		info.mu.Lock()
+		info.span = sc
+		info.mu.Unlock()
+	}
+}
+
+// SpanContext returns the span recorded with SetSpanContext.
+func (i *RequestInfo) SpanContext() trace.SpanContext {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.span
+}
+
+// requestID returns the client's ID when it is usable, or a fresh one.
+func requestID(incoming string) string {
+	if validRequestID(incoming) {
+		return incoming
+	}
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// validRequestID accepts printable ASCII without spaces, so IDs are safe to
+// echo in headers and logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -2,10 +2,26 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/auth"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/config"
 	"github.com/beheryahmed1991/ClipsStream/server/short_server/controllers"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/database"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/health"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/idempotency"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/logging"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/metrics"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/ratelimit"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/schema"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/search"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/server"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/store"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/tracing"
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/danielgtaylor/huma/v2/adapters/humagin"
 	"github.com/gin-gonic/gin"
@@ -19,35 +35,187 @@ type HelloOutput struct {
 	}
 }
 
-// main initializes a Gin router, configures Huma under the /api group with a GET /hello endpoint that returns {"message":"hello"}, and starts the HTTP server on :8080, logging and exiting with status 1 if startup fails.
+// main loads the configuration, connects to MongoDB, wires the stores,
+// search indexes and middleware into Huma under /api, and serves HTTP until
+// SIGINT or SIGTERM, exiting with status 1 if startup or shutdown fails.
 func main() {
+	configPath := flag.String("config", "", "path to a YAML or TOML config file (defaults to $CONFIG_FILE)")
+	flag.Parse()
+
 	gin.SetMode(gin.ReleaseMode)
-	// r:=gin.Default()
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	logger := slog.New(logging.NewContextHandler(slog.NewJSONHandler(os.Stdout, nil)))
 	slog.SetDefault(logger)
 
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		slog.Error("load configuration failed", "err", err)
+		os.Exit(1)
+	}
+	if err := cfg.Validate(); err != nil {
+		slog.Error("configuration invalid", "err", err)
+		os.Exit(1)
+	}
+
+	tokens, err := auth.NewTokenManager(cfg.Auth.JWTSecret, cfg.Auth.AccessTokenTTL, cfg.Auth.RefreshTokenTTL)
+	if err != nil {
+		slog.Error("auth configuration invalid", "err", err)
+		os.Exit(1)
+	}
+
+	limiter, err := ratelimit.New(cfg.RateLimit, ratelimit.NewMemoryStore())
+	if err != nil {
+		slog.Error("rate limit configuration invalid", "err", err)
+		os.Exit(1)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		slog.Error("tracing setup failed", "err", err)
+		os.Exit(1)
+	}
+
+	appMetrics := metrics.New()
+	db, err := database.Connect(context.Background(), cfg.Mongo, appMetrics.CommandMonitor(), tracing.CommandMonitor())
+	if err != nil {
+		slog.Error("connect database failed", "err", err)
+		os.Exit(1)
+	}
+
+	if cfg.Mongo.EnsureIndexes {
+		changes, err := schema.Reconcile(context.Background(), db.Database(), schema.Collections(), schema.Options{
+			Validation:     cfg.Mongo.SchemaValidation,
+			SkipDuplicates: cfg.Mongo.SkipDuplicateIndexes,
+		})
+		for _, c := range changes {
+			if c.Action == schema.ActionSkipDuplicates {
+				slog.Warn("unique index not built: documents share its key; remove the duplicates (cmd/dedupe_users does this for users) and restart", "collection", c.Collection, "index", c.Index)
+				continue
+			}
+			slog.Info("schema reconciled", "change", c.String())
+		}
+		if err != nil {
+			slog.Error("reconcile indexes failed", "err", err)
+			os.Exit(1)
+		}
+	}
+
 	r := gin.New()
-	r.Use(gin.Logger(), gin.Recovery())
+	r.Use(logging.AccessLog(), logging.Recovery())
+	srv := server.New(cfg.Server, r)
+
+	probes := health.New(cfg.Server.ReadinessTimeout, srv.Draining,
+		health.Check{Name: "mongo", Fn: db.Ping},
+	)
+	r.GET("/healthz", gin.WrapF(probes.Liveness))
+	r.GET("/readyz", gin.WrapF(probes.Readiness))
+	r.GET("/metrics", gin.WrapH(appMetrics.Handler()))
 
 	apiGroup := r.Group("/api")
 
-	config := huma.DefaultConfig("My API", "1.0.0")
-	config.Servers = []*huma.Server{
+	humaConfig := huma.DefaultConfig(cfg.API.Title, cfg.API.Version)
+	humaConfig.Servers = []*huma.Server{
 		{URL: "/api"},
 	}
+	humaConfig.Components.SecuritySchemes = map[string]*huma.SecurityScheme{
+		auth.SecuritySchemeName: auth.SecurityScheme(),
+	}
 
-	api := humagin.NewWithGroup(r, apiGroup, config)
+	idempotencyCol, err := db.Collection("idempotency_keys")
+	if err != nil {
+		slog.Error("open idempotency_keys collection failed", "err", err)
+		os.Exit(1)
+	}
+	idempotent := idempotency.New(cfg.Idempotency, idempotency.NewMongoStore(idempotencyCol, cfg.Mongo.QueryTimeout))
+
+	movieCol, err := db.Collection("movies")
+	if err != nil {
+		slog.Error("open movies collection failed", "err", err)
+		os.Exit(1)
+	}
+	userCol, err := db.Collection("users")
+	if err != nil {
+		slog.Error("open users collection failed", "err", err)
+		os.Exit(1)
+	}
+	auditCol, err := db.Collection("user_audit")
+	if err != nil {
+		slog.Error("open user_audit collection failed", "err", err)
+		os.Exit(1)
+	}
+	genreCol, err := db.Collection("genres")
+	if err != nil {
+		slog.Error("open genres collection failed", "err", err)
+		os.Exit(1)
+	}
+	rankingCol, err := db.Collection("rankings")
+	if err != nil {
+		slog.Error("open rankings collection failed", "err", err)
+		os.Exit(1)
+	}
+
+	var movieStore store.MovieStore = store.NewMongoMovieStore(movieCol, cfg.Mongo.QueryTimeout)
+	var searcher search.Searcher = search.NewMongoSearcher(movieCol, cfg.Mongo.QueryTimeout)
+	titles := search.NewTitleIndex()
+	indexes := []search.Indexer{titles}
+	if cfg.Search.Backend == config.SearchBackendMemory {
+		index := search.NewIndex()
+		indexes = append(indexes, index)
+		searcher = index
+	}
+	if err := search.Load(context.Background(), movieStore, indexes...); err != nil {
+		slog.Error("build search indexes failed", "err", err)
+		os.Exit(1)
+	}
+	movieStore = search.Track(movieStore, indexes...)
+	userStore := store.NewMongoUserStore(userCol, cfg.Mongo.QueryTimeout)
+	genreStore := store.NewMongoGenreStore(genreCol, cfg.Mongo.QueryTimeout)
+	rankingStore := store.NewMongoRankingStore(rankingCol, cfg.Mongo.QueryTimeout)
+	movies := controllers.NewMovieHandler(movieStore, genreStore, rankingStore, searcher, titles)
+	users := controllers.NewUserHandler(
+		userStore,
+		store.NewMongoAuditStore(auditCol, cfg.Mongo.QueryTimeout),
+		genreStore,
+		tokens,
+	)
+	api := humagin.NewWithGroup(r, apiGroup, humaConfig)
+	api.UseMiddleware(
+		tracing.Middleware(),
+		appMetrics.Middleware(),
+		auth.Middleware(api, tokens, users),
+		limiter.Middleware(api),
+		idempotent.Middleware(api),
+	)
 
 	huma.Get(api, "/hello", func(ctx context.Context, in *struct{}) (*HelloOutput, error) {
 		out := &HelloOutput{}
 		out.Body.Message = "hello"
 		return out, nil
 	})
-	controllers.RegisterMovRoutes(api)
-	controllers.RegisterUserRoutes(api)
-	slog.Info("server starting", "addr", ":8080")
-	if err := r.Run(":8080"); err != nil {
-		slog.Error("server failed to start", "err", err)
+	controllers.RegisterMovRoutes(api, movies)
+	controllers.RegisterGenreRoutes(api, controllers.NewGenreHandler(genreStore, movieStore, userStore))
+	controllers.RegisterRankingRoutes(api, controllers.NewRankingHandler(rankingStore))
+	controllers.RegisterUserRoutes(api, users)
+	controllers.RegisterProfileRoutes(api, users)
+	controllers.RegisterAdminRoutes(api, users)
+	controllers.RegisterAuthRoutes(api, users)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	runErr := srv.Run(ctx)
+	if runErr != nil {
+		slog.Error("server stopped with error", "err", runErr)
+	}
+
+	disconnectCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := db.Disconnect(disconnectCtx); err != nil {
+		slog.Error("disconnect database failed", "err", err)
+	}
+	if err := shutdownTracing(disconnectCtx); err != nil {
+		slog.Error("flush traces failed", "err", err)
+	}
+	_ = os.Stdout.Sync()
+	if runErr != nil {
 		os.Exit(1)
 	}
 }
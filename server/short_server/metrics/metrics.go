@@ -0,0 +1,96 @@
+// Package metrics exposes Prometheus metrics for huma operations and MongoDB
+// commands.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "clipsstream"
+
+// Metrics owns a Prometheus registry and the collectors recorded into it.
+// Using a private registry rather than the global one keeps tests isolated.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+
+	mongoDuration *prometheus.HistogramVec
+	mongoErrors   *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests handled, by huma operation, method and status code.",
+		}, []string{"operation", "method", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time spent serving HTTP requests, by huma operation, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "method", "status"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests currently being served, by huma operation.",
+		}, []string{"operation"}),
+		mongoDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "mongo_command_duration_seconds",
+			Help:      "MongoDB command latency, by collection, command and outcome.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"collection", "command", "outcome"}),
+		mongoErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "mongo_command_errors_total",
+			Help:      "MongoDB commands that failed, by collection and command.",
+		}, []string{"collection", "command"}),
+	}
+	m.registry.MustRegister(
+		m.requests, m.duration, m.inFlight,
+		m.mongoDuration, m.mongoErrors,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus text exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Middleware records every huma operation. Register it before the auth
+// middleware so rejected requests are counted too.
+func (m *Metrics) Middleware() func(huma.Context, func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		op := ctx.Operation().OperationID
+		inFlight := m.inFlight.WithLabelValues(op)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		start := time.Now()
+		next(ctx)
+
+		status := ctx.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := []string{op, ctx.Method(), strconv.Itoa(status)}
+		m.requests.WithLabelValues(labels...).Inc()
+		m.duration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/event"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("scrape: %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func assertContains(t *testing.T, body string, lines ...string) {
+	t.Helper()
+	for _, l := range lines {
+		if !strings.Contains(body, l) {
+			t.Errorf("metrics output missing %q", l)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	m := New()
+	_, api := humatest.New(t)
+	api.UseMiddleware(m.Middleware())
+	huma.Register(api, huma.Operation{
+		OperationID: "get-thing", Method: http.MethodGet, Path: "/things/{id}",
+	}, func(ctx context.Context, in *struct {
+		ID string `path:"id"`
+	}) (*struct{}, error) {
+		if in.ID == "missing" {
+			return nil, huma.Error404NotFound("no such thing")
+		}
+		return nil, nil
+	})
+
+	api.Get("/things/a")
+	api.Get("/things/b")
+	api.Get("/things/missing")
+
+	assertContains(t, scrape(t, m),
+		`clipsstream_http_requests_total{method="GET",operation="get-thing",status="204"} 2`,
+		`clipsstream_http_requests_total{method="GET",operation="get-thing",status="404"} 1`,
+		`clipsstream_http_request_duration_seconds_count{method="GET",operation="get-thing",status="204"} 2`,
+		`clipsstream_http_requests_in_flight{operation="get-thing"} 0`,
+	)
+}
+
+func TestCommandMonitor(t *testing.T) {
+	m := New()
+	mon := m.CommandMonitor()
+	ctx := context.Background()
+
+	find, _ := bson.Marshal(bson.D{{Key: "find", Value: "movies"}, {Key: "filter", Value: bson.D{}}})
+	mon.Started(ctx, &event.CommandStartedEvent{CommandName: "find", RequestID: 1, Command: find})
+	mon.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{
+		CommandName: "find", RequestID: 1, Duration: 3 * time.Millisecond,
+	}})
+
+	insert, _ := bson.Marshal(bson.D{{Key: "insert", Value: "users"}})
+	mon.Started(ctx, &event.CommandStartedEvent{CommandName: "insert", RequestID: 2, Command: insert})
+	mon.Failed(ctx, &event.CommandFailedEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "insert", RequestID: 2, Duration: time.Millisecond},
+		Failure:              errors.New("E11000 duplicate key"),
+	})
+
+	getMore, _ := bson.Marshal(bson.D{{Key: "getMore", Value: int64(42)}, {Key: "collection", Value: "movies"}})
+	mon.Started(ctx, &event.CommandStartedEvent{CommandName: "getMore", RequestID: 3, Command: getMore})
+	mon.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{
+		CommandName: "getMore", RequestID: 3,
+	}})
+
+	ping, _ := bson.Marshal(bson.D{{Key: "ping", Value: 1}})
+	mon.Started(ctx, &event.CommandStartedEvent{CommandName: "ping", RequestID: 4, Command: ping})
+	mon.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{
+		CommandName: "ping", RequestID: 4,
+	}})
+
+	assertContains(t, scrape(t, m),
+		`clipsstream_mongo_command_duration_seconds_count{collection="movies",command="find",outcome="success"} 1`,
+		`clipsstream_mongo_command_duration_seconds_count{collection="users",command="insert",outcome="error"} 1`,
+		`clipsstream_mongo_command_duration_seconds_count{collection="movies",command="getMore",outcome="success"} 1`,
+		`clipsstream_mongo_command_duration_seconds_count{collection="none",command="ping",outcome="success"} 1`,
+		`clipsstream_mongo_command_errors_total{collection="users",command="insert"} 1`,
+	)
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/database"
+	"go.mongodb.org/mongo-driver/v2/event"
+)
+
+// noCollection labels commands that do not target a collection, such as
+// ping or hello.
+const noCollection = "none"
+
+// CommandMonitor returns a driver monitor that records the latency of every
+// command and counts failures. The collection is only present on the
+// started event, so it is remembered by request ID until the command
+// finishes.
+func (m *Metrics) CommandMonitor() *event.CommandMonitor {
+	var collections sync.Map // request ID -> collection name
+
+	finished := func(e event.CommandFinishedEvent, outcome string) string {
+		collection := noCollection
+		if v, ok := collections.LoadAndDelete(e.RequestID); ok {
+			collection = v.(string)
+		}
+		m.observeCommand(collection, e.CommandName, outcome, e.Duration)
+		return collection
+	}
+
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			collection := database.CommandCollection(e.CommandName, e.Command)
+			if collection == "" {
+				collection = noCollection
+			}
+			collections.Store(e.RequestID, collection)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			finished(e.CommandFinishedEvent, "success")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			collection := finished(e.CommandFinishedEvent, "error")
+			m.mongoErrors.WithLabelValues(collection, e.CommandName).Inc()
+		},
+	}
+}
+
+func (m *Metrics) observeCommand(collection, command, outcome string, d time.Duration) {
+	m.mongoDuration.WithLabelValues(collection, command, outcome).Observe(d.Seconds())
+}
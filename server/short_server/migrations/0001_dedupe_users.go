@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/dedupe"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/schema"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// dedupeUsers removes users registered twice under one email, normalizes the
+// stored emails and builds the unique email index, as cmd/dedupe_users does
+// with its defaults. Removed users are copied to the archive collection, but
+// restoring them is a manual decision, so it has no Down.
+var dedupeUsers = Migration{
+	Version: 1,
+	Name:    "dedupe_users",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		report, err := dedupe.Users(ctx, db.Collection("users"), db.Collection(dedupe.ArchiveCollection), dedupe.Options{Keep: dedupe.KeepOldest})
+		if err != nil {
+			return fmt.Errorf("remove duplicates: %w", err)
+		}
+		slog.InfoContext(ctx, "deduplicated users", "groups", len(report.Groups), "removed", report.Removed, "archive", dedupe.ArchiveCollection)
+		users, _ := schema.Lookup("users")
+		if _, err := schema.Reconcile(ctx, db, []schema.Collection{users}, schema.Options{}); err != nil {
+			return fmt.Errorf("ensure unique email index: %w", err)
+		}
+		return nil
+	},
+}
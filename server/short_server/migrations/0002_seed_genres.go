@@ -0,0 +1,146 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/schema"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/store"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// seedGenres builds the genres catalog from the genres already embedded in
+// movies and users, then rewrites the embedded copies to the catalog names.
+// Genres already in the catalog keep their names. Renaming the embedded
+// copies loses their old names, so it has no Down.
+var seedGenres = Migration{
+	Version: 2,
+	Name:    "seed_genres",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		genres, _ := schema.Lookup("genres")
+		if _, err := schema.Reconcile(ctx, db, []schema.Collection{genres}, schema.Options{}); err != nil {
+			return fmt.Errorf("ensure genres indexes: %w", err)
+		}
+
+		var usage []genreUsage
+		for _, src := range []struct{ col, field string }{{"movies", "genre"}, {"users", "favourite_genres"}} {
+			found, err := embeddedGenres(ctx, db.Collection(src.col), src.field)
+			if err != nil {
+				return err
+			}
+			usage = append(usage, found...)
+		}
+
+		catalog := store.NewMongoGenreStore(db.Collection("genres"), 0)
+		existing, err := catalog.List(ctx)
+		if err != nil {
+			return err
+		}
+		seeded := catalogGenres(existing, usage)
+		for _, g := range seeded[len(existing):] {
+			if err := catalog.Insert(ctx, g); err != nil {
+				return fmt.Errorf("insert genre %d: %w", g.GenreID, err)
+			}
+		}
+		slog.InfoContext(ctx, "seeded genres", "existing", len(existing), "added", len(seeded)-len(existing))
+
+		movies := store.NewMongoMovieStore(db.Collection("movies"), 0)
+		users := store.NewMongoUserStore(db.Collection("users"), 0)
+		for _, g := range seeded {
+			if _, err := movies.RenameGenre(ctx, g.GenreID, g.GenreName); err != nil {
+				return err
+			}
+			if _, err := users.RenameGenre(ctx, g.GenreID, g.GenreName); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// genreUsage counts how often one ID and name pair is embedded.
+type genreUsage struct {
+	GenreID   int    `bson:"genre_id"`
+	GenreName string `bson:"genre_name"`
+	Count     int    `bson:"count"`
+}
+
+func embeddedGenres(ctx context.Context, col *mongo.Collection, field string) ([]genreUsage, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$unwind", Value: "$" + field}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "id", Value: "$" + field + ".genre_id"}, {Key: "name", Value: "$" + field + ".genre_name"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "genre_id", Value: "$_id.id"},
+			{Key: "genre_name", Value: "$_id.name"},
+			{Key: "count", Value: 1},
+		}}},
+	}
+	cur, err := col.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, fmt.Errorf("aggregate %s genres: %w", col.Name(), err)
+	}
+	var usage []genreUsage
+	if err := cur.All(ctx, &usage); err != nil {
+		return nil, fmt.Errorf("decode %s genres: %w", col.Name(), err)
+	}
+	return usage, nil
+}
+
+// catalogGenres returns existing followed by one genre for every other ID in
+// usage, ordered by ID. Each new genre takes its most used name, ties going
+// to the alphabetically first. A name already taken by another ID, ignoring
+// case, gets the ID appended so the catalog stays unique.
+func catalogGenres(existing []model.Genre, usage []genreUsage) []model.Genre {
+	known := make(map[int]bool, len(existing))
+	taken := make(map[string]bool, len(existing))
+	for _, g := range existing {
+		known[g.GenreID] = true
+		taken[strings.ToLower(g.GenreName)] = true
+	}
+
+	counts := make(map[int]map[string]int)
+	for _, u := range usage {
+		name := strings.TrimSpace(u.GenreName)
+		if u.GenreID == 0 || known[u.GenreID] || name == "" {
+			continue
+		}
+		if counts[u.GenreID] == nil {
+			counts[u.GenreID] = make(map[string]int)
+		}
+		counts[u.GenreID][name] += u.Count
+	}
+
+	ids := make([]int, 0, len(counts))
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	out := slices.Clone(existing)
+	for _, id := range ids {
+		var best string
+		for name, n := range counts[id] {
+			if best == "" || n > counts[id][best] || (n == counts[id][best] && name < best) {
+				best = name
+			}
+		}
+		if taken[strings.ToLower(best)] {
+			renamed := fmt.Sprintf("%s (%d)", best, id)
+			slog.Warn("genre name already taken, suffixing it", "genre_id", id, "name", best, "renamed", renamed)
+			best = renamed
+		}
+		taken[strings.ToLower(best)] = true
+		out = append(out, model.Genre{GenreID: id, GenreName: best})
+	}
+	return out
+}
@@ -0,0 +1,160 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/schema"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/store"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// defaultRankings is the scale the ranking_name validation tag used to
+// hardcode, numbered from best to worst. It is only seeded when no movie has
+// a ranking to derive the scale from.
+var defaultRankings = []model.Ranking{
+	{RankingValue: 1, RankingName: "Excellent"},
+	{RankingValue: 2, RankingName: "Good"},
+	{RankingValue: 3, RankingName: "Okay"},
+	{RankingValue: 4, RankingName: "Bad"},
+	{RankingValue: 5, RankingName: "Terrible"},
+}
+
+// seedRankings creates the rankings collection from the rankings movies
+// already carry, or with the default scale if none do. A collection that
+// already has entries is kept as configured. Movies whose ranking is not on
+// the scale are only counted: fixing them needs a decision per movie, and
+// their next write is rejected until then. The scale may have been edited
+// since, so it has no Down.
+var seedRankings = Migration{
+	Version: 3,
+	Name:    "seed_rankings",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		rankings, _ := schema.Lookup("rankings")
+		if _, err := schema.Reconcile(ctx, db, []schema.Collection{rankings}, schema.Options{}); err != nil {
+			return fmt.Errorf("ensure rankings indexes: %w", err)
+		}
+
+		scale := store.NewMongoRankingStore(db.Collection("rankings"), 0)
+		existing, err := scale.List(ctx)
+		if err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			usage, err := usedRankings(ctx, db.Collection("movies"))
+			if err != nil {
+				return err
+			}
+			existing = scaleRankings(usage)
+			source := "movies"
+			if len(existing) == 0 {
+				existing, source = defaultRankings, "default"
+			}
+			for _, r := range existing {
+				if err := scale.Insert(ctx, r); err != nil {
+					return fmt.Errorf("insert ranking %d: %w", r.RankingValue, err)
+				}
+			}
+			slog.InfoContext(ctx, "seeded rankings", "count", len(existing), "source", source)
+		}
+
+		off, err := db.Collection("movies").CountDocuments(ctx, offScaleFilter(existing))
+		if err != nil {
+			return fmt.Errorf("count movies off the ranking scale: %w", err)
+		}
+		if off > 0 {
+			slog.WarnContext(ctx, "movies have a ranking that is not on the scale", "count", off)
+		}
+		return nil
+	},
+}
+
+// rankingUsage counts how many movies carry one value and name pair.
+type rankingUsage struct {
+	RankingValue int    `bson:"ranking_value"`
+	RankingName  string `bson:"ranking_name"`
+	Count        int    `bson:"count"`
+}
+
+func usedRankings(ctx context.Context, col *mongo.Collection) ([]rankingUsage, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "value", Value: "$ranking.ranking_value"}, {Key: "name", Value: "$ranking.ranking_name"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "ranking_value", Value: "$_id.value"},
+			{Key: "ranking_name", Value: "$_id.name"},
+			{Key: "count", Value: 1},
+		}}},
+	}
+	cur, err := col.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, fmt.Errorf("aggregate movie rankings: %w", err)
+	}
+	var usage []rankingUsage
+	if err := cur.All(ctx, &usage); err != nil {
+		return nil, fmt.Errorf("decode movie rankings: %w", err)
+	}
+	return usage, nil
+}
+
+// scaleRankings returns one ranking for every value in usage, ordered by
+// value, named the way catalogGenres names genres: the most used name wins,
+// ties go to the alphabetically first, and a name already taken by another
+// value, ignoring case, gets the value appended.
+func scaleRankings(usage []rankingUsage) []model.Ranking {
+	counts := make(map[int]map[string]int)
+	for _, u := range usage {
+		name := strings.TrimSpace(u.RankingName)
+		if u.RankingValue == 0 || name == "" {
+			continue
+		}
+		if counts[u.RankingValue] == nil {
+			counts[u.RankingValue] = make(map[string]int)
+		}
+		counts[u.RankingValue][name] += u.Count
+	}
+
+	values := make([]int, 0, len(counts))
+	for v := range counts {
+		values = append(values, v)
+	}
+	slices.Sort(values)
+
+	taken := make(map[string]bool, len(values))
+	out := make([]model.Ranking, 0, len(values))
+	for _, v := range values {
+		var best string
+		for name, n := range counts[v] {
+			if best == "" || n > counts[v][best] || (n == counts[v][best] && name < best) {
+				best = name
+			}
+		}
+		if taken[strings.ToLower(best)] {
+			renamed := fmt.Sprintf("%s (%d)", best, v)
+			slog.Warn("ranking name already taken, suffixing it", "ranking_value", v, "name", best, "renamed", renamed)
+			best = renamed
+		}
+		taken[strings.ToLower(best)] = true
+		out = append(out, model.Ranking{RankingValue: v, RankingName: best})
+	}
+	return out
+}
+
+// offScaleFilter matches movies whose ranking value and name are not a pair
+// on the scale.
+func offScaleFilter(scale []model.Ranking) bson.M {
+	pairs := make(bson.A, len(scale))
+	for i, r := range scale {
+		pairs[i] = bson.M{"ranking.ranking_value": r.RankingValue, "ranking.ranking_name": r.RankingName}
+	}
+	return bson.M{"$nor": pairs}
+}
@@ -0,0 +1,65 @@
+package migrations
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryLedger keeps the ledger in process memory, for tests.
+type MemoryLedger struct {
+	mu        sync.Mutex
+	records   map[int]Record
+	owner     string
+	expiresAt time.Time
+}
+
+func NewMemoryLedger() *MemoryLedger {
+	return &MemoryLedger{records: make(map[int]Record)}
+}
+
+func (l *MemoryLedger) Applied(ctx context.Context) ([]Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	records := make([]Record, 0, len(l.records))
+	for _, r := range l.records {
+		records = append(records, r)
+	}
+	slices.SortFunc(records, func(a, b Record) int { return a.Version - b.Version })
+	return records, nil
+}
+
+func (l *MemoryLedger) Record(ctx context.Context, r Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records[r.Version] = r
+	return nil
+}
+
+func (l *MemoryLedger) Remove(ctx context.Context, version int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.records, version)
+	return nil
+}
+
+func (l *MemoryLedger) Lock(ctx context.Context, owner string, now, until time.Time) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.owner != "" && l.owner != owner && now.Before(l.expiresAt) {
+		return false, nil
+	}
+	l.owner, l.expiresAt = owner, until
+	return true, nil
+}
+
+func (l *MemoryLedger) Unlock(ctx context.Context, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.owner == owner {
+		l.owner = ""
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Package migrations applies ordered, versioned changes to the database and
+// records each applied version in the schema_migrations collection. A lock
+// document keeps two instances from migrating at the same time.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// ErrLocked is returned when another process holds the migration lock.
+var ErrLocked = errors.New("migrations are locked by another process")
+
+// Migration is one versioned change. Down is nil for a migration that cannot
+// be undone.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+	Down    func(ctx context.Context, db *mongo.Database) error
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Record is the ledger entry for an applied migration.
+type Record struct {
+	Version   int           `bson:"_id"`
+	Name      string        `bson:"name"`
+	AppliedAt time.Time     `bson:"applied_at"`
+	Duration  time.Duration `bson:"duration_ns"`
+}
+
+// Ledger stores the applied versions and the migration lock.
+// Implementations must be safe for concurrent use.
+type Ledger interface {
+	Applied(ctx context.Context) ([]Record, error)
+	Record(ctx context.Context, r Record) error
+	Remove(ctx context.Context, version int) error
+	// Lock takes the lock for owner until the given time, or extends it if
+	// owner already holds it. It returns false if another owner holds a lock
+	// that has not expired by now.
+	Lock(ctx context.Context, owner string, now, until time.Time) (bool, error)
+	// Unlock releases the lock if owner holds it.
+	Unlock(ctx context.Context, owner string) error
+}
@@ -0,0 +1,217 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// recorder builds migrations that log their runs instead of touching a
+// database.
+type recorder struct {
+	runs []string
+	fail map[string]bool
+}
+
+func (r *recorder) migration(version int, name string, reversible bool) Migration {
+	step := func(dir string) func(context.Context, *mongo.Database) error {
+		return func(ctx context.Context, db *mongo.Database) error {
+			if r.fail[dir+" "+name] {
+				return errors.New("boom")
+			}
+			r.runs = append(r.runs, dir+" "+name)
+			return nil
+		}
+	}
+	m := Migration{Version: version, Name: name, Up: step("up")}
+	if reversible {
+		m.Down = step("down")
+	}
+	return m
+}
+
+func newTestMigrator(t *testing.T, ledger Ledger, migs ...Migration) *Migrator {
+	t.Helper()
+	m, err := New(nil, ledger, migs)
+	if err != nil {
+		t.Fatalf("new migrator: %v", err)
+	}
+	return m
+}
+
+func versions(migs []Migration) []int {
+	out := make([]int, len(migs))
+	for i, m := range migs {
+		out[i] = m.Version
+	}
+	return out
+}
+
+func TestUpDownStatus(t *testing.T) {
+	ctx := context.Background()
+	r := &recorder{}
+	ledger := NewMemoryLedger()
+	m := newTestMigrator(t, ledger,
+		r.migration(3, "c", true),
+		r.migration(1, "a", true),
+		r.migration(2, "b", true),
+	)
+
+	applied, err := m.Up(ctx, 2)
+	if err != nil || !reflect.DeepEqual(versions(applied), []int{1, 2}) {
+		t.Fatalf("up to 2: %v, %v", versions(applied), err)
+	}
+	applied, err = m.Up(ctx, 0)
+	if err != nil || !reflect.DeepEqual(versions(applied), []int{3}) {
+		t.Fatalf("up: %v, %v", versions(applied), err)
+	}
+	if applied, _ := m.Up(ctx, 0); len(applied) != 0 {
+		t.Fatalf("second up must be a no-op, applied %v", versions(applied))
+	}
+
+	rolledBack, err := m.Down(ctx, 2)
+	if err != nil || !reflect.DeepEqual(versions(rolledBack), []int{3, 2}) {
+		t.Fatalf("down: %v, %v", versions(rolledBack), err)
+	}
+	want := []string{"up a", "up b", "up c", "down c", "down b"}
+	if !reflect.DeepEqual(r.runs, want) {
+		t.Fatalf("runs = %v, want %v", r.runs, want)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if len(statuses) != 3 || statuses[0].AppliedAt.IsZero() || !statuses[1].AppliedAt.IsZero() || !statuses[2].AppliedAt.IsZero() {
+		t.Fatalf("unexpected status %+v", statuses)
+	}
+}
+
+func TestFailedMigrationStaysPending(t *testing.T) {
+	ctx := context.Background()
+	r := &recorder{fail: map[string]bool{"up b": true}}
+	ledger := NewMemoryLedger()
+	m := newTestMigrator(t, ledger, r.migration(1, "a", true), r.migration(2, "b", true), r.migration(3, "c", true))
+
+	applied, err := m.Up(ctx, 0)
+	if err == nil || !reflect.DeepEqual(versions(applied), []int{1}) {
+		t.Fatalf("expected to stop after 1, got %v, %v", versions(applied), err)
+	}
+	records, _ := ledger.Applied(ctx)
+	if len(records) != 1 || records[0].Version != 1 {
+		t.Fatalf("only the successful migration may be recorded, got %+v", records)
+	}
+	if ok, _ := ledger.Lock(ctx, "someone-else", time.Now(), time.Now().Add(time.Minute)); !ok {
+		t.Fatal("the lock must be released after a failure")
+	}
+}
+
+func TestDownRefusesIrreversibleAndUnknown(t *testing.T) {
+	ctx := context.Background()
+	r := &recorder{}
+	ledger := NewMemoryLedger()
+	m := newTestMigrator(t, ledger, r.migration(1, "a", false))
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if _, err := m.Down(ctx, 1); err == nil {
+		t.Fatal("expected irreversible migration to be refused")
+	}
+
+	_ = ledger.Record(ctx, Record{Version: 7, Name: "from-the-future"})
+	statuses, _ := m.Status(ctx)
+	if last := statuses[len(statuses)-1]; !last.Unknown || last.Version != 7 {
+		t.Fatalf("expected unknown applied version in status, got %+v", statuses)
+	}
+	if _, err := m.Down(ctx, 1); err == nil {
+		t.Fatal("expected unknown migration to be refused")
+	}
+}
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+	r := &recorder{}
+	ledger := NewMemoryLedger()
+	m := newTestMigrator(t, ledger, r.migration(1, "a", true))
+
+	now := time.Now()
+	if ok, _ := ledger.Lock(ctx, "other", now, now.Add(time.Hour)); !ok {
+		t.Fatal("expected to take a free lock")
+	}
+	if _, err := m.Up(ctx, 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if len(r.runs) != 0 {
+		t.Fatalf("nothing may run without the lock, ran %v", r.runs)
+	}
+
+	m.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("expected an expired lock to be taken over, got %v", err)
+	}
+}
+
+func TestNewValidates(t *testing.T) {
+	r := &recorder{}
+	for _, migs := range [][]Migration{
+		{r.migration(1, "a", true), r.migration(1, "b", true)},
+		{r.migration(0, "a", true)},
+		{{Version: 1, Name: "a"}},
+	} {
+		if _, err := New(nil, NewMemoryLedger(), migs); err == nil {
+			t.Errorf("expected %v to be rejected", migs)
+		}
+	}
+	if _, err := New(nil, NewMemoryLedger(), All()); err != nil {
+		t.Fatalf("registered migrations are invalid: %v", err)
+	}
+}
+
+func TestCatalogGenres(t *testing.T) {
+	existing := []model.Genre{{GenreID: 1, GenreName: "Drama"}}
+	usage := []genreUsage{
+		{GenreID: 1, GenreName: "drama", Count: 9},
+		{GenreID: 3, GenreName: "Comedy", Count: 2},
+		{GenreID: 3, GenreName: "comedy ", Count: 1},
+		{GenreID: 3, GenreName: "comedy", Count: 2},
+		{GenreID: 2, GenreName: "DRAMA", Count: 1},
+		{GenreID: 4, GenreName: " ", Count: 5},
+		{GenreID: 0, GenreName: "Horror", Count: 5},
+	}
+	want := []model.Genre{
+		{GenreID: 1, GenreName: "Drama"},
+		{GenreID: 2, GenreName: "DRAMA (2)"},
+		{GenreID: 3, GenreName: "comedy"},
+	}
+	if got := catalogGenres(existing, usage); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestScaleRankings(t *testing.T) {
+	usage := []rankingUsage{
+		{RankingValue: 2, RankingName: "Fine", Count: 3},
+		{RankingValue: 2, RankingName: "fine ", Count: 1},
+		{RankingValue: 1, RankingName: "Great", Count: 4},
+		{RankingValue: 1, RankingName: "Superb", Count: 1},
+		{RankingValue: 3, RankingName: "great", Count: 2},
+		{RankingValue: 4, RankingName: "", Count: 9},
+		{RankingValue: 0, RankingName: "Unranked", Count: 9},
+	}
+	want := []model.Ranking{
+		{RankingValue: 1, RankingName: "Great"},
+		{RankingValue: 2, RankingName: "Fine"},
+		{RankingValue: 3, RankingName: "great (3)"},
+	}
+	if got := scaleRankings(usage); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if got := scaleRankings(nil); len(got) != 0 {
+		t.Fatalf("expected no rankings without usage, got %+v", got)
+	}
+}
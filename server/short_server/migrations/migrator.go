@@ -0,0 +1,220 @@
+package migrations
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// defaultLockTTL is how long the lock outlives a crashed migrator. A running
+// migrator renews it every third of that.
+const defaultLockTTL = 5 * time.Minute
+
+// Status describes one migration. AppliedAt is zero while it is pending.
+// Unknown marks an applied version this binary has no migration for, e.g.
+// after rolling back to an older release.
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+	Unknown   bool
+}
+
+// Migrator applies and rolls back migrations under the ledger's lock.
+type Migrator struct {
+	db         *mongo.Database
+	ledger     Ledger
+	migrations []Migration
+	owner      string
+	lockTTL    time.Duration
+	now        func() time.Time
+}
+
+// New returns a Migrator for migrations, which must have distinct positive
+// versions and an Up function; they are applied in version order.
+func New(db *mongo.Database, ledger Ledger, migrations []Migration) (*Migrator, error) {
+	sorted := slices.Clone(migrations)
+	slices.SortFunc(sorted, func(a, b Migration) int { return a.Version - b.Version })
+	for i, m := range sorted {
+		if m.Version <= 0 || m.Name == "" || m.Up == nil {
+			return nil, fmt.Errorf("migration %s: needs a positive version, a name and an Up function", m)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", m.Version, sorted[i-1], m)
+		}
+	}
+	return &Migrator{
+		db:         db,
+		ledger:     ledger,
+		migrations: sorted,
+		owner:      lockOwner(),
+		lockTTL:    defaultLockTTL,
+		now:        time.Now,
+	}, nil
+}
+
+// Status lists every known migration in order, followed by any applied
+// versions the binary does not know.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if r, ok := applied[mig.Version]; ok {
+			s.AppliedAt = r.AppliedAt
+			delete(applied, mig.Version)
+		}
+		out = append(out, s)
+	}
+	for _, r := range applied {
+		out = append(out, Status{Version: r.Version, Name: r.Name, AppliedAt: r.AppliedAt, Unknown: true})
+	}
+	slices.SortStableFunc(out[len(m.migrations):], func(a, b Status) int { return a.Version - b.Version })
+	return out, nil
+}
+
+// Up applies every pending migration up to and including version to, or all
+// of them when to is 0, and returns those it applied. It stops at the first
+// failure, leaving the failed migration pending.
+func (m *Migrator) Up(ctx context.Context, to int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(ctx context.Context) error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if to > 0 && mig.Version > to {
+				break
+			}
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			start := m.now()
+			if err := mig.Up(ctx, m.db); err != nil {
+				return fmt.Errorf("apply %s: %w", mig, err)
+			}
+			if err := m.ledger.Record(ctx, Record{
+				Version: mig.Version, Name: mig.Name, AppliedAt: start.UTC(), Duration: m.now().Sub(start),
+			}); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the latest steps applied migrations, newest first, and
+// returns those it rolled back. It refuses to roll back a migration without
+// a Down function or one the binary does not know.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(ctx context.Context) error {
+		records, err := m.ledger.Applied(ctx)
+		if err != nil {
+			return err
+		}
+		slices.Reverse(records)
+		for _, r := range records[:min(steps, len(records))] {
+			i := slices.IndexFunc(m.migrations, func(mig Migration) bool { return mig.Version == r.Version })
+			if i < 0 {
+				return fmt.Errorf("roll back %04d_%s: unknown to this binary", r.Version, r.Name)
+			}
+			mig := m.migrations[i]
+			if mig.Down == nil {
+				return fmt.Errorf("roll back %s: migration is irreversible", mig)
+			}
+			if err := mig.Down(ctx, m.db); err != nil {
+				return fmt.Errorf("roll back %s: %w", mig, err)
+			}
+			if err := m.ledger.Remove(ctx, mig.Version); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]Record, error) {
+	records, err := m.ledger.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int]Record, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// locked runs fn while holding the lock, renewing it in the background. If
+// a renewal fails, fn's context is cancelled so the run stops before another
+// process can take over.
+func (m *Migrator) locked(ctx context.Context, fn func(context.Context) error) error {
+	now := m.now()
+	ok, err := m.ledger.Lock(ctx, m.owner, now, now.Add(m.lockTTL))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLocked
+	}
+	defer func() {
+		if err := m.ledger.Unlock(context.WithoutCancel(ctx), m.owner); err != nil {
+			slog.ErrorContext(ctx, "release migration lock failed", "op", "Migrator.locked", "err", err)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	var renewErr error
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		ticker := time.NewTicker(m.lockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				now := m.now()
+				ok, err := m.ledger.Lock(ctx, m.owner, now, now.Add(m.lockTTL))
+				if err == nil && !ok {
+					err = ErrLocked
+				}
+				if err != nil && ctx.Err() == nil {
+					renewErr = fmt.Errorf("renew migration lock: %w", err)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	err = fn(ctx)
+	cancel()
+	<-renewed
+	return errors.Join(err, renewErr)
+}
+
+func lockOwner() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s/%d/%s", host, os.Getpid(), hex.EncodeToString(b))
+}
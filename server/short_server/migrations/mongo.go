@@ -0,0 +1,84 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	ledgerCollection = "schema_migrations"
+	// lockID is the _id of the lock document, kept in the ledger collection
+	// apart from the numeric version records.
+	lockID = "lock"
+)
+
+// MongoLedger keeps the ledger in the schema_migrations collection.
+type MongoLedger struct {
+	col *mongo.Collection
+}
+
+func NewMongoLedger(db *mongo.Database) *MongoLedger {
+	return &MongoLedger{col: db.Collection(ledgerCollection)}
+}
+
+func (l *MongoLedger) Applied(ctx context.Context) ([]Record, error) {
+	cur, err := l.col.Find(ctx,
+		bson.M{"_id": bson.M{"$type": "number"}},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("find applied migrations: %w", err)
+	}
+	records := make([]Record, 0)
+	if err := cur.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("decode applied migrations: %w", err)
+	}
+	return records, nil
+}
+
+func (l *MongoLedger) Record(ctx context.Context, r Record) error {
+	if _, err := l.col.InsertOne(ctx, r); err != nil {
+		return fmt.Errorf("record migration %d: %w", r.Version, err)
+	}
+	return nil
+}
+
+func (l *MongoLedger) Remove(ctx context.Context, version int) error {
+	if _, err := l.col.DeleteOne(ctx, bson.M{"_id": version}); err != nil {
+		return fmt.Errorf("remove migration %d: %w", version, err)
+	}
+	return nil
+}
+
+func (l *MongoLedger) Lock(ctx context.Context, owner string, now, until time.Time) (bool, error) {
+	// The upsert matches only a lock that is expired or already ours; when
+	// another owner holds it, the upsert inserts a second "lock" _id and
+	// fails with a duplicate key error.
+	_, err := l.col.UpdateOne(ctx,
+		bson.M{"_id": lockID, "$or": bson.A{
+			bson.M{"owner": owner},
+			bson.M{"expires_at": bson.M{"$lte": now}},
+		}},
+		bson.M{"$set": bson.M{"owner": owner, "expires_at": until, "locked_at": now}},
+		options.UpdateOne().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("take migration lock: %w", err)
+	}
+	return true, nil
+}
+
+func (l *MongoLedger) Unlock(ctx context.Context, owner string) error {
+	if _, err := l.col.DeleteOne(ctx, bson.M{"_id": lockID, "owner": owner}); err != nil {
+		return fmt.Errorf("release migration lock: %w", err)
+	}
+	return nil
+}
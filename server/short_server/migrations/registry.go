@@ -0,0 +1,11 @@
+package migrations
+
+// All returns every migration. Add new ones here with the next version
+// number, in a file named after it.
+func All() []Migration {
+	return []Migration{
+		dedupeUsers,
+		seedGenres,
+		seedRankings,
+	}
+}
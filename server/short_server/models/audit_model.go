@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	AuditActionChangeRole   = "user.change_role"
+	AuditActionChangeStatus = "user.change_status"
+	AuditActionDeleteUser   = "user.delete"
+)
+
+// AuditEntry records an administrative action taken on a user.
+type AuditEntry struct {
+	ID           bson.ObjectID  `json:"_id,omitempty" bson:"_id,omitempty"`
+	Action       string         `json:"action" bson:"action"`
+	TargetUserID string         `json:"target_user_id" bson:"target_user_id"`
+	ActorID      string         `json:"actor_id" bson:"actor_id"`
+	ActorEmail   string         `json:"actor_email" bson:"actor_email"`
+	Details      map[string]any `json:"details,omitempty" bson:"details,omitempty"`
+	At           time.Time      `json:"at" bson:"at"`
+}
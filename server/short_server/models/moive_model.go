@@ -7,9 +7,12 @@ type Genre struct {
 	GenreName string `bson:"genre_name" json:"genre_name" validate:"required,min=2,max=100"`
 }
 
+// Ranking is one step of the ranking scale. The scale itself is reference
+// data in the rankings collection, and a movie's ranking must be one of its
+// entries.
 type Ranking struct {
 	RankingValue int    `bson:"ranking_value" json:"ranking_value" validate:"required"`
-	RankingName  string `bson:"ranking_name" json:"ranking_name" validate:"oneof=Excellent Good Okay Bad Terrible"`
+	RankingName  string `bson:"ranking_name" json:"ranking_name" validate:"required,min=2,max=100"`
 }
 
 type Movie struct {
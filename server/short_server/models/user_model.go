@@ -6,17 +6,32 @@ import (
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
+const (
+	RoleAdmin = "ADMIN"
+	RoleUser  = "USER"
+
+	UserStatusActive   = "ACTIVE"
+	UserStatusDisabled = "DISABLED"
+)
+
 type User struct {
 	ID              bson.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
 	UserID          string        `json:"user_id" bson:"user_id"`
 	FirstName       string        `json:"first_name" bson:"first_name" validate:"required,min=2,max=100"`
 	LastName        string        `json:"last_name" bson:"last_name" validate:"required,min=2,max=100"`
 	Email           string        `json:"email" bson:"email" validate:"required,email"`
-	Password        string        `json:"password" bson:"password" validate:"required,min=6"`
+	Password        string        `json:"-" bson:"password" validate:"required,min=6"`
 	Role            string        `json:"role" bson:"role" validate:"required,oneof=ADMIN USER"`
+	Status          string        `json:"status,omitempty" bson:"status,omitempty" validate:"omitempty,oneof=ACTIVE DISABLED"`
 	CreatedAt       time.Time     `json:"created_at" bson:"created_at"`
 	UpdatedAt       time.Time     `json:"updated_at" bson:"updated_at"`
-	Token           string        `json:"token" bson:"token"`
-	RefreshToken    string        `json:"refresh_token" bson:"refresh_token"`
+	Token           string        `json:"-" bson:"token"`
+	RefreshToken    string        `json:"-" bson:"refresh_token"`
 	FavouriteGenres []Genre       `json:"favourite_genres" bson:"favourite_genres" validate:"required,dive"`
 }
+
+// IsDisabled reports whether an admin has disabled the account. Users stored
+// before statuses existed have no status and count as active.
+func (u User) IsDisabled() bool {
+	return u.Status == UserStatusDisabled
+}
@@ -0,0 +1,137 @@
+// Package ratelimit throttles huma operations with per-client token buckets
+// and reports the outcome in the IETF RateLimit header fields.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests per Period on average with bursts of up to Burst
+// requests. A zero Burst means Requests.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// Per returns a Limit of n requests per period.
+func Per(n int, period time.Duration) Limit {
+	return Limit{Requests: n, Period: period}
+}
+
+func (l Limit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.Requests)
+}
+
+// rate is the refill rate in tokens per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+func (l Limit) valid() bool {
+	return l.Requests > 0 && l.Period > 0 && l.Burst >= 0
+}
+
+// String renders the limit in ParseLimit syntax.
+func (l Limit) String() string {
+	s := strconv.Itoa(l.Requests) + "/" + l.Period.String()
+	if l.Burst > 0 {
+		s += "+" + strconv.Itoa(l.Burst)
+	}
+	return s
+}
+
+// ParseLimit parses "<requests>/<period>[+<burst>]", e.g. "5/1m" or
+// "100/1h+20". The period uses time.ParseDuration syntax; a bare unit such
+// as "m" means one of it.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	rest, burstStr, hasBurst := strings.Cut(s, "+")
+	reqStr, periodStr, ok := strings.Cut(rest, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q: want <requests>/<period>, e.g. 5/1m", s)
+	}
+
+	var l Limit
+	var err error
+	if l.Requests, err = strconv.Atoi(strings.TrimSpace(reqStr)); err != nil {
+		return Limit{}, fmt.Errorf("rate limit %q: invalid request count", s)
+	}
+	periodStr = strings.TrimSpace(periodStr)
+	if periodStr != "" && (periodStr[0] < '0' || periodStr[0] > '9') {
+		periodStr = "1" + periodStr
+	}
+	if l.Period, err = time.ParseDuration(periodStr); err != nil {
+		return Limit{}, fmt.Errorf("rate limit %q: invalid period", s)
+	}
+	if hasBurst {
+		if l.Burst, err = strconv.Atoi(strings.TrimSpace(burstStr)); err != nil {
+			return Limit{}, fmt.Errorf("rate limit %q: invalid burst", s)
+		}
+	}
+	if !l.valid() {
+		return Limit{}, fmt.Errorf("rate limit %q: requests and period must be positive", s)
+	}
+	return l, nil
+}
+
+// Result describes the state of a bucket after a Take.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request would be allowed; zero
+	// when Allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets. Implementations must be safe for concurrent
+// use; a shared implementation (e.g. Redis) lets several instances enforce
+// one limit.
+type Store interface {
+	// Take removes one token from the bucket for key, refilled according to
+	// limit as of now.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// bucket is the token bucket state shared by store implementations.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills b for the time since its last use and tries to spend a token.
+func (b *bucket) take(limit Limit, now time.Time) Result {
+	capacity, rate := limit.capacity(), limit.rate()
+	if b.last.IsZero() {
+		b.tokens = capacity
+	} else if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*rate)
+	}
+	b.last = now
+
+	res := Result{Limit: int(capacity)}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	res.Remaining = int(math.Floor(b.tokens))
+	res.Reset = seconds((capacity - b.tokens) / rate)
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,153 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/auth"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/config"
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// MetadataKey holds an operation's built-in Limit in huma.Operation.Metadata.
+const MetadataKey = "rateLimit"
+
+// Policy returns operation metadata declaring the operation's limit. The
+// rate_limit.operations setting still overrides it.
+func Policy(l Limit) map[string]any {
+	return map[string]any{MetadataKey: l}
+}
+
+// Limiter resolves the limit for each operation and enforces it.
+type Limiter struct {
+	store      Store
+	enabled    bool
+	def        Limit
+	overrides  map[string]Limit
+	trustProxy bool
+	proxies    int
+	now        func() time.Time
+}
+
+// New builds a Limiter from cfg, reporting malformed limits so they fail
+// startup rather than being ignored.
+func New(cfg config.RateLimitConfig, store Store) (*Limiter, error) {
+	l := &Limiter{
+		store:      store,
+		enabled:    cfg.Enabled,
+		overrides:  make(map[string]Limit),
+		trustProxy: cfg.TrustProxyHeaders,
+		proxies:    cfg.TrustedProxies,
+		now:        time.Now,
+	}
+	if l.trustProxy && l.proxies < 1 {
+		return nil, fmt.Errorf("rate_limit.trusted_proxies: must be at least 1 when trust_proxy_headers is set, got %d", l.proxies)
+	}
+	def, err := ParseLimit(cfg.Default)
+	if err != nil {
+		return nil, fmt.Errorf("rate_limit.default: %w", err)
+	}
+	l.def = def
+
+	for _, entry := range strings.Split(cfg.Operations, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		op, spec, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("rate_limit.operations: entry %q: want <operation-id>=<limit>", entry)
+		}
+		limit, err := ParseLimit(spec)
+		if err != nil {
+			return nil, fmt.Errorf("rate_limit.operations: %s: %w", strings.TrimSpace(op), err)
+		}
+		l.overrides[strings.TrimSpace(op)] = limit
+	}
+	return l, nil
+}
+
+// LimitFor returns the limit applied to op.
+func (l *Limiter) LimitFor(op *huma.Operation) Limit {
+	if limit, ok := l.overrides[op.OperationID]; ok {
+		return limit
+	}
+	if limit, ok := op.Metadata[MetadataKey].(Limit); ok {
+		return limit
+	}
+	return l.def
+}
+
+// Middleware throttles each operation per caller: authenticated requests
+// are keyed by user ID, anonymous ones by client IP. Register it after the
+// auth middleware so the identity is known. Every response carries the
+// RateLimit-Policy, RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset
+// fields; throttled requests get 429 with Retry-After. If the store fails,
+// the request is let through.
+func (l *Limiter) Middleware(api huma.API) func(huma.Context, func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		if !l.enabled {
+			next(ctx)
+			return
+		}
+		op := ctx.Operation()
+		limit := l.LimitFor(op)
+
+		res, err := l.store.Take(ctx.Context(), op.OperationID+"|"+l.clientKey(ctx), limit, l.now())
+		if err != nil {
+			slog.ErrorContext(ctx.Context(), "rate limit store failed", "op", op.OperationID, "err", err)
+			next(ctx)
+			return
+		}
+
+		ctx.SetHeader("RateLimit-Policy", fmt.Sprintf("%d;w=%d", int(limit.capacity()), ceilSeconds(limit.Period)))
+		ctx.SetHeader("RateLimit-Limit", strconv.Itoa(res.Limit))
+		ctx.SetHeader("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		ctx.SetHeader("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		if !res.Allowed {
+			ctx.SetHeader("Retry-After", strconv.Itoa(max(ceilSeconds(res.RetryAfter), 1)))
+			_ = huma.WriteErr(api, ctx, http.StatusTooManyRequests, "rate limit exceeded, retry later")
+			return
+		}
+		next(ctx)
+	}
+}
+
+func (l *Limiter) clientKey(ctx huma.Context) string {
+	if id, ok := auth.IdentityFromContext(ctx.Context()); ok {
+		return "user:" + id.UserID
+	}
+	return "ip:" + l.clientIP(ctx)
+}
+
+// clientIP reads X-Forwarded-For from the right, since each trusted proxy
+// appends the address it received the request from. The entry l.proxies
+// places from the end is the client; entries left of it are whatever the
+// client put there. A header too short to have passed every proxy falls
+// back to the connection address.
+func (l *Limiter) clientIP(ctx huma.Context) string {
+	if l.trustProxy {
+		if fwd := ctx.Header("X-Forwarded-For"); fwd != "" {
+			hops := strings.Split(fwd, ",")
+			if len(hops) >= l.proxies {
+				if ip := strings.TrimSpace(hops[len(hops)-l.proxies]); ip != "" {
+					return ip
+				}
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(ctx.RemoteAddr())
+	if err != nil {
+		return ctx.RemoteAddr()
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
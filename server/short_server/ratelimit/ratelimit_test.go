@@ -0,0 +1,197 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/auth"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/config"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/humatest"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{in: "5/1m", want: Limit{Requests: 5, Period: time.Minute}},
+		{in: "100/h+20", want: Limit{Requests: 100, Period: time.Hour, Burst: 20}},
+		{in: " 3 / 10s ", want: Limit{Requests: 3, Period: 10 * time.Second}},
+		{in: "5", wantErr: true},
+		{in: "x/1m", wantErr: true},
+		{in: "5/soon", wantErr: true},
+		{in: "0/1m", wantErr: true},
+		{in: "5/1m+x", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, %v; want %+v, err=%v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestMemoryStoreTokenBucket(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+	limit := Per(2, 10*time.Second)
+	now := time.Unix(1000, 0)
+
+	for i, wantRemaining := range []int{1, 0} {
+		res, _ := s.Take(ctx, "k", limit, now)
+		if !res.Allowed || res.Remaining != wantRemaining || res.Limit != 2 {
+			t.Fatalf("take %d: unexpected result %+v", i, res)
+		}
+	}
+	res, _ := s.Take(ctx, "k", limit, now)
+	if res.Allowed || res.RetryAfter != 5*time.Second || res.Reset != 10*time.Second {
+		t.Fatalf("expected throttled result with 5s retry, got %+v", res)
+	}
+
+	// One token refills every 5s.
+	res, _ = s.Take(ctx, "k", limit, now.Add(5*time.Second))
+	if !res.Allowed {
+		t.Fatalf("expected a refilled token, got %+v", res)
+	}
+	if res, _ := s.Take(ctx, "other", limit, now); !res.Allowed {
+		t.Fatal("buckets must be independent per key")
+	}
+
+	s.sweep(now.Add(time.Hour))
+	if s.Len() != 0 {
+		t.Fatalf("expected idle buckets to be swept, %d left", s.Len())
+	}
+}
+
+func newLimitedAPI(t *testing.T, cfg config.RateLimitConfig) (humatest.TestAPI, *Limiter) {
+	t.Helper()
+	tm, _ := auth.NewTokenManager("test-secret", time.Minute, time.Hour)
+	limiter, err := New(cfg, NewMemoryStore())
+	if err != nil {
+		t.Fatalf("new limiter: %v", err)
+	}
+	limiter.now = func() time.Time { return time.Unix(1000, 0) }
+
+	_, api := humatest.New(t)
+	api.UseMiddleware(auth.Middleware(api, tm, nil), limiter.Middleware(api))
+	noop := func(ctx context.Context, in *struct{}) (*struct{}, error) { return nil, nil }
+	huma.Register(api, huma.Operation{
+		OperationID: "login", Method: http.MethodPost, Path: "/login",
+		Metadata: Policy(Per(2, time.Minute)),
+	}, noop)
+	huma.Register(api, huma.Operation{
+		OperationID: "list", Method: http.MethodGet, Path: "/list",
+	}, noop)
+	return api, limiter
+}
+
+func TestMiddleware(t *testing.T) {
+	cfg := config.Default().RateLimit
+	api, _ := newLimitedAPI(t, cfg)
+
+	for i := range 2 {
+		resp := api.Post("/login")
+		if resp.Code != http.StatusNoContent {
+			t.Fatalf("request %d: expected 204, got %d", i, resp.Code)
+		}
+		if got := resp.Header().Get("RateLimit-Remaining"); got != []string{"1", "0"}[i] {
+			t.Fatalf("request %d: RateLimit-Remaining = %q", i, got)
+		}
+	}
+
+	resp := api.Post("/login")
+	if resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", resp.Code)
+	}
+	for header, want := range map[string]string{
+		"Retry-After":         "30",
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "60",
+		"RateLimit-Policy":    "2;w=60",
+	} {
+		if got := resp.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	if resp := api.Get("/list"); resp.Code != http.StatusNoContent || resp.Header().Get("RateLimit-Limit") != "120" {
+		t.Fatalf("other operations use their own bucket and the default limit, got %d %v", resp.Code, resp.Header())
+	}
+}
+
+func TestMiddlewareKeysByUser(t *testing.T) {
+	cfg := config.Default().RateLimit
+	api, _ := newLimitedAPI(t, cfg)
+	tm, _ := auth.NewTokenManager("test-secret", time.Minute, time.Hour)
+	pair, _ := tm.Issue("u1", "u@x.com", auth.RoleUser)
+
+	api.Post("/login")
+	api.Post("/login")
+	if resp := api.Post("/login", "Authorization: Bearer "+pair.AccessToken); resp.Code != http.StatusNoContent {
+		t.Fatalf("authenticated user must not share the anonymous IP bucket, got %d", resp.Code)
+	}
+}
+
+func TestMiddlewareKeysByForwardedFor(t *testing.T) {
+	cfg := config.Default().RateLimit
+	cfg.TrustProxyHeaders = true
+	api, _ := newLimitedAPI(t, cfg)
+
+	api.Post("/login", "X-Forwarded-For: 203.0.113.7")
+	api.Post("/login", "X-Forwarded-For: 203.0.113.7")
+	if resp := api.Post("/login", "X-Forwarded-For: 198.51.100.1, 203.0.113.7"); resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("a spoofed leftmost entry must not pick a fresh bucket, got %d", resp.Code)
+	}
+	if resp := api.Post("/login", "X-Forwarded-For: 203.0.113.7, 198.51.100.1"); resp.Code != http.StatusNoContent {
+		t.Fatalf("expected the proxy-appended entry to key the bucket, got %d", resp.Code)
+	}
+
+	cfg.TrustedProxies = 2
+	api, _ = newLimitedAPI(t, cfg)
+	api.Post("/login", "X-Forwarded-For: 203.0.113.7, 10.0.0.1")
+	api.Post("/login", "X-Forwarded-For: 203.0.113.7, 10.0.0.2")
+	if resp := api.Post("/login", "X-Forwarded-For: 198.51.100.1, 203.0.113.7, 10.0.0.1"); resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the client two hops from the right to be throttled, got %d", resp.Code)
+	}
+}
+
+func TestConfigOverridesAndDisable(t *testing.T) {
+	cfg := config.Default().RateLimit
+	cfg.Operations = "login=1/1h"
+	api, limiter := newLimitedAPI(t, cfg)
+	api.Post("/login")
+	if resp := api.Post("/login"); resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected config override to apply, got %d", resp.Code)
+	}
+	if got := limiter.LimitFor(&huma.Operation{OperationID: "login"}); got != Per(1, time.Hour) {
+		t.Fatalf("unexpected limit %v", got)
+	}
+
+	cfg.Enabled = false
+	api, _ = newLimitedAPI(t, cfg)
+	for range 3 {
+		if resp := api.Post("/login"); resp.Code != http.StatusNoContent {
+			t.Fatalf("disabled limiter must not throttle, got %d", resp.Code)
+		}
+	}
+}
+
+func TestNewRejectsMalformedConfig(t *testing.T) {
+	for _, cfg := range []config.RateLimitConfig{
+		{Default: "lots"},
+		{Default: "10/1m", Operations: "login"},
+		{Default: "10/1m", Operations: "login=5/forever"},
+		{Default: "10/1m", TrustProxyHeaders: true},
+	} {
+		_, err := New(cfg, NewMemoryStore())
+		if err == nil || !strings.Contains(err.Error(), "rate_limit.") {
+			t.Errorf("config %+v: expected descriptive error, got %v", cfg, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Package schema declares every collection's indexes and validators in code
+// and reconciles a database with them, at startup or from cmd/ensure_indexes.
+package schema
+
+import (
+	"time"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Collection is the declared shape of one collection.
+type Collection struct {
+	Name    string
+	Indexes []Index
+	// Model, when set, is the struct whose bson and validate tags generate
+	// the collection's $jsonSchema validator.
+	Model any
+}
+
+// Index is a declared index. Name identifies it: an existing index with the
+// same name but a different definition is dropped and recreated.
+type Index struct {
+	Name   string
+	Keys   bson.D
+	Unique bool
+	// Partial limits the index to documents matching the filter.
+	Partial bson.D
+	// ExpireAfter makes a TTL index on a date field; nil means no TTL.
+	ExpireAfter *time.Duration
+	// Weights sets the relative weight of text index fields; unlisted fields
+	// weigh 1.
+	Weights bson.D
+	// Collation makes string comparisons in the index locale-aware; only its
+	// locale and strength are reconciled.
+	Collation *options.Collation
+}
+
+func expireAfter(d time.Duration) *time.Duration {
+	return &d
+}
+
+// caseInsensitive compares strings ignoring case but not diacritics.
+var caseInsensitive = &options.Collation{Locale: "en", Strength: 2}
+
+// Collections returns the declarations for every collection the service uses.
+func Collections() []Collection {
+	return []Collection{
+		{
+			Name:  "users",
+			Model: model.User{},
+			Indexes: []Index{
+				{
+					Name:   "users_email_unique",
+					Keys:   bson.D{{Key: "email", Value: 1}},
+					Unique: true,
+					// Legacy documents without an email must not collide.
+					Partial: bson.D{{Key: "email", Value: bson.D{{Key: "$type", Value: "string"}, {Key: "$gt", Value: ""}}}},
+				},
+				{Name: "users_role_status", Keys: bson.D{{Key: "role", Value: 1}, {Key: "status", Value: 1}}},
+				{Name: "users_favourite_genre_id", Keys: bson.D{{Key: "favourite_genres.genre_id", Value: 1}}},
+			},
+		},
+		{
+			Name:  "movies",
+			Model: model.Movie{},
+			Indexes: []Index{
+				{Name: "imdb_id_unique", Keys: bson.D{{Key: "imdb_id", Value: 1}}, Unique: true},
+				{Name: "movies_genre_id", Keys: bson.D{{Key: "genre.genre_id", Value: 1}, {Key: "_id", Value: 1}}},
+				{Name: "movies_ranking", Keys: bson.D{{Key: "ranking.ranking_value", Value: 1}, {Key: "_id", Value: 1}}},
+				{Name: "movies_title", Keys: bson.D{{Key: "title", Value: 1}, {Key: "_id", Value: 1}}},
+				{
+					Name:    "movies_text",
+					Keys:    bson.D{{Key: "title", Value: "text"}, {Key: "admin_review", Value: "text"}},
+					Weights: bson.D{{Key: "title", Value: 10}},
+				},
+			},
+		},
+		{
+			Name:  "genres",
+			Model: model.Genre{},
+			Indexes: []Index{
+				{Name: "genres_id_unique", Keys: bson.D{{Key: "genre_id", Value: 1}}, Unique: true},
+				{Name: "genres_name_unique", Keys: bson.D{{Key: "genre_name", Value: 1}}, Unique: true, Collation: caseInsensitive},
+			},
+		},
+		{
+			Name:  "rankings",
+			Model: model.Ranking{},
+			Indexes: []Index{
+				{Name: "rankings_value_unique", Keys: bson.D{{Key: "ranking_value", Value: 1}}, Unique: true},
+				{Name: "rankings_name_unique", Keys: bson.D{{Key: "ranking_name", Value: 1}}, Unique: true, Collation: caseInsensitive},
+			},
+		},
+		{
+			Name:  "user_audit",
+			Model: model.AuditEntry{},
+			Indexes: []Index{
+				{Name: "user_audit_target_at", Keys: bson.D{{Key: "target_user_id", Value: 1}, {Key: "at", Value: -1}}},
+			},
+		},
+		{
+			Name: "idempotency_keys",
+			Indexes: []Index{
+				{Name: "expires_at_ttl", Keys: bson.D{{Key: "expires_at", Value: 1}}, ExpireAfter: expireAfter(0)},
+			},
+		},
+	}
+}
+
+// Lookup returns the declaration of the named collection.
+func Lookup(name string) (Collection, bool) {
+	for _, c := range Collections() {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Collection{}, false
+}
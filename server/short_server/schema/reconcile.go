@@ -0,0 +1,358 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/config"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Change actions reported by Reconcile.
+const (
+	ActionCreate   = "create"
+	ActionRecreate = "recreate"
+	ActionDrop     = "drop"
+	// ActionKeep marks an undeclared index left in place because
+	// Options.DropUnknown is off.
+	ActionKeep      = "keep-unknown"
+	ActionValidator = "set-validator"
+	// ActionSkipDuplicates marks a unique index left unbuilt because
+	// documents share its key and Options.SkipDuplicates is on.
+	ActionSkipDuplicates = "skip-duplicates"
+)
+
+// Change is one difference between the database and the declarations.
+type Change struct {
+	Collection string
+	// Index is empty for validator changes.
+	Index  string
+	Action string
+}
+
+func (c Change) String() string {
+	if c.Index == "" {
+		return fmt.Sprintf("%s %s", c.Action, c.Collection)
+	}
+	return fmt.Sprintf("%s %s.%s", c.Action, c.Collection, c.Index)
+}
+
+// Options controls Reconcile.
+type Options struct {
+	// Validation is one of the config.SchemaValidation modes. With off,
+	// validators are neither installed nor removed.
+	Validation string
+	// DropUnknown drops indexes that are not declared, except _id_.
+	DropUnknown bool
+	// DryRun reports the changes without making them.
+	DryRun bool
+	// SkipDuplicates leaves a unique index unbuilt, reporting it with
+	// ActionSkipDuplicates, when documents share its key, instead of failing.
+	SkipDuplicates bool
+}
+
+// duplicatesHint tells the operator how to unblock a unique index.
+const duplicatesHint = "documents share its key; remove the duplicates (cmd/dedupe_users does this for users) and reconcile again"
+
+// Reconcile makes each collection's indexes, and with Options.Validation its
+// validator, match cols, and returns what it changed (or would change, with
+// DryRun). Missing collections are created. Creating a unique index fails
+// while the collection holds duplicates, unless Options.SkipDuplicates is
+// set; the error names the index.
+func Reconcile(ctx context.Context, db *mongo.Database, cols []Collection, opts Options) ([]Change, error) {
+	var changes []Change
+	for _, c := range cols {
+		col := db.Collection(c.Name)
+
+		if opts.Validation != "" && opts.Validation != config.SchemaValidationOff && c.Model != nil {
+			changed, err := reconcileValidator(ctx, db, c, opts)
+			if err != nil {
+				return changes, err
+			}
+			if changed {
+				changes = append(changes, Change{Collection: c.Name, Action: ActionValidator})
+			}
+		}
+
+		existing, err := listIndexes(ctx, col)
+		if err != nil {
+			return changes, err
+		}
+		planned := plan(c, existing, opts.DropUnknown)
+		changes = append(changes, planned...)
+		if opts.DryRun {
+			continue
+		}
+		skipped, err := apply(ctx, col, c, planned, opts.SkipDuplicates)
+		if err != nil {
+			return changes, err
+		}
+		for i := len(changes) - len(planned); i < len(changes); i++ {
+			if skipped[changes[i].Index] {
+				changes[i].Action = ActionSkipDuplicates
+			}
+		}
+	}
+	return changes, nil
+}
+
+// existingIndex is the subset of a listIndexes entry that declarations set.
+type existingIndex struct {
+	Name               string             `bson:"name"`
+	Key                bson.D             `bson:"key"`
+	Unique             bool               `bson:"unique"`
+	Partial            bson.D             `bson:"partialFilterExpression"`
+	ExpireAfterSeconds *float64           `bson:"expireAfterSeconds"`
+	Weights            bson.D             `bson:"weights"`
+	Collation          *existingCollation `bson:"collation"`
+}
+
+type existingCollation struct {
+	Locale   string `bson:"locale"`
+	Strength int    `bson:"strength"`
+}
+
+func listIndexes(ctx context.Context, col *mongo.Collection) ([]existingIndex, error) {
+	cur, err := col.Indexes().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list %s indexes: %w", col.Name(), err)
+	}
+	var existing []existingIndex
+	if err := cur.All(ctx, &existing); err != nil {
+		return nil, fmt.Errorf("decode %s indexes: %w", col.Name(), err)
+	}
+	return existing, nil
+}
+
+// plan compares the declared indexes with the existing ones.
+func plan(c Collection, existing []existingIndex, dropUnknown bool) []Change {
+	byName := make(map[string]existingIndex, len(existing))
+	for _, e := range existing {
+		byName[e.Name] = e
+	}
+
+	var changes []Change
+	declared := make(map[string]bool, len(c.Indexes))
+	for _, ix := range c.Indexes {
+		declared[ix.Name] = true
+		e, ok := byName[ix.Name]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Collection: c.Name, Index: ix.Name, Action: ActionCreate})
+		case !ix.matches(e):
+			changes = append(changes, Change{Collection: c.Name, Index: ix.Name, Action: ActionRecreate})
+		}
+	}
+	for _, e := range existing {
+		if e.Name == "_id_" || declared[e.Name] {
+			continue
+		}
+		action := ActionKeep
+		if dropUnknown {
+			action = ActionDrop
+		}
+		changes = append(changes, Change{Collection: c.Name, Index: e.Name, Action: action})
+	}
+	return changes
+}
+
+// apply drops before it creates so a recreated or replaced index never
+// clashes with the one it supersedes. It returns the indexes it skipped
+// because of duplicates, which only happens with skipDuplicates; a unique
+// index due for recreation is then checked for duplicates first and, if
+// it would be skipped, the existing one is kept rather than dropped.
+func apply(ctx context.Context, col *mongo.Collection, c Collection, changes []Change, skipDuplicates bool) (map[string]bool, error) {
+	skipped := make(map[string]bool)
+	if skipDuplicates {
+		for _, ch := range changes {
+			ix, ok := c.index(ch.Index)
+			if ch.Action != ActionRecreate || !ok || !ix.Unique {
+				continue
+			}
+			dup, err := hasDuplicates(ctx, col, ix)
+			if err != nil {
+				return nil, err
+			}
+			skipped[ix.Name] = dup
+		}
+	}
+
+	for _, ch := range changes {
+		if ch.Action != ActionDrop && ch.Action != ActionRecreate || skipped[ch.Index] {
+			continue
+		}
+		if err := col.Indexes().DropOne(ctx, ch.Index); err != nil {
+			return nil, fmt.Errorf("drop index %s.%s: %w", c.Name, ch.Index, err)
+		}
+	}
+	for _, ch := range changes {
+		if ch.Action != ActionCreate && ch.Action != ActionRecreate || skipped[ch.Index] {
+			continue
+		}
+		ix, ok := c.index(ch.Index)
+		if !ok {
+			continue
+		}
+		_, err := col.Indexes().CreateOne(ctx, ix.model())
+		switch {
+		case err == nil:
+		case mongo.IsDuplicateKeyError(err) && skipDuplicates:
+			skipped[ix.Name] = true
+		case mongo.IsDuplicateKeyError(err):
+			return nil, fmt.Errorf("create index %s.%s: %s: %w", c.Name, ix.Name, duplicatesHint, err)
+		default:
+			return nil, fmt.Errorf("create index %s.%s: %w", c.Name, ix.Name, err)
+		}
+	}
+	return skipped, nil
+}
+
+func (c Collection) index(name string) (Index, bool) {
+	for _, ix := range c.Indexes {
+		if ix.Name == name {
+			return ix, true
+		}
+	}
+	return Index{}, false
+}
+
+// hasDuplicates reports whether documents in col share ix's key, in which
+// case building ix as a unique index would fail.
+func hasDuplicates(ctx context.Context, col *mongo.Collection, ix Index) (bool, error) {
+	opts := options.Aggregate().SetAllowDiskUse(true)
+	if ix.Collation != nil {
+		opts.SetCollation(ix.Collation)
+	}
+	cur, err := col.Aggregate(ctx, duplicatesPipeline(ix), opts)
+	if err != nil {
+		return false, fmt.Errorf("check %s.%s for duplicates: %w", col.Name(), ix.Name, err)
+	}
+	defer cur.Close(ctx)
+	return cur.Next(ctx), cur.Err()
+}
+
+// duplicatesPipeline groups the documents ix covers by its key fields and
+// yields one group if any key is shared.
+func duplicatesPipeline(ix Index) mongo.Pipeline {
+	key := make(bson.D, len(ix.Keys))
+	for i, k := range ix.Keys {
+		key[i] = bson.E{Key: fmt.Sprintf("k%d", i), Value: "$" + k.Key}
+	}
+	var p mongo.Pipeline
+	if len(ix.Partial) > 0 {
+		p = append(p, bson.D{{Key: "$match", Value: ix.Partial}})
+	}
+	return append(p,
+		bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: key}, {Key: "n", Value: bson.D{{Key: "$sum", Value: 1}}}}}},
+		bson.D{{Key: "$match", Value: bson.D{{Key: "n", Value: bson.D{{Key: "$gt", Value: 1}}}}}},
+		bson.D{{Key: "$limit", Value: 1}},
+	)
+}
+
+func (ix Index) model() mongo.IndexModel {
+	opts := options.Index().SetName(ix.Name)
+	if ix.Unique {
+		opts.SetUnique(true)
+	}
+	if len(ix.Partial) > 0 {
+		opts.SetPartialFilterExpression(ix.Partial)
+	}
+	if ix.ExpireAfter != nil {
+		opts.SetExpireAfterSeconds(int32(*ix.ExpireAfter / time.Second))
+	}
+	if len(ix.Weights) > 0 {
+		opts.SetWeights(ix.Weights)
+	}
+	if ix.Collation != nil {
+		opts.SetCollation(ix.Collation)
+	}
+	return mongo.IndexModel{Keys: ix.Keys, Options: opts}
+}
+
+func (ix Index) matches(e existingIndex) bool {
+	if ix.Unique != e.Unique || !equalValues(ix.Partial, e.Partial) {
+		return false
+	}
+	if (ix.ExpireAfter == nil) != (e.ExpireAfterSeconds == nil) {
+		return false
+	}
+	if ix.ExpireAfter != nil && ix.ExpireAfter.Seconds() != *e.ExpireAfterSeconds {
+		return false
+	}
+	if (ix.Collation == nil) != (e.Collation == nil) {
+		return false
+	}
+	if ix.Collation != nil && (ix.Collation.Locale != e.Collation.Locale || ix.Collation.Strength != e.Collation.Strength) {
+		return false
+	}
+	if weights := ix.textWeights(); weights != nil {
+		// The server stores text fields as weights under the {_fts, _ftsx}
+		// key, so compare those instead of the declared keys.
+		got := make(map[string]float64, len(e.Weights))
+		for _, w := range e.Weights {
+			got[w.Key], _ = normalize(w.Value).(float64)
+		}
+		return reflect.DeepEqual(weights, got)
+	}
+	return equalValues(ix.Keys, e.Key)
+}
+
+// textWeights returns the weight of every text field, or nil for a non-text
+// index.
+func (ix Index) textWeights() map[string]float64 {
+	var weights map[string]float64
+	for _, k := range ix.Keys {
+		if k.Value == "text" {
+			if weights == nil {
+				weights = make(map[string]float64)
+			}
+			weights[k.Key] = 1
+		}
+	}
+	for _, w := range ix.Weights {
+		if weights != nil {
+			weights[w.Key], _ = normalize(w.Value).(float64)
+		}
+	}
+	return weights
+}
+
+// equalValues compares BSON values after widening every number to float64,
+// since the server may return an int as int32 or double.
+func equalValues(a, b any) bool {
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func normalize(v any) any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case bson.D:
+		if len(v) == 0 {
+			return nil
+		}
+		out := make([]any, 0, 2*len(v))
+		for _, e := range v {
+			out = append(out, e.Key, normalize(e.Value))
+		}
+		return out
+	case bson.A:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = normalize(e)
+		}
+		return out
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	default:
+		return v
+	}
+}
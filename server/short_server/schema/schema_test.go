@@ -0,0 +1,221 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestCollectionsAreWellFormed(t *testing.T) {
+	seen := map[string]bool{}
+	for _, c := range Collections() {
+		if seen[c.Name] {
+			t.Fatalf("collection %s declared twice", c.Name)
+		}
+		seen[c.Name] = true
+		names := map[string]bool{}
+		for _, ix := range c.Indexes {
+			if ix.Name == "" || len(ix.Keys) == 0 || names[ix.Name] {
+				t.Fatalf("%s: index %+v needs a unique name and keys", c.Name, ix)
+			}
+			names[ix.Name] = true
+		}
+	}
+	if _, ok := Lookup("users"); !ok {
+		t.Fatal("users collection is not declared")
+	}
+}
+
+func TestPlan(t *testing.T) {
+	users, _ := Lookup("users")
+	movies, _ := Lookup("movies")
+
+	t.Run("empty collection creates everything", func(t *testing.T) {
+		changes := plan(users, []existingIndex{{Name: "_id_", Key: bson.D{{Key: "_id", Value: int32(1)}}}}, false)
+		want := []Change{
+			{Collection: "users", Index: "users_email_unique", Action: ActionCreate},
+			{Collection: "users", Index: "users_role_status", Action: ActionCreate},
+			{Collection: "users", Index: "users_favourite_genre_id", Action: ActionCreate},
+		}
+		if !reflect.DeepEqual(changes, want) {
+			t.Fatalf("got %v, want %v", changes, want)
+		}
+	})
+
+	t.Run("matching indexes need nothing", func(t *testing.T) {
+		existing := []existingIndex{
+			{
+				Name: "users_email_unique", Key: bson.D{{Key: "email", Value: int32(1)}}, Unique: true,
+				Partial: bson.D{{Key: "email", Value: bson.D{{Key: "$type", Value: "string"}, {Key: "$gt", Value: ""}}}},
+			},
+			{Name: "users_role_status", Key: bson.D{{Key: "role", Value: 1.0}, {Key: "status", Value: int64(1)}}},
+			{Name: "users_favourite_genre_id", Key: bson.D{{Key: "favourite_genres.genre_id", Value: int32(1)}}},
+		}
+		if changes := plan(users, existing, true); len(changes) != 0 {
+			t.Fatalf("expected no changes, got %v", changes)
+		}
+	})
+
+	t.Run("changed definitions are recreated and unknown indexes reported", func(t *testing.T) {
+		existing := []existingIndex{
+			// As created by the old dedupe tool, with a different filter.
+			{
+				Name: "users_email_unique", Key: bson.D{{Key: "email", Value: int32(1)}}, Unique: true,
+				Partial: bson.D{{Key: "email", Value: bson.D{{Key: "$type", Value: "string"}}}},
+			},
+			{Name: "users_role_status", Key: bson.D{{Key: "role", Value: int32(1)}, {Key: "status", Value: int32(1)}}},
+			{Name: "users_favourite_genre_id", Key: bson.D{{Key: "favourite_genres.genre_id", Value: int32(1)}}},
+			{Name: "email_1", Key: bson.D{{Key: "email", Value: int32(1)}}},
+		}
+		want := []Change{
+			{Collection: "users", Index: "users_email_unique", Action: ActionRecreate},
+			{Collection: "users", Index: "email_1", Action: ActionKeep},
+		}
+		if changes := plan(users, existing, false); !reflect.DeepEqual(changes, want) {
+			t.Fatalf("got %v, want %v", changes, want)
+		}
+		want[1].Action = ActionDrop
+		if changes := plan(users, existing, true); !reflect.DeepEqual(changes, want) {
+			t.Fatalf("got %v, want %v", changes, want)
+		}
+	})
+
+	t.Run("text indexes compare weights", func(t *testing.T) {
+		var text Index
+		for _, ix := range movies.Indexes {
+			if ix.Name == "movies_text" {
+				text = ix
+			}
+		}
+		e := existingIndex{
+			Name:    "movies_text",
+			Key:     bson.D{{Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: int32(1)}},
+			Weights: bson.D{{Key: "admin_review", Value: int32(1)}, {Key: "title", Value: int32(10)}},
+		}
+		if !text.matches(e) {
+			t.Fatal("expected the server's form of the text index to match")
+		}
+		e.Weights[1].Value = int32(5)
+		if text.matches(e) {
+			t.Fatal("expected a changed weight to differ")
+		}
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		keys, _ := Lookup("idempotency_keys")
+		zero, hour := 0.0, 3600.0
+		e := existingIndex{Name: "expires_at_ttl", Key: bson.D{{Key: "expires_at", Value: int32(1)}}, ExpireAfterSeconds: &zero}
+		if !keys.Indexes[0].matches(e) {
+			t.Fatal("expected TTL index to match")
+		}
+		e.ExpireAfterSeconds = &hour
+		if keys.Indexes[0].matches(e) {
+			t.Fatal("expected a different TTL to differ")
+		}
+	})
+
+	t.Run("collation", func(t *testing.T) {
+		genres, _ := Lookup("genres")
+		name := genres.Indexes[1]
+		e := existingIndex{
+			Name: "genres_name_unique", Key: bson.D{{Key: "genre_name", Value: int32(1)}}, Unique: true,
+			Collation: &existingCollation{Locale: "en", Strength: 2},
+		}
+		if !name.matches(e) {
+			t.Fatal("expected collated index to match")
+		}
+		e.Collation.Strength = 3
+		if name.matches(e) {
+			t.Fatal("expected a different strength to differ")
+		}
+		e.Collation = nil
+		if name.matches(e) {
+			t.Fatal("expected a missing collation to differ")
+		}
+	})
+}
+
+func lookup(t *testing.T, d bson.D, path ...string) any {
+	t.Helper()
+	var v any = d
+	for _, key := range path {
+		doc, ok := v.(bson.D)
+		if !ok {
+			t.Fatalf("%v: not a document at %q", path, key)
+		}
+		v = nil
+		for _, e := range doc {
+			if e.Key == key {
+				v = e.Value
+			}
+		}
+		if v == nil {
+			t.Fatalf("%v: missing %q", path, key)
+		}
+	}
+	return v
+}
+
+func TestJSONSchema(t *testing.T) {
+	movie := JSONSchema(model.Movie{})
+	wantRequired := bson.A{"imdb_id", "title", "poster_path", "youtube_id", "genre", "admin_review", "ranking"}
+	if got := lookup(t, movie, "required"); !reflect.DeepEqual(got, wantRequired) {
+		t.Fatalf("required = %v", got)
+	}
+	title := lookup(t, movie, "properties", "title")
+	if !reflect.DeepEqual(title, bson.D{{Key: "bsonType", Value: "string"}, {Key: "minLength", Value: int64(2)}, {Key: "maxLength", Value: int64(500)}}) {
+		t.Fatalf("title schema = %v", title)
+	}
+	if got := lookup(t, movie, "properties", "_id", "bsonType"); got != "objectId" {
+		t.Fatalf("_id type = %v", got)
+	}
+	if got := lookup(t, movie, "properties", "genre", "bsonType"); !reflect.DeepEqual(got, "array") {
+		t.Fatalf("required genre array must not allow null, got %v", got)
+	}
+	if got := lookup(t, movie, "properties", "genre", "items", "properties", "genre_name", "maxLength"); got != int64(100) {
+		t.Fatalf("dive rules must apply to items, got %v", got)
+	}
+	if got := lookup(t, movie, "properties", "ranking", "properties", "ranking_name", "maxLength"); got != int64(100) {
+		t.Fatalf("ranking_name maxLength = %v", got)
+	}
+
+	user := JSONSchema(model.User{})
+	if got := lookup(t, user, "properties", "role", "enum"); !reflect.DeepEqual(got, bson.A{"ADMIN", "USER"}) {
+		t.Fatalf("role enum = %v", got)
+	}
+	if got := lookup(t, user, "properties", "status", "enum"); !reflect.DeepEqual(got, bson.A{"ACTIVE", "DISABLED", ""}) {
+		t.Fatalf("omitempty oneof must allow the empty string, got %v", got)
+	}
+	if got := lookup(t, user, "properties", "created_at", "bsonType"); got != "date" {
+		t.Fatalf("created_at type = %v", got)
+	}
+	if got := lookup(t, user, "properties", "email", "pattern"); got == "" {
+		t.Fatal("email must carry a pattern")
+	}
+
+	audit := JSONSchema(model.AuditEntry{})
+	if got := lookup(t, audit, "properties", "details", "bsonType"); !reflect.DeepEqual(got, bson.A{"null", "object"}) {
+		t.Fatalf("optional map must allow null, got %v", got)
+	}
+}
+
+func TestDuplicatesPipeline(t *testing.T) {
+	users, _ := Lookup("users")
+	ix, _ := users.index("users_email_unique")
+	p := duplicatesPipeline(ix)
+	if len(p) != 4 || p[0][0].Key != "$match" || !reflect.DeepEqual(p[0][0].Value, ix.Partial) {
+		t.Fatalf("partial index must only count the documents it covers, got %v", p)
+	}
+	group := p[1][0].Value.(bson.D)
+	if want := (bson.D{{Key: "k0", Value: "$email"}}); !reflect.DeepEqual(group[0].Value, want) {
+		t.Fatalf("group key = %v, want %v", group[0].Value, want)
+	}
+
+	movies, _ := Lookup("movies")
+	ix, _ = movies.index("imdb_id_unique")
+	if p := duplicatesPipeline(ix); len(p) != 3 || p[0][0].Key != "$group" {
+		t.Fatalf("full index must group every document, got %v", p)
+	}
+}
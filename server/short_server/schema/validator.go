@@ -0,0 +1,294 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// validationLevel "moderate" only checks inserts and updates of documents
+// that already pass, so legacy documents do not block unrelated writes.
+const validationLevel = "moderate"
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(bson.ObjectID{})
+)
+
+// Validator returns the collMod validator document for c.Model.
+func Validator(c Collection) bson.D {
+	return bson.D{{Key: "$jsonSchema", Value: JSONSchema(c.Model)}}
+}
+
+// JSONSchema builds a $jsonSchema for the struct v from its bson field names
+// and the go-playground/validator tags it carries: required, omitempty,
+// min, max, oneof, email, url and dive. Fields without validate tags are
+// only type-checked, and other properties are allowed.
+func JSONSchema(v any) bson.D {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return objectSchema(t)
+}
+
+func objectSchema(t reflect.Type) bson.D {
+	properties := bson.D{}
+	required := bson.A{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := bsonName(f)
+		if name == "" {
+			continue
+		}
+		r := parseRules(f.Tag.Get("validate"))
+		properties = append(properties, bson.E{Key: name, Value: valueSchema(f.Type, r)})
+		if r.required {
+			required = append(required, name)
+		}
+	}
+
+	schema := bson.D{{Key: "bsonType", Value: "object"}}
+	if len(required) > 0 {
+		schema = append(schema, bson.E{Key: "required", Value: required})
+	}
+	return append(schema, bson.E{Key: "properties", Value: properties})
+}
+
+// bsonName returns the stored field name, or "" for unexported and skipped
+// fields.
+func bsonName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("bson"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
+
+// rules are the validate tags that translate to $jsonSchema keywords.
+type rules struct {
+	required  bool
+	omitempty bool
+	min, max  string
+	oneof     []string
+	email     bool
+	url       bool
+	// dive holds the rules for slice elements.
+	dive *rules
+}
+
+func parseRules(tag string) rules {
+	var r rules
+	parts := strings.Split(tag, ",")
+	for i, p := range parts {
+		name, arg, _ := strings.Cut(p, "=")
+		switch name {
+		case "required":
+			r.required = true
+		case "omitempty":
+			r.omitempty = true
+		case "min":
+			r.min = arg
+		case "max":
+			r.max = arg
+		case "oneof":
+			r.oneof = strings.Fields(arg)
+		case "email":
+			r.email = true
+		case "url":
+			r.url = true
+		case "dive":
+			elem := parseRules(strings.Join(parts[i+1:], ","))
+			r.dive = &elem
+			return r
+		}
+	}
+	return r
+}
+
+func valueSchema(t reflect.Type, r rules) bson.D {
+	nullable := false
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = !r.required
+	}
+
+	var schema bson.D
+	switch {
+	case t == timeType:
+		schema = bson.D{{Key: "bsonType", Value: "date"}}
+	case t == objectIDType:
+		schema = bson.D{{Key: "bsonType", Value: "objectId"}}
+	case t.Kind() == reflect.String:
+		schema = stringSchema(r)
+	case t.Kind() == reflect.Bool:
+		schema = bson.D{{Key: "bsonType", Value: "bool"}}
+	case isInt(t.Kind()):
+		schema = numberSchema(bson.A{"int", "long"}, r)
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		schema = numberSchema(bson.A{"double", "int", "long", "decimal"}, r)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		schema = bson.D{{Key: "bsonType", Value: "binData"}}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		schema = bson.D{{Key: "bsonType", Value: "array"}}
+		if !r.omitempty {
+			schema = appendBound(schema, "minItems", r.min)
+			schema = appendBound(schema, "maxItems", r.max)
+		}
+		var elem rules
+		if r.dive != nil {
+			elem = *r.dive
+		}
+		schema = append(schema, bson.E{Key: "items", Value: valueSchema(t.Elem(), elem)})
+		// A nil slice is stored as null.
+		nullable = nullable || !r.required
+	case t.Kind() == reflect.Map:
+		schema = bson.D{{Key: "bsonType", Value: "object"}}
+		nullable = nullable || !r.required
+	case t.Kind() == reflect.Struct:
+		schema = objectSchema(t)
+	default:
+		return bson.D{}
+	}
+
+	if nullable {
+		schema[0].Value = append(bson.A{"null"}, bsonTypes(schema[0].Value)...)
+	}
+	return schema
+}
+
+func stringSchema(r rules) bson.D {
+	schema := bson.D{{Key: "bsonType", Value: "string"}}
+	if len(r.oneof) > 0 {
+		enum := bson.A{}
+		for _, v := range r.oneof {
+			enum = append(enum, v)
+		}
+		if r.omitempty {
+			enum = append(enum, "")
+		}
+		schema = append(schema, bson.E{Key: "enum", Value: enum})
+	}
+	if r.omitempty {
+		return schema
+	}
+	schema = appendBound(schema, "minLength", r.min)
+	schema = appendBound(schema, "maxLength", r.max)
+	switch {
+	case r.email:
+		schema = append(schema, bson.E{Key: "pattern", Value: `^[^@\s]+@[^@\s]+$`})
+	case r.url:
+		schema = append(schema, bson.E{Key: "pattern", Value: `^[A-Za-z][A-Za-z0-9+.-]*://`})
+	}
+	return schema
+}
+
+func numberSchema(types bson.A, r rules) bson.D {
+	schema := bson.D{{Key: "bsonType", Value: types}}
+	if len(r.oneof) > 0 {
+		enum := bson.A{}
+		for _, v := range r.oneof {
+			if n, err := strconv.ParseFloat(v, 64); err == nil {
+				enum = append(enum, n)
+			}
+		}
+		schema = append(schema, bson.E{Key: "enum", Value: enum})
+	}
+	if r.omitempty {
+		return schema
+	}
+	schema = appendBound(schema, "minimum", r.min)
+	return appendBound(schema, "maximum", r.max)
+}
+
+// appendBound adds keyword with the numeric value of arg, if it has one.
+func appendBound(schema bson.D, keyword, arg string) bson.D {
+	n, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return schema
+	}
+	if keyword == "minimum" || keyword == "maximum" {
+		return append(schema, bson.E{Key: keyword, Value: n})
+	}
+	return append(schema, bson.E{Key: keyword, Value: int64(n)})
+}
+
+func bsonTypes(v any) bson.A {
+	if a, ok := v.(bson.A); ok {
+		return a
+	}
+	return bson.A{v}
+}
+
+func isInt(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// reconcileValidator installs c's validator unless the collection already
+// has it, creating the collection if needed, and reports whether it changed.
+func reconcileValidator(ctx context.Context, db *mongo.Database, c Collection, opts Options) (bool, error) {
+	want := Validator(c)
+	specs, err := db.ListCollectionSpecifications(ctx, bson.D{{Key: "name", Value: c.Name}})
+	if err != nil {
+		return false, fmt.Errorf("list %s collection: %w", c.Name, err)
+	}
+
+	if len(specs) == 0 {
+		if opts.DryRun {
+			return true, nil
+		}
+		err := db.CreateCollection(ctx, c.Name, options.CreateCollection().
+			SetValidator(want).
+			SetValidationLevel(validationLevel).
+			SetValidationAction(opts.Validation))
+		if err != nil {
+			return false, fmt.Errorf("create %s collection: %w", c.Name, err)
+		}
+		return true, nil
+	}
+
+	var current struct {
+		Validator bson.D `bson:"validator"`
+		Level     string `bson:"validationLevel"`
+		Action    string `bson:"validationAction"`
+	}
+	if len(specs[0].Options) > 0 {
+		if err := bson.Unmarshal(specs[0].Options, &current); err != nil {
+			return false, fmt.Errorf("decode %s collection options: %w", c.Name, err)
+		}
+	}
+	if equalValues(want, current.Validator) && current.Level == validationLevel && current.Action == opts.Validation {
+		return false, nil
+	}
+	if opts.DryRun {
+		return true, nil
+	}
+	err = db.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: c.Name},
+		{Key: "validator", Value: want},
+		{Key: "validationLevel", Value: validationLevel},
+		{Key: "validationAction", Value: opts.Validation},
+	}).Err()
+	if err != nil {
+		return false, fmt.Errorf("set %s validator: %w", c.Name, err)
+	}
+	return true, nil
+}
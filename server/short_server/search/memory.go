@@ -0,0 +1,146 @@
+package search
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Index is an in-process inverted index over movie titles and reviews. It is
+// a Searcher; fill it with Load at startup and keep it current by writing
+// movies through the store returned by Track.
+type Index struct {
+	mu     sync.RWMutex
+	movies map[bson.ObjectID]model.Movie
+	// postings maps a term to the score each movie gets for containing it.
+	postings map[string]map[bson.ObjectID]float64
+	// terms remembers each movie's terms so Remove can find its postings.
+	terms map[bson.ObjectID][]string
+}
+
+func NewIndex() *Index {
+	return &Index{
+		movies:   make(map[bson.ObjectID]model.Movie),
+		postings: make(map[string]map[bson.ObjectID]float64),
+		terms:    make(map[bson.ObjectID][]string),
+	}
+}
+
+// Put adds the movie or replaces the indexed copy with the same ID.
+func (x *Index) Put(m model.Movie) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.remove(m.ID)
+	scores := make(map[string]float64)
+	addField(scores, m.Title, titleWeight)
+	addField(scores, m.AdminReview, reviewWeight)
+
+	terms := make([]string, 0, len(scores))
+	for term, score := range scores {
+		if x.postings[term] == nil {
+			x.postings[term] = make(map[bson.ObjectID]float64)
+		}
+		x.postings[term][m.ID] = score
+		terms = append(terms, term)
+	}
+	m.Genre = slices.Clone(m.Genre)
+	x.movies[m.ID] = m
+	x.terms[m.ID] = terms
+}
+
+func (x *Index) Remove(id bson.ObjectID) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.remove(id)
+}
+
+func (x *Index) remove(id bson.ObjectID) {
+	for _, term := range x.terms[id] {
+		delete(x.postings[term], id)
+		if len(x.postings[term]) == 0 {
+			delete(x.postings, term)
+		}
+	}
+	delete(x.terms, id)
+	delete(x.movies, id)
+}
+
+// addField scores each term of a field by its share of the field's words,
+// so a match in a short title outranks one in a long review.
+func addField(scores map[string]float64, text string, weight float64) {
+	terms := Terms(text)
+	for _, term := range terms {
+		scores[term] += weight / float64(len(terms))
+	}
+}
+
+// Search matches movies containing any term of the query, scored by the sum
+// of their per-term scores.
+func (x *Index) Search(ctx context.Context, q Query) ([]Hit, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	scores := make(map[bson.ObjectID]float64)
+	for _, term := range uniqueTerms(q.Text) {
+		for id, score := range x.postings[term] {
+			scores[id] += score
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		m := x.movies[id]
+		if q.GenreID != 0 && !slices.ContainsFunc(m.Genre, func(g model.Genre) bool { return g.GenreID == q.GenreID }) {
+			continue
+		}
+		if !q.After.after(score, id) {
+			continue
+		}
+		hits = append(hits, Hit{Movie: m, Score: score})
+	}
+	slices.SortFunc(hits, func(a, b Hit) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.Movie.ID[:], b.Movie.ID[:])
+	})
+	if q.Limit > 0 && len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	return hits, nil
+}
+
+func uniqueTerms(s string) []string {
+	terms := Terms(s)
+	slices.Sort(terms)
+	return slices.Compact(terms)
+}
+
+// RenameGenre updates the genre names in indexed copies, since hits return
+// whole movies.
+func (x *Index) RenameGenre(genreID int, name string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for id, m := range x.movies {
+		genres := slices.Clone(m.Genre)
+		changed := false
+		for i := range genres {
+			if genres[i].GenreID == genreID && genres[i].GenreName != name {
+				genres[i].GenreName = name
+				changed = true
+			}
+		}
+		if changed {
+			m.Genre = genres
+			x.movies[id] = m
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const defaultQueryTimeout = 10 * time.Second
+
+// MongoSearcher runs $text queries against the movies collection, which
+// needs the movies_text index declared in the schema package.
+type MongoSearcher struct {
+	col     *mongo.Collection
+	timeout time.Duration
+}
+
+func NewMongoSearcher(col *mongo.Collection, timeout time.Duration) *MongoSearcher {
+	if timeout <= 0 {
+		timeout = defaultQueryTimeout
+	}
+	return &MongoSearcher{col: col, timeout: timeout}
+}
+
+func (s *MongoSearcher) Search(ctx context.Context, q Query) ([]Hit, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	cursor, err := s.col.Aggregate(ctx, pipeline(q))
+	if err != nil {
+		return nil, fmt.Errorf("search movies: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var docs []struct {
+		Movie bson.Raw `bson:"movie"`
+		Score float64  `bson:"score"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("decode search results: %w", err)
+	}
+	hits := make([]Hit, len(docs))
+	for i, d := range docs {
+		if err := bson.Unmarshal(d.Movie, &hits[i].Movie); err != nil {
+			return nil, fmt.Errorf("decode search results: %w", err)
+		}
+		hits[i].Score = d.Score
+	}
+	return hits, nil
+}
+
+// pipeline scores matches with the text index and pages through them in
+// score order. The score is projected next to the movie rather than into it
+// so a movie field can never clash with it.
+func pipeline(q Query) mongo.Pipeline {
+	match := bson.D{{Key: "$text", Value: bson.D{{Key: "$search", Value: q.Text}}}}
+	if q.GenreID != 0 {
+		match = append(match, bson.E{Key: "genre.genre_id", Value: q.GenreID})
+	}
+	p := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 1},
+			{Key: "movie", Value: "$$ROOT"},
+			{Key: "score", Value: bson.D{{Key: "$meta", Value: "textScore"}}},
+		}}},
+	}
+	if q.After != nil {
+		p = append(p, bson.D{{Key: "$match", Value: bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "score", Value: bson.D{{Key: "$lt", Value: q.After.Score}}}},
+			bson.D{{Key: "score", Value: q.After.Score}, {Key: "_id", Value: bson.D{{Key: "$gt", Value: q.After.ID}}}},
+		}}}}})
+	}
+	p = append(p, bson.D{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: 1}}}})
+	if q.Limit > 0 {
+		p = append(p, bson.D{{Key: "$limit", Value: q.Limit}})
+	}
+	return p
+}
@@ -0,0 +1,96 @@
+// Package search finds movies by free text in their title and admin review.
+// A Searcher is backed either by the movies_text index in Mongo or by an
+// inverted Index kept in process, which needs no text index and suits tests
+// and small deployments.
+package search
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"unicode"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Query selects a page of movies matching Text, best match first.
+type Query struct {
+	Text string
+	// GenreID, when not zero, keeps only movies tagged with that genre.
+	GenreID int
+	After   *Cursor
+	Limit   int
+}
+
+// Cursor is the position of the last hit of a page. Hits are ordered by
+// score, highest first, and then by _id.
+type Cursor struct {
+	Score float64
+	ID    bson.ObjectID
+}
+
+// Hit is a matching movie and its relevance score. Scores are comparable
+// within one backend only.
+type Hit struct {
+	Movie model.Movie
+	Score float64
+}
+
+type Searcher interface {
+	Search(ctx context.Context, q Query) ([]Hit, error)
+}
+
+// Field weights, matching the movies_text index declared in the schema
+// package.
+const (
+	titleWeight  = 10
+	reviewWeight = 1
+)
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "in": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "the": true, "to": true,
+	"with": true,
+}
+
+// Fold lowercases s and strips its diacritics, so "Amélie" and "AMELIE"
+// compare equal.
+func Fold(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		folded = s
+	}
+	return strings.ToLower(folded)
+}
+
+// Terms splits s into folded words, dropping common English stop words the
+// way a Mongo text index does.
+func Terms(s string) []string {
+	words := strings.FieldsFunc(Fold(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := words[:0]
+	for _, w := range words {
+		if !stopWords[w] {
+			terms = append(terms, w)
+		}
+	}
+	return terms
+}
+
+// after reports whether a hit sorts after the cursor.
+func (c *Cursor) after(score float64, id bson.ObjectID) bool {
+	if c == nil {
+		return true
+	}
+	if score != c.Score {
+		return score < c.Score
+	}
+	return bytes.Compare(id[:], c.ID[:]) > 0
+}
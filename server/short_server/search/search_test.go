@@ -0,0 +1,212 @@
+package search
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/store"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestTerms(t *testing.T) {
+	got := Terms("The Fabulous Destiny of Amélie Poulain, 2001!")
+	want := []string{"fabulous", "destiny", "amelie", "poulain", "2001"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Terms = %v, want %v", got, want)
+	}
+}
+
+func hitTitles(hits []Hit) []string {
+	out := make([]string, len(hits))
+	for i, h := range hits {
+		out[i] = h.Movie.Title
+	}
+	return out
+}
+
+func TestIndexSearch(t *testing.T) {
+	ctx := context.Background()
+	x := NewIndex()
+	drama := []model.Genre{{GenreID: 1, GenreName: "Drama"}}
+	comedy := []model.Genre{{GenreID: 2, GenreName: "Comedy"}}
+	x.Put(model.Movie{ID: bson.NewObjectID(), Title: "Alien", AdminReview: "A space horror classic", Genre: drama})
+	x.Put(model.Movie{ID: bson.NewObjectID(), Title: "Spaceballs", AdminReview: "Space comedy that spoofs every space film", Genre: comedy})
+	x.Put(model.Movie{ID: bson.NewObjectID(), Title: "Space Odyssey", AdminReview: "Slow", Genre: drama})
+	x.Put(model.Movie{ID: bson.NewObjectID(), Title: "Amélie", AdminReview: "Charming", Genre: comedy})
+
+	t.Run("title matches outrank review matches", func(t *testing.T) {
+		hits, _ := x.Search(ctx, Query{Text: "space"})
+		if got := hitTitles(hits); !reflect.DeepEqual(got, []string{"Space Odyssey", "Alien", "Spaceballs"}) {
+			t.Fatalf("unexpected order %v", got)
+		}
+	})
+
+	t.Run("genre filter", func(t *testing.T) {
+		hits, _ := x.Search(ctx, Query{Text: "space", GenreID: 2})
+		if got := hitTitles(hits); !reflect.DeepEqual(got, []string{"Spaceballs"}) {
+			t.Fatalf("unexpected hits %v", got)
+		}
+	})
+
+	t.Run("diacritics and case are ignored", func(t *testing.T) {
+		hits, _ := x.Search(ctx, Query{Text: "AMELIE"})
+		if got := hitTitles(hits); !reflect.DeepEqual(got, []string{"Amélie"}) {
+			t.Fatalf("unexpected hits %v", got)
+		}
+	})
+
+	t.Run("pages follow the cursor", func(t *testing.T) {
+		var got []string
+		q := Query{Text: "space", Limit: 2}
+		for {
+			hits, _ := x.Search(ctx, q)
+			got = append(got, hitTitles(hits)...)
+			if len(hits) < q.Limit {
+				break
+			}
+			last := hits[len(hits)-1]
+			q.After = &Cursor{Score: last.Score, ID: last.Movie.ID}
+		}
+		if !reflect.DeepEqual(got, []string{"Space Odyssey", "Alien", "Spaceballs"}) {
+			t.Fatalf("unexpected pages %v", got)
+		}
+	})
+}
+
+func TestTrackedStore(t *testing.T) {
+	ctx := context.Background()
+	backing := store.NewMemoryMovieStore()
+	existing := model.Movie{ID: bson.NewObjectID(), ImdbID: "tt1", Title: "Heat", Genre: []model.Genre{{GenreID: 1, GenreName: "Crime"}}}
+	if err := backing.Insert(ctx, existing); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	x, titles := NewIndex(), NewTitleIndex()
+	if err := Load(ctx, backing, x, titles); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	movies := Track(backing, x, titles)
+
+	if hits, _ := x.Search(ctx, Query{Text: "heat"}); len(hits) != 1 {
+		t.Fatalf("expected loaded movie to be found, got %v", hitTitles(hits))
+	}
+
+	added := model.Movie{ID: bson.NewObjectID(), ImdbID: "tt2", Title: "Ronin"}
+	if err := movies.Insert(ctx, added); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	added.Title = "Ronin (1998)"
+	if err := movies.Replace(ctx, added); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if hits, _ := x.Search(ctx, Query{Text: "1998"}); len(hits) != 1 {
+		t.Fatalf("expected replaced title to be indexed, got %v", hitTitles(hits))
+	}
+	if got := titles.Suggest("ron", 5); len(got) != 1 || got[0].Title != "Ronin (1998)" {
+		t.Fatalf("expected replaced title to be suggested, got %+v", got)
+	}
+
+	if _, err := movies.RenameGenre(ctx, 1, "Heist"); err != nil {
+		t.Fatalf("rename genre: %v", err)
+	}
+	if hits, _ := x.Search(ctx, Query{Text: "heat"}); hits[0].Movie.Genre[0].GenreName != "Heist" {
+		t.Fatalf("expected renamed genre in hits, got %+v", hits[0].Movie.Genre)
+	}
+
+	if err := movies.Delete(ctx, existing.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if hits, _ := x.Search(ctx, Query{Text: "heat"}); len(hits) != 0 {
+		t.Fatalf("expected deleted movie to be gone, got %v", hitTitles(hits))
+	}
+	if got := titles.Suggest("heat", 5); len(got) != 0 {
+		t.Fatalf("expected deleted movie not to be suggested, got %+v", got)
+	}
+
+	dup := model.Movie{ID: bson.NewObjectID(), ImdbID: "tt2", Title: "Duplicate"}
+	if err := movies.Insert(ctx, dup); err == nil {
+		t.Fatal("expected duplicate imdb_id to fail")
+	}
+	if hits, _ := x.Search(ctx, Query{Text: "duplicate"}); len(hits) != 0 {
+		t.Fatal("a failed write must not be indexed")
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	id := bson.NewObjectID()
+	p := pipeline(Query{Text: "alien", GenreID: 3, After: &Cursor{Score: 1.5, ID: id}, Limit: 10})
+	if len(p) != 5 {
+		t.Fatalf("expected match, project, after, sort and limit stages, got %v", p)
+	}
+	wantMatch := bson.D{{Key: "$match", Value: bson.D{
+		{Key: "$text", Value: bson.D{{Key: "$search", Value: "alien"}}},
+		{Key: "genre.genre_id", Value: 3},
+	}}}
+	if !reflect.DeepEqual(p[0], wantMatch) {
+		t.Fatalf("match = %v", p[0])
+	}
+	wantSort := bson.D{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: 1}}}}
+	if !reflect.DeepEqual(p[3], wantSort) {
+		t.Fatalf("sort = %v", p[3])
+	}
+
+	if p := pipeline(Query{Text: "alien"}); len(p) != 3 {
+		t.Fatalf("expected no cursor or limit stages, got %v", p)
+	}
+}
+
+func TestTitleIndexSuggest(t *testing.T) {
+	x := NewTitleIndex()
+	movie := func(title string) model.Movie {
+		return model.Movie{ID: bson.NewObjectID(), Title: title, PosterPath: "https://example.com/" + title}
+	}
+	for _, title := range []string{"Star Wars", "Stardust", "Amélie", "2001: A Space Odyssey", "Lone Star", "Alien"} {
+		x.Put(movie(title))
+	}
+
+	suggested := func(prefix string, limit int) []string {
+		var out []string
+		for _, s := range x.Suggest(prefix, limit) {
+			out = append(out, s.Title)
+		}
+		return out
+	}
+
+	for _, tc := range []struct {
+		prefix string
+		limit  int
+		want   []string
+	}{
+		{"star", 10, []string{"Star Wars", "Stardust", "Lone Star"}},
+		{"STAR", 2, []string{"Star Wars", "Stardust"}},
+		{"ame", 10, []string{"Amélie"}},
+		{"amé", 10, []string{"Amélie"}},
+		{"2001 a sp", 10, []string{"2001: A Space Odyssey"}},
+		{"odyssey", 10, []string{"2001: A Space Odyssey"}},
+		{"star w", 10, []string{"Star Wars"}},
+		{"zz", 10, nil},
+		{"  ", 10, nil},
+	} {
+		if got := suggested(tc.prefix, tc.limit); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Suggest(%q, %d) = %v, want %v", tc.prefix, tc.limit, got, tc.want)
+		}
+	}
+
+	if s := x.Suggest("alien", 1); len(s) != 1 || s[0].PosterPath != "https://example.com/Alien" || s[0].ID.IsZero() {
+		t.Fatalf("expected ID and poster path, got %+v", s)
+	}
+
+	bulk := NewTitleIndex()
+	first, second := movie("Lone Star"), movie("Lone Star")
+	bulk.PutAll([]model.Movie{first, movie("Star Wars"), second, movie("Stardust")})
+	bulk.PutAll([]model.Movie{{ID: first.ID, Title: "Lone Star (1996)"}})
+	if got := bulk.Suggest("star", 10); len(got) != 4 || got[0].Title != "Star Wars" || got[1].Title != "Stardust" {
+		t.Fatalf("expected bulk load to sort like Put, got %+v", got)
+	}
+	bulk.Remove(first.ID)
+	if got := bulk.Suggest("lone", 10); len(got) != 1 || got[0].ID != second.ID {
+		t.Fatalf("expected only the other copy of a shared title to remain, got %+v", got)
+	}
+}
@@ -0,0 +1,190 @@
+package search
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Suggestion is a title offered for type-ahead.
+type Suggestion struct {
+	ID         bson.ObjectID `json:"_id"`
+	Title      string        `json:"title"`
+	PosterPath string        `json:"poster_path"`
+}
+
+type Suggester interface {
+	// Suggest returns up to limit titles starting with prefix.
+	Suggest(prefix string, limit int) []Suggestion
+}
+
+// titleKey is one sorted entry of a TitleIndex.
+type titleKey struct {
+	key string
+	id  bson.ObjectID
+}
+
+// TitleIndex answers title prefix queries from memory, ignoring case,
+// diacritics and punctuation. Titles are matched from their start first and
+// then from the start of any later word, so "odyssey" finds "2001: A Space
+// Odyssey". Each instance only sees the writes made through it, on top of
+// what Load read at startup.
+type TitleIndex struct {
+	mu sync.RWMutex
+	// titles and words hold keys in sorted order for whole titles and for
+	// title tails starting at each later word.
+	titles []titleKey
+	words  []titleKey
+	movies map[bson.ObjectID]titleEntry
+}
+
+// titleEntry is an indexed movie with the keys it added, so removing it
+// only has to look those up.
+type titleEntry struct {
+	suggestion Suggestion
+	title      titleKey
+	words      []titleKey
+}
+
+func NewTitleIndex() *TitleIndex {
+	return &TitleIndex{movies: make(map[bson.ObjectID]titleEntry)}
+}
+
+// titleWords folds s and splits it on anything but letters and digits.
+func titleWords(s string) []string {
+	return strings.FieldsFunc(Fold(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// newTitleEntry returns the entry for m, or false if its title has no
+// letters or digits to match on.
+func newTitleEntry(m model.Movie) (titleEntry, bool) {
+	words := titleWords(m.Title)
+	if len(words) == 0 {
+		return titleEntry{}, false
+	}
+	e := titleEntry{
+		suggestion: Suggestion{ID: m.ID, Title: m.Title, PosterPath: m.PosterPath},
+		title:      titleKey{key: strings.Join(words, " "), id: m.ID},
+		words:      make([]titleKey, 0, len(words)-1),
+	}
+	for i := 1; i < len(words); i++ {
+		e.words = append(e.words, titleKey{key: strings.Join(words[i:], " "), id: m.ID})
+	}
+	return e, true
+}
+
+func (x *TitleIndex) Put(m model.Movie) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.remove(m.ID)
+	e, ok := newTitleEntry(m)
+	if !ok {
+		return
+	}
+	x.titles = insertKey(x.titles, e.title)
+	for _, k := range e.words {
+		x.words = insertKey(x.words, k)
+	}
+	x.movies[m.ID] = e
+}
+
+// PutAll puts every movie, appending their keys and sorting once instead of
+// inserting each key in place, which makes building a large index from
+// scratch O(N log N) rather than O(N²). A movie listed twice keeps its last
+// copy.
+func (x *TitleIndex) PutAll(movies []model.Movie) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for _, m := range movies {
+		x.remove(m.ID)
+	}
+	added := make(map[bson.ObjectID]titleEntry, len(movies))
+	for _, m := range movies {
+		if e, ok := newTitleEntry(m); ok {
+			added[m.ID] = e
+		} else {
+			delete(added, m.ID)
+		}
+	}
+	for id, e := range added {
+		x.titles = append(x.titles, e.title)
+		x.words = append(x.words, e.words...)
+		x.movies[id] = e
+	}
+	slices.SortFunc(x.titles, compareKeys)
+	slices.SortFunc(x.words, compareKeys)
+}
+
+func (x *TitleIndex) Remove(id bson.ObjectID) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.remove(id)
+}
+
+func (x *TitleIndex) remove(id bson.ObjectID) {
+	e, ok := x.movies[id]
+	if !ok {
+		return
+	}
+	x.titles = deleteKey(x.titles, e.title)
+	for _, k := range e.words {
+		x.words = deleteKey(x.words, k)
+	}
+	delete(x.movies, id)
+}
+
+func compareKeys(a, b titleKey) int {
+	if c := strings.Compare(a.key, b.key); c != 0 {
+		return c
+	}
+	return bytes.Compare(a.id[:], b.id[:])
+}
+
+func insertKey(keys []titleKey, k titleKey) []titleKey {
+	i, _ := slices.BinarySearchFunc(keys, k, compareKeys)
+	return slices.Insert(keys, i, k)
+}
+
+func deleteKey(keys []titleKey, k titleKey) []titleKey {
+	i, found := slices.BinarySearchFunc(keys, k, compareKeys)
+	if !found {
+		return keys
+	}
+	return slices.Delete(keys, i, i+1)
+}
+
+// Suggest returns whole-title matches in alphabetical order, followed by
+// titles with a later word matching, each title at most once.
+func (x *TitleIndex) Suggest(prefix string, limit int) []Suggestion {
+	p := strings.Join(titleWords(prefix), " ")
+	if p == "" || limit <= 0 {
+		return []Suggestion{}
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	out := make([]Suggestion, 0, limit)
+	seen := make(map[bson.ObjectID]bool)
+	for _, keys := range [][]titleKey{x.titles, x.words} {
+		i, _ := slices.BinarySearchFunc(keys, titleKey{key: p}, compareKeys)
+		for ; i < len(keys) && len(out) < limit && strings.HasPrefix(keys[i].key, p); i++ {
+			if seen[keys[i].id] {
+				continue
+			}
+			seen[keys[i].id] = true
+			out = append(out, x.movies[keys[i].id].suggestion)
+		}
+	}
+	return out
+}
@@ -0,0 +1,118 @@
+package search
+
+import (
+	"context"
+	"fmt"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/store"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// loadPageSize is how many movies Load reads per query.
+const loadPageSize = 500
+
+// Indexer is an in-process index over movies, such as Index or TitleIndex.
+type Indexer interface {
+	// Put adds the movie or replaces the indexed copy with the same ID.
+	Put(m model.Movie)
+	Remove(id bson.ObjectID)
+}
+
+// bulkIndexer is implemented by indexers that can add many movies faster
+// than one Put at a time.
+type bulkIndexer interface {
+	PutAll(movies []model.Movie)
+}
+
+// genreRenamer is implemented by indexers that keep genre names.
+type genreRenamer interface {
+	RenameGenre(genreID int, name string)
+}
+
+// Load adds every movie in the store to the indexes, paging through it by
+// _id. Indexes that support bulk loading get all movies in one call once
+// the last page is read; the others get them one at a time per page.
+func Load(ctx context.Context, movies store.MovieStore, indexes ...Indexer) error {
+	var all []model.Movie
+	q := store.MovieQuery{SortBy: store.MovieSortID, Limit: loadPageSize}
+	for {
+		page, err := movies.List(ctx, q)
+		if err != nil {
+			return fmt.Errorf("load search indexes: %w", err)
+		}
+		for _, x := range indexes {
+			if _, ok := x.(bulkIndexer); ok {
+				continue
+			}
+			for _, m := range page {
+				x.Put(m)
+			}
+		}
+		all = append(all, page...)
+		if len(page) < q.Limit {
+			break
+		}
+		q.After = &store.MovieCursor{ID: page[len(page)-1].ID}
+	}
+	for _, x := range indexes {
+		if b, ok := x.(bulkIndexer); ok {
+			b.PutAll(all)
+		}
+	}
+	return nil
+}
+
+// Track returns movies with its writes mirrored into the indexes. A write
+// that fails leaves the indexes untouched.
+func Track(movies store.MovieStore, indexes ...Indexer) store.MovieStore {
+	return &trackedStore{MovieStore: movies, indexes: indexes}
+}
+
+type trackedStore struct {
+	store.MovieStore
+	indexes []Indexer
+}
+
+func (s *trackedStore) Insert(ctx context.Context, movie model.Movie) error {
+	if err := s.MovieStore.Insert(ctx, movie); err != nil {
+		return err
+	}
+	for _, x := range s.indexes {
+		x.Put(movie)
+	}
+	return nil
+}
+
+func (s *trackedStore) Replace(ctx context.Context, movie model.Movie) error {
+	if err := s.MovieStore.Replace(ctx, movie); err != nil {
+		return err
+	}
+	for _, x := range s.indexes {
+		x.Put(movie)
+	}
+	return nil
+}
+
+func (s *trackedStore) Delete(ctx context.Context, id bson.ObjectID) error {
+	if err := s.MovieStore.Delete(ctx, id); err != nil {
+		return err
+	}
+	for _, x := range s.indexes {
+		x.Remove(id)
+	}
+	return nil
+}
+
+func (s *trackedStore) RenameGenre(ctx context.Context, genreID int, name string) (int64, error) {
+	n, err := s.MovieStore.RenameGenre(ctx, genreID, name)
+	if err != nil {
+		return n, err
+	}
+	for _, x := range s.indexes {
+		if r, ok := x.(genreRenamer); ok {
+			r.RenameGenre(genreID, name)
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,93 @@
+// Package server runs the HTTP listener and shuts it down gracefully.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/config"
+)
+
+// Server wraps an http.Server whose lifetime is tied to a context.
+type Server struct {
+	http            *http.Server
+	shutdownTimeout time.Duration
+	drainDelay      time.Duration
+	draining        atomic.Bool
+}
+
+func New(cfg config.ServerConfig, h http.Handler) *Server {
+	return &Server{
+		http: &http.Server{
+			Addr:              cfg.Addr,
+			Handler:           h,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+			ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+		},
+		shutdownTimeout: cfg.ShutdownTimeout,
+		drainDelay:      cfg.DrainDelay,
+	}
+}
+
+// Draining reports whether a shutdown has started. Readiness probes use it
+// to take the instance out of rotation.
+func (s *Server) Draining() bool {
+	return s.draining.Load()
+}
+
+// Run listens on the configured address and serves until ctx is done, then
+// shuts down gracefully. See Serve.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", s.http.Addr, err)
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts connections on ln until ctx is done. It then marks the
+// server as draining, keeps serving for the drain delay, stops accepting
+// new connections and waits up to the shutdown timeout for in-flight
+// requests to finish; connections still busy after that are closed and
+// context.DeadlineExceeded is returned.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("server listening", "addr", ln.Addr().String())
+		serveErr <- s.http.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	s.draining.Store(true)
+	if s.drainDelay > 0 {
+		slog.Info("server draining", "delay", s.drainDelay.String())
+		time.Sleep(s.drainDelay)
+	}
+
+	slog.Info("server shutting down", "timeout", s.shutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	if err := s.http.Shutdown(shutdownCtx); err != nil {
+		_ = s.http.Close()
+		return fmt.Errorf("drain in-flight requests: %w", err)
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve: %w", err)
+	}
+	slog.Info("server stopped")
+	return nil
+}
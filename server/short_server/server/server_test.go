@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/config"
+)
+
+// blockingHandler signals when a request arrives and answers once released.
+func blockingHandler(started chan<- struct{}, release <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		_, _ = io.WriteString(w, "done")
+	})
+}
+
+func startServer(t *testing.T, h http.Handler, shutdownTimeout time.Duration) (string, context.CancelFunc, <-chan error) {
+	url, _, stop, done := startServerWith(t, h, func(cfg *config.ServerConfig) { cfg.ShutdownTimeout = shutdownTimeout })
+	return url, stop, done
+}
+
+func startServerWith(t *testing.T, h http.Handler, configure func(*config.ServerConfig)) (string, *Server, context.CancelFunc, <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	cfg := config.Default().Server
+	configure(&cfg)
+
+	srv := New(cfg, h)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, ln) }()
+	return "http://" + ln.Addr().String(), srv, cancel, done
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	url, stop, done := startServer(t, blockingHandler(started, release), 5*time.Second)
+
+	type result struct {
+		body string
+		err  error
+	}
+	resp := make(chan result, 1)
+	go func() {
+		r, err := http.Get(url)
+		if err != nil {
+			resp <- result{err: err}
+			return
+		}
+		defer r.Body.Close()
+		b, err := io.ReadAll(r.Body)
+		resp <- result{body: string(b), err: err}
+	}()
+
+	<-started
+	stop()
+
+	select {
+	case err := <-done:
+		t.Fatalf("server stopped before the in-flight request finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Fatal("expected new connections to be refused while draining")
+	}
+
+	close(release)
+	if r := <-resp; r.err != nil || r.body != "done" {
+		t.Fatalf("in-flight request not completed: %+v", r)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("expected clean shutdown, got %v", err)
+	}
+}
+
+func TestServeShutdownDeadline(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	url, stop, done := startServer(t, blockingHandler(started, release), 50*time.Millisecond)
+
+	go func() {
+		if r, err := http.Get(url); err == nil {
+			r.Body.Close()
+		}
+	}()
+	<-started
+	stop()
+
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestServeKeepsServingDuringDrainDelay(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	url, srv, stop, done := startServerWith(t, h, func(cfg *config.ServerConfig) { cfg.DrainDelay = 200 * time.Millisecond })
+
+	if srv.Draining() {
+		t.Fatal("server should not be draining before shutdown")
+	}
+	stop()
+	deadline := time.Now().Add(time.Second)
+	for !srv.Draining() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !srv.Draining() {
+		t.Fatal("expected server to report draining after shutdown started")
+	}
+	r, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("expected requests to be served during the drain delay: %v", err)
+	}
+	r.Body.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("expected clean shutdown, got %v", err)
+	}
+}
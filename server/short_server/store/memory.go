@@ -0,0 +1,581 @@
+package store
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// MemoryMovieStore is a concurrency-safe MovieStore backed by a map. It
+// mirrors MongoMovieStore's filtering, ordering and keyset pagination, and
+// its unique imdb_id index.
+type MemoryMovieStore struct {
+	mu     sync.RWMutex
+	movies map[bson.ObjectID]model.Movie
+}
+
+func NewMemoryMovieStore() *MemoryMovieStore {
+	return &MemoryMovieStore{movies: make(map[bson.ObjectID]model.Movie)}
+}
+
+func (s *MemoryMovieStore) List(ctx context.Context, q MovieQuery) ([]model.Movie, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	movies := make([]model.Movie, 0)
+	for _, m := range s.movies {
+		if matchesMovieQuery(m, q) {
+			movies = append(movies, cloneMovie(m))
+		}
+	}
+	slices.SortFunc(movies, func(a, b model.Movie) int {
+		return compareMovies(a, b, q)
+	})
+	if q.Limit > 0 && len(movies) > q.Limit {
+		movies = movies[:q.Limit]
+	}
+	return movies, nil
+}
+
+func (s *MemoryMovieStore) Get(ctx context.Context, id bson.ObjectID) (model.Movie, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.movies[id]
+	if !ok {
+		return model.Movie{}, ErrNotFound
+	}
+	return cloneMovie(m), nil
+}
+
+func (s *MemoryMovieStore) GetByImdbID(ctx context.Context, imdbID string) (model.Movie, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, m := range s.movies {
+		if m.ImdbID == imdbID {
+			return cloneMovie(m), nil
+		}
+	}
+	return model.Movie{}, ErrNotFound
+}
+
+func (s *MemoryMovieStore) Insert(ctx context.Context, movie model.Movie) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.movies[movie.ID]; ok || s.imdbIDTaken(movie) {
+		return ErrDuplicateKey
+	}
+	s.movies[movie.ID] = cloneMovie(movie)
+	return nil
+}
+
+func (s *MemoryMovieStore) Replace(ctx context.Context, movie model.Movie) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.movies[movie.ID]; !ok {
+		return ErrNotFound
+	}
+	if s.imdbIDTaken(movie) {
+		return ErrDuplicateKey
+	}
+	s.movies[movie.ID] = cloneMovie(movie)
+	return nil
+}
+
+// imdbIDTaken reports whether a movie other than movie has its imdb_id.
+func (s *MemoryMovieStore) imdbIDTaken(movie model.Movie) bool {
+	for id, m := range s.movies {
+		if id != movie.ID && m.ImdbID == movie.ImdbID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryMovieStore) Delete(ctx context.Context, id bson.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.movies[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.movies, id)
+	return nil
+}
+
+func (s *MemoryMovieStore) RenameGenre(ctx context.Context, genreID int, name string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for id, m := range s.movies {
+		if renameGenre(m.Genre, genreID, name) {
+			s.movies[id] = m
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *MemoryMovieStore) CountWithGenre(ctx context.Context, genreID int) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var n int64
+	for _, m := range s.movies {
+		if hasGenre(m.Genre, genreID) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func matchesMovieQuery(m model.Movie, q MovieQuery) bool {
+	if q.GenreID != 0 && !slices.ContainsFunc(m.Genre, func(g model.Genre) bool { return g.GenreID == q.GenreID }) {
+		return false
+	}
+	if q.MinRank != 0 && m.Ranking.RankingValue < q.MinRank {
+		return false
+	}
+	if q.MaxRank != 0 && m.Ranking.RankingValue > q.MaxRank {
+		return false
+	}
+	if q.TitlePrefix != "" && !strings.HasPrefix(strings.ToLower(m.Title), strings.ToLower(q.TitlePrefix)) {
+		return false
+	}
+	if q.After != nil {
+		after := model.Movie{ID: q.After.ID, Title: q.After.Title, Ranking: model.Ranking{RankingValue: q.After.Rank}}
+		if compareMovies(m, after, q) <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// compareMovies orders a before b according to q, breaking ties on _id in
+// the same direction as Mongo does with movieSort.
+func compareMovies(a, b model.Movie, q MovieQuery) int {
+	var c int
+	switch q.SortBy {
+	case MovieSortTitle:
+		c = cmp.Compare(a.Title, b.Title)
+	case MovieSortRanking:
+		c = cmp.Compare(a.Ranking.RankingValue, b.Ranking.RankingValue)
+	}
+	if c == 0 {
+		c = bytes.Compare(a.ID[:], b.ID[:])
+	}
+	if q.Desc {
+		c = -c
+	}
+	return c
+}
+
+func cloneMovie(m model.Movie) model.Movie {
+	m.Genre = slices.Clone(m.Genre)
+	return m
+}
+
+// MemoryUserStore is a concurrency-safe UserStore backed by a map. Like the
+// Mongo store it enforces unique emails and never returns credentials from
+// List, Get or Update.
+type MemoryUserStore struct {
+	mu    sync.RWMutex
+	users map[bson.ObjectID]model.User
+}
+
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{users: make(map[bson.ObjectID]model.User)}
+}
+
+func (s *MemoryUserStore) List(ctx context.Context) ([]model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]model.User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, stripCredentials(cloneUser(u)))
+	}
+	slices.SortFunc(users, func(a, b model.User) int { return bytes.Compare(a.ID[:], b.ID[:]) })
+	return users, nil
+}
+
+func (s *MemoryUserStore) Get(ctx context.Context, id bson.ObjectID) (model.User, error) {
+	u, err := s.GetWithCredentials(ctx, id)
+	return stripCredentials(u), err
+}
+
+func (s *MemoryUserStore) GetWithCredentials(ctx context.Context, id bson.ObjectID) (model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return model.User{}, ErrNotFound
+	}
+	return cloneUser(u), nil
+}
+
+func (s *MemoryUserStore) GetByEmailWithCredentials(ctx context.Context, email string) (model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.Email == email {
+			return cloneUser(u), nil
+		}
+	}
+	return model.User{}, ErrNotFound
+}
+
+func (s *MemoryUserStore) Insert(ctx context.Context, user model.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[user.ID]; ok {
+		return ErrDuplicateKey
+	}
+	for _, u := range s.users {
+		if user.Email != "" && u.Email == user.Email {
+			return ErrDuplicateKey
+		}
+	}
+	s.users[user.ID] = cloneUser(user)
+	return nil
+}
+
+func (s *MemoryUserStore) Update(ctx context.Context, id bson.ObjectID, upd UserUpdate) (model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.update(id, upd)
+}
+
+func (s *MemoryUserStore) UpdateKeepingAdmin(ctx context.Context, id bson.ObjectID, upd UserUpdate) (model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[id]; ok && removesAdmin(u, &upd) && !s.otherActiveAdmin(id) {
+		return model.User{}, ErrLastAdmin
+	}
+	return s.update(id, upd)
+}
+
+func (s *MemoryUserStore) update(id bson.ObjectID, upd UserUpdate) (model.User, error) {
+	u, ok := s.users[id]
+	if !ok {
+		return model.User{}, ErrNotFound
+	}
+	if upd.FirstName != nil {
+		u.FirstName = *upd.FirstName
+	}
+	if upd.LastName != nil {
+		u.LastName = *upd.LastName
+	}
+	if upd.FavouriteGenres != nil {
+		u.FavouriteGenres = slices.Clone(*upd.FavouriteGenres)
+	}
+	if upd.Role != nil {
+		u.Role = *upd.Role
+	}
+	if upd.Status != nil {
+		u.Status = *upd.Status
+	}
+	if upd.Password != nil {
+		u.Password = *upd.Password
+	}
+	if upd.Token != nil {
+		u.Token = *upd.Token
+	}
+	if upd.RefreshToken != nil {
+		u.RefreshToken = *upd.RefreshToken
+	}
+	u.UpdatedAt = now()
+	s.users[id] = u
+	return stripCredentials(cloneUser(u)), nil
+}
+
+func (s *MemoryUserStore) RotateRefreshToken(ctx context.Context, id bson.ObjectID, current, access, refresh string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok || u.RefreshToken != current {
+		return ErrNotFound
+	}
+	u.Token = access
+	u.RefreshToken = refresh
+	u.UpdatedAt = now()
+	s.users[id] = u
+	return nil
+}
+
+func (s *MemoryUserStore) CountActiveAdmins(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var n int64
+	for _, u := range s.users {
+		if u.Role == model.RoleAdmin && !u.IsDisabled() {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *MemoryUserStore) Delete(ctx context.Context, id bson.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.users, id)
+	return nil
+}
+
+func (s *MemoryUserStore) DeleteKeepingAdmin(ctx context.Context, id bson.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if removesAdmin(u, nil) && !s.otherActiveAdmin(id) {
+		return ErrLastAdmin
+	}
+	delete(s.users, id)
+	return nil
+}
+
+// otherActiveAdmin reports whether an active admin other than id exists.
+// The caller holds s.mu.
+func (s *MemoryUserStore) otherActiveAdmin(id bson.ObjectID) bool {
+	for _, u := range s.users {
+		if u.ID != id && u.Role == model.RoleAdmin && !u.IsDisabled() {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryUserStore) RenameGenre(ctx context.Context, genreID int, name string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for _, u := range s.users {
+		if renameGenre(u.FavouriteGenres, genreID, name) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *MemoryUserStore) CountWithGenre(ctx context.Context, genreID int) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var n int64
+	for _, u := range s.users {
+		if hasGenre(u.FavouriteGenres, genreID) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// renameGenre renames the matching genres in place, which the stores may do
+// because they only hand out clones, and reports whether any changed.
+func renameGenre(genres []model.Genre, genreID int, name string) bool {
+	changed := false
+	for i := range genres {
+		if genres[i].GenreID == genreID && genres[i].GenreName != name {
+			genres[i].GenreName = name
+			changed = true
+		}
+	}
+	return changed
+}
+
+func hasGenre(genres []model.Genre, genreID int) bool {
+	return slices.ContainsFunc(genres, func(g model.Genre) bool { return g.GenreID == genreID })
+}
+
+func cloneUser(u model.User) model.User {
+	u.FavouriteGenres = slices.Clone(u.FavouriteGenres)
+	return u
+}
+
+// MemoryAuditStore keeps audit entries in insertion order.
+type MemoryAuditStore struct {
+	mu      sync.Mutex
+	entries []model.AuditEntry
+}
+
+func NewMemoryAuditStore() *MemoryAuditStore {
+	return &MemoryAuditStore{}
+}
+
+func (s *MemoryAuditStore) Record(ctx context.Context, entry model.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry.ID.IsZero() {
+		entry.ID = bson.NewObjectID()
+	}
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+// Entries returns a copy of the recorded entries.
+func (s *MemoryAuditStore) Entries() []model.AuditEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.entries)
+}
+
+// MemoryGenreStore is a concurrency-safe GenreStore backed by a map, with the
+// same unique ID and case-insensitive name rules as the Mongo indexes.
+type MemoryGenreStore struct {
+	mu     sync.RWMutex
+	genres map[int]model.Genre
+}
+
+func NewMemoryGenreStore(genres ...model.Genre) *MemoryGenreStore {
+	s := &MemoryGenreStore{genres: make(map[int]model.Genre)}
+	for _, g := range genres {
+		s.genres[g.GenreID] = g
+	}
+	return s
+}
+
+func (s *MemoryGenreStore) List(ctx context.Context) ([]model.Genre, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	genres := make([]model.Genre, 0, len(s.genres))
+	for _, g := range s.genres {
+		genres = append(genres, g)
+	}
+	slices.SortFunc(genres, func(a, b model.Genre) int { return a.GenreID - b.GenreID })
+	return genres, nil
+}
+
+func (s *MemoryGenreStore) Get(ctx context.Context, id int) (model.Genre, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	g, ok := s.genres[id]
+	if !ok {
+		return model.Genre{}, ErrNotFound
+	}
+	return g, nil
+}
+
+func (s *MemoryGenreStore) Insert(ctx context.Context, genre model.Genre) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.genres[genre.GenreID]; ok || s.nameTaken(genre) {
+		return ErrDuplicateKey
+	}
+	s.genres[genre.GenreID] = genre
+	return nil
+}
+
+func (s *MemoryGenreStore) Update(ctx context.Context, genre model.Genre) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.genres[genre.GenreID]; !ok {
+		return ErrNotFound
+	}
+	if s.nameTaken(genre) {
+		return ErrDuplicateKey
+	}
+	s.genres[genre.GenreID] = genre
+	return nil
+}
+
+func (s *MemoryGenreStore) Delete(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.genres[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.genres, id)
+	return nil
+}
+
+// nameTaken reports whether another genre has genre's name, ignoring case.
+func (s *MemoryGenreStore) nameTaken(genre model.Genre) bool {
+	for id, g := range s.genres {
+		if id != genre.GenreID && strings.EqualFold(g.GenreName, genre.GenreName) {
+			return true
+		}
+	}
+	return false
+}
+
+// MemoryRankingStore is a concurrency-safe RankingStore backed by a map.
+type MemoryRankingStore struct {
+	mu       sync.RWMutex
+	rankings map[int]model.Ranking
+}
+
+func NewMemoryRankingStore(rankings ...model.Ranking) *MemoryRankingStore {
+	s := &MemoryRankingStore{rankings: make(map[int]model.Ranking)}
+	for _, r := range rankings {
+		s.rankings[r.RankingValue] = r
+	}
+	return s
+}
+
+func (s *MemoryRankingStore) List(ctx context.Context) ([]model.Ranking, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rankings := make([]model.Ranking, 0, len(s.rankings))
+	for _, r := range s.rankings {
+		rankings = append(rankings, r)
+	}
+	slices.SortFunc(rankings, func(a, b model.Ranking) int { return cmp.Compare(a.RankingValue, b.RankingValue) })
+	return rankings, nil
+}
+
+func (s *MemoryRankingStore) Get(ctx context.Context, value int) (model.Ranking, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.rankings[value]
+	if !ok {
+		return model.Ranking{}, ErrNotFound
+	}
+	return r, nil
+}
+
+func (s *MemoryRankingStore) Insert(ctx context.Context, ranking model.Ranking) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.rankings {
+		if r.RankingValue == ranking.RankingValue || strings.EqualFold(r.RankingName, ranking.RankingName) {
+			return ErrDuplicateKey
+		}
+	}
+	s.rankings[ranking.RankingValue] = ranking
+	return nil
+}
@@ -0,0 +1,249 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestMemoryUserStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryUserStore()
+	u := model.User{ID: bson.NewObjectID(), Email: "a@example.com", Password: "hash", RefreshToken: "r1", Role: model.RoleAdmin}
+	if err := s.Insert(ctx, u); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	t.Run("duplicate email", func(t *testing.T) {
+		err := s.Insert(ctx, model.User{ID: bson.NewObjectID(), Email: "a@example.com"})
+		if !errors.Is(err, ErrDuplicateKey) {
+			t.Fatalf("expected ErrDuplicateKey, got %v", err)
+		}
+	})
+
+	t.Run("get strips credentials", func(t *testing.T) {
+		got, err := s.Get(ctx, u.ID)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		if got.Password != "" || got.RefreshToken != "" {
+			t.Fatalf("credentials leaked: %+v", got)
+		}
+		full, _ := s.GetWithCredentials(ctx, u.ID)
+		if full.Password != "hash" {
+			t.Fatalf("expected credentials, got %+v", full)
+		}
+	})
+
+	t.Run("rotate refresh token is compare-and-swap", func(t *testing.T) {
+		if err := s.RotateRefreshToken(ctx, u.ID, "r1", "a2", "r2"); err != nil {
+			t.Fatalf("rotate: %v", err)
+		}
+		if err := s.RotateRefreshToken(ctx, u.ID, "r1", "a3", "r3"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected stale token to fail, got %v", err)
+		}
+	})
+
+	t.Run("count active admins ignores disabled", func(t *testing.T) {
+		disabled := model.UserStatusDisabled
+		if _, err := s.Update(ctx, u.ID, UserUpdate{Status: &disabled}); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		if n, _ := s.CountActiveAdmins(ctx); n != 0 {
+			t.Fatalf("expected 0 active admins, got %d", n)
+		}
+	})
+
+	t.Run("missing user", func(t *testing.T) {
+		if err := s.Delete(ctx, bson.NewObjectID()); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestMemoryUserStoreKeepsAdmin(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryUserStore()
+	a := model.User{ID: bson.NewObjectID(), Email: "a@example.com", Role: model.RoleAdmin}
+	b := model.User{ID: bson.NewObjectID(), Email: "b@example.com", Role: model.RoleAdmin}
+	for _, u := range []model.User{a, b} {
+		if err := s.Insert(ctx, u); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	demote := model.RoleUser
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, id := range []bson.ObjectID{a.ID, b.ID} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = s.UpdateKeepingAdmin(ctx, id, UserUpdate{Role: &demote})
+		}()
+	}
+	wg.Wait()
+	if (errs[0] == nil) == (errs[1] == nil) || !errors.Is(errors.Join(errs...), ErrLastAdmin) {
+		t.Fatalf("expected exactly one demotion to fail with ErrLastAdmin, got %v", errs)
+	}
+	if n, _ := s.CountActiveAdmins(ctx); n != 1 {
+		t.Fatalf("expected one admin left, got %d", n)
+	}
+
+	last := a.ID
+	if errs[0] == nil {
+		last = b.ID
+	}
+	if err := s.DeleteKeepingAdmin(ctx, last); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("expected deleting the last admin to fail, got %v", err)
+	}
+	name := "Ann"
+	if _, err := s.UpdateKeepingAdmin(ctx, last, UserUpdate{FirstName: &name}); err != nil {
+		t.Fatalf("changes that keep the admin must pass, got %v", err)
+	}
+	if err := s.DeleteKeepingAdmin(ctx, bson.NewObjectID()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMemoryMovieStoreList(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryMovieStore()
+	for i, title := range []string{"Casablanca", "alien", "Brazil"} {
+		m := model.Movie{ID: bson.NewObjectID(), ImdbID: "tt" + title, Title: title, Ranking: model.Ranking{RankingValue: i + 1}}
+		if err := s.Insert(ctx, m); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	page, _ := s.List(ctx, MovieQuery{SortBy: MovieSortRanking, Desc: true, Limit: 2})
+	if len(page) != 2 || page[0].Title != "Brazil" || page[1].Title != "alien" {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+	last := page[1]
+	rest, _ := s.List(ctx, MovieQuery{
+		SortBy: MovieSortRanking, Desc: true, Limit: 2,
+		After: &MovieCursor{ID: last.ID, Rank: last.Ranking.RankingValue},
+	})
+	if len(rest) != 1 || rest[0].Title != "Casablanca" {
+		t.Fatalf("unexpected second page %+v", rest)
+	}
+
+	filtered, _ := s.List(ctx, MovieQuery{TitlePrefix: "AL"})
+	if len(filtered) != 1 || filtered[0].Title != "alien" {
+		t.Fatalf("expected case-insensitive prefix match, got %+v", filtered)
+	}
+}
+
+func TestMemoryMovieStoreUniqueImdbID(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryMovieStore()
+	alien := model.Movie{ID: bson.NewObjectID(), ImdbID: "tt0078748", Title: "Alien"}
+	brazil := model.Movie{ID: bson.NewObjectID(), ImdbID: "tt0088846", Title: "Brazil"}
+	for _, m := range []model.Movie{alien, brazil} {
+		if err := s.Insert(ctx, m); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	dup := model.Movie{ID: bson.NewObjectID(), ImdbID: alien.ImdbID}
+	if err := s.Insert(ctx, dup); !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("expected ErrDuplicateKey on insert, got %v", err)
+	}
+	brazil.ImdbID = alien.ImdbID
+	if err := s.Replace(ctx, brazil); !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("expected ErrDuplicateKey on replace, got %v", err)
+	}
+	alien.Title = "Alien (1979)"
+	if err := s.Replace(ctx, alien); err != nil {
+		t.Fatalf("replacing a movie keeps its own imdb_id: %v", err)
+	}
+
+	got, err := s.GetByImdbID(ctx, alien.ImdbID)
+	if err != nil || got.ID != alien.ID {
+		t.Fatalf("GetByImdbID = %+v, %v", got, err)
+	}
+	if _, err := s.GetByImdbID(ctx, "tt0000000"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMemoryGenreStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryGenreStore(model.Genre{GenreID: 2, GenreName: "Drama"}, model.Genre{GenreID: 1, GenreName: "Comedy"})
+
+	if err := s.Insert(ctx, model.Genre{GenreID: 3, GenreName: "DRAMA"}); !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("expected ErrDuplicateKey for a name differing in case, got %v", err)
+	}
+	if err := s.Update(ctx, model.Genre{GenreID: 1, GenreName: "drama"}); !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("expected ErrDuplicateKey on rename, got %v", err)
+	}
+	if err := s.Update(ctx, model.Genre{GenreID: 1, GenreName: "comedy"}); err != nil {
+		t.Fatalf("a genre may change the case of its own name: %v", err)
+	}
+	if err := s.Update(ctx, model.Genre{GenreID: 9, GenreName: "Horror"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	genres, _ := s.List(ctx)
+	if len(genres) != 2 || genres[0].GenreID != 1 || genres[1].GenreID != 2 {
+		t.Fatalf("expected genres ordered by ID, got %+v", genres)
+	}
+}
+
+func TestMemoryStoresRenameGenre(t *testing.T) {
+	ctx := context.Background()
+	movies := NewMemoryMovieStore()
+	users := NewMemoryUserStore()
+	drama := []model.Genre{{GenreID: 1, GenreName: "Drama"}, {GenreID: 2, GenreName: "Comedy"}}
+	if err := movies.Insert(ctx, model.Movie{ID: bson.NewObjectID(), ImdbID: "tt1", Genre: drama}); err != nil {
+		t.Fatalf("insert movie: %v", err)
+	}
+	if err := movies.Insert(ctx, model.Movie{ID: bson.NewObjectID(), ImdbID: "tt2", Genre: []model.Genre{{GenreID: 2, GenreName: "Comedy"}}}); err != nil {
+		t.Fatalf("insert movie: %v", err)
+	}
+	u := model.User{ID: bson.NewObjectID(), Email: "a@example.com", FavouriteGenres: []model.Genre{{GenreID: 1, GenreName: "drama"}}}
+	if err := users.Insert(ctx, u); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+
+	if n, _ := movies.RenameGenre(ctx, 1, "Dramas"); n != 1 {
+		t.Fatalf("expected 1 movie renamed, got %d", n)
+	}
+	if n, _ := users.RenameGenre(ctx, 1, "Dramas"); n != 1 {
+		t.Fatalf("expected 1 user renamed, got %d", n)
+	}
+	if n, _ := users.RenameGenre(ctx, 1, "Dramas"); n != 0 {
+		t.Fatalf("expected a repeated rename to change nothing, got %d", n)
+	}
+	if drama[0].GenreName != "Drama" {
+		t.Fatal("rename must not reach the caller's slice")
+	}
+	got, _ := users.Get(ctx, u.ID)
+	if got.FavouriteGenres[0].GenreName != "Dramas" {
+		t.Fatalf("expected renamed favourite genre, got %+v", got.FavouriteGenres)
+	}
+	if n, _ := movies.CountWithGenre(ctx, 2); n != 2 {
+		t.Fatalf("expected 2 movies with genre 2, got %d", n)
+	}
+}
+
+func TestMemoryRankingStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryRankingStore(model.Ranking{RankingValue: 2, RankingName: "Good"}, model.Ranking{RankingValue: 1, RankingName: "Excellent"})
+
+	if err := s.Insert(ctx, model.Ranking{RankingValue: 3, RankingName: "good"}); !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("expected ErrDuplicateKey for a name differing in case, got %v", err)
+	}
+	if _, err := s.Get(ctx, 999); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	rankings, _ := s.List(ctx)
+	if len(rankings) != 2 || rankings[0].RankingValue != 1 {
+		t.Fatalf("expected rankings ordered by value, got %+v", rankings)
+	}
+}
@@ -0,0 +1,605 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const defaultQueryTimeout = 10 * time.Second
+
+// queryTimeout bounds every store call; non-positive values fall back to
+// defaultQueryTimeout.
+func queryTimeout(d time.Duration) time.Duration {
+	if d <= 0 {
+		return defaultQueryTimeout
+	}
+	return d
+}
+
+var (
+	// userPublicProjection keeps credentials out of every read that serves
+	// a user to a client.
+	userPublicProjection = bson.M{"password": 0, "token": 0, "refresh_token": 0}
+)
+
+type MongoMovieStore struct {
+	col     *mongo.Collection
+	timeout time.Duration
+}
+
+func NewMongoMovieStore(col *mongo.Collection, timeout time.Duration) *MongoMovieStore {
+	return &MongoMovieStore{col: col, timeout: queryTimeout(timeout)}
+}
+
+func (s *MongoMovieStore) List(ctx context.Context, q MovieQuery) ([]model.Movie, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(movieSort(q))
+	if q.Limit > 0 {
+		opts.SetLimit(int64(q.Limit))
+	}
+	cursor, err := s.col.Find(ctx, movieFilter(q), opts)
+	if err != nil {
+		return nil, fmt.Errorf("find movies: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	movies := make([]model.Movie, 0)
+	if err := cursor.All(ctx, &movies); err != nil {
+		return nil, fmt.Errorf("decode movies: %w", err)
+	}
+	return movies, nil
+}
+
+func (s *MongoMovieStore) Get(ctx context.Context, id bson.ObjectID) (model.Movie, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var movie model.Movie
+	if err := s.col.FindOne(ctx, bson.M{"_id": id}).Decode(&movie); err != nil {
+		return movie, mapErr("find movie", err)
+	}
+	return movie, nil
+}
+
+func (s *MongoMovieStore) GetByImdbID(ctx context.Context, imdbID string) (model.Movie, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var movie model.Movie
+	if err := s.col.FindOne(ctx, bson.M{"imdb_id": imdbID}).Decode(&movie); err != nil {
+		return movie, mapErr("find movie by imdb_id", err)
+	}
+	return movie, nil
+}
+
+func (s *MongoMovieStore) Insert(ctx context.Context, movie model.Movie) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.col.InsertOne(ctx, movie)
+	return mapErr("insert movie", err)
+}
+
+func (s *MongoMovieStore) Replace(ctx context.Context, movie model.Movie) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return mapErr("replace movie", s.col.FindOneAndReplace(ctx, bson.M{"_id": movie.ID}, movie).Err())
+}
+
+func (s *MongoMovieStore) Delete(ctx context.Context, id bson.ObjectID) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return mapErr("delete movie", s.col.FindOneAndDelete(ctx, bson.M{"_id": id}).Err())
+}
+
+func (s *MongoMovieStore) RenameGenre(ctx context.Context, genreID int, name string) (int64, error) {
+	return renameEmbeddedGenre(ctx, s.col, s.timeout, "genre", genreID, name)
+}
+
+func (s *MongoMovieStore) CountWithGenre(ctx context.Context, genreID int) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	n, err := s.col.CountDocuments(ctx, bson.M{"genre.genre_id": genreID})
+	if err != nil {
+		return 0, fmt.Errorf("count movies with genre: %w", err)
+	}
+	return n, nil
+}
+
+func movieSortField(q MovieQuery) string {
+	switch q.SortBy {
+	case MovieSortTitle:
+		return "title"
+	case MovieSortRanking:
+		return "ranking.ranking_value"
+	default:
+		return "_id"
+	}
+}
+
+func movieSort(q MovieQuery) bson.D {
+	field := movieSortField(q)
+	dir := 1
+	if q.Desc {
+		dir = -1
+	}
+	if field == "_id" {
+		return bson.D{{Key: "_id", Value: dir}}
+	}
+	return bson.D{{Key: field, Value: dir}, {Key: "_id", Value: dir}}
+}
+
+// movieFilter translates a MovieQuery, including its keyset cursor, into a
+// Mongo filter.
+func movieFilter(q MovieQuery) bson.M {
+	clauses := bson.A{}
+	if q.GenreID != 0 {
+		clauses = append(clauses, bson.M{"genre.genre_id": q.GenreID})
+	}
+	if q.MinRank != 0 || q.MaxRank != 0 {
+		rank := bson.M{}
+		if q.MinRank != 0 {
+			rank["$gte"] = q.MinRank
+		}
+		if q.MaxRank != 0 {
+			rank["$lte"] = q.MaxRank
+		}
+		clauses = append(clauses, bson.M{"ranking.ranking_value": rank})
+	}
+	if q.TitlePrefix != "" {
+		clauses = append(clauses, bson.M{"title": bson.M{
+			"$regex": "^" + regexp.QuoteMeta(q.TitlePrefix), "$options": "i",
+		}})
+	}
+	if q.After != nil {
+		clauses = append(clauses, afterFilter(q))
+	}
+
+	switch len(clauses) {
+	case 0:
+		return bson.M{}
+	case 1:
+		return clauses[0].(bson.M)
+	default:
+		return bson.M{"$and": clauses}
+	}
+}
+
+func afterFilter(q MovieQuery) bson.M {
+	field := movieSortField(q)
+	op := "$gt"
+	if q.Desc {
+		op = "$lt"
+	}
+	if field == "_id" {
+		return bson.M{"_id": bson.M{op: q.After.ID}}
+	}
+
+	var value any = q.After.Title
+	if field != "title" {
+		value = q.After.Rank
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{field: bson.M{op: value}},
+		bson.M{field: value, "_id": bson.M{op: q.After.ID}},
+	}}
+}
+
+type MongoUserStore struct {
+	col     *mongo.Collection
+	timeout time.Duration
+}
+
+func NewMongoUserStore(col *mongo.Collection, timeout time.Duration) *MongoUserStore {
+	return &MongoUserStore{col: col, timeout: queryTimeout(timeout)}
+}
+
+func (s *MongoUserStore) List(ctx context.Context) ([]model.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	cursor, err := s.col.Find(ctx, bson.M{}, options.Find().SetProjection(userPublicProjection))
+	if err != nil {
+		return nil, fmt.Errorf("find users: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	users := make([]model.User, 0)
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, fmt.Errorf("decode users: %w", err)
+	}
+	return users, nil
+}
+
+func (s *MongoUserStore) Get(ctx context.Context, id bson.ObjectID) (model.User, error) {
+	return s.findOne(ctx, bson.M{"_id": id}, options.FindOne().SetProjection(userPublicProjection))
+}
+
+func (s *MongoUserStore) GetWithCredentials(ctx context.Context, id bson.ObjectID) (model.User, error) {
+	return s.findOne(ctx, bson.M{"_id": id}, options.FindOne())
+}
+
+func (s *MongoUserStore) GetByEmailWithCredentials(ctx context.Context, email string) (model.User, error) {
+	return s.findOne(ctx, bson.M{"email": email}, options.FindOne())
+}
+
+func (s *MongoUserStore) findOne(ctx context.Context, filter bson.M, opts *options.FindOneOptionsBuilder) (model.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var user model.User
+	if err := s.col.FindOne(ctx, filter, opts).Decode(&user); err != nil {
+		return user, mapErr("find user", err)
+	}
+	return user, nil
+}
+
+func (s *MongoUserStore) Insert(ctx context.Context, user model.User) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.col.InsertOne(ctx, user)
+	return mapErr("insert user", err)
+}
+
+func (s *MongoUserStore) Update(ctx context.Context, id bson.ObjectID, u UserUpdate) (model.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(userPublicProjection)
+
+	var user model.User
+	if err := s.col.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$set": userUpdateSet(u)}, opts).Decode(&user); err != nil {
+		return user, mapErr("update user", err)
+	}
+	return user, nil
+}
+
+func userUpdateSet(u UserUpdate) bson.M {
+	set := bson.M{"updated_at": now()}
+	if u.FirstName != nil {
+		set["first_name"] = *u.FirstName
+	}
+	if u.LastName != nil {
+		set["last_name"] = *u.LastName
+	}
+	if u.FavouriteGenres != nil {
+		set["favourite_genres"] = *u.FavouriteGenres
+	}
+	if u.Role != nil {
+		set["role"] = *u.Role
+	}
+	if u.Status != nil {
+		set["status"] = *u.Status
+	}
+	if u.Password != nil {
+		set["password"] = *u.Password
+	}
+	if u.Token != nil {
+		set["token"] = *u.Token
+	}
+	if u.RefreshToken != nil {
+		set["refresh_token"] = *u.RefreshToken
+	}
+	return set
+}
+
+func (s *MongoUserStore) RotateRefreshToken(ctx context.Context, id bson.ObjectID, current, access, refresh string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	// Match on the presented token so that two concurrent refreshes with the
+	// same token cannot both succeed.
+	res, err := s.col.UpdateOne(ctx,
+		bson.M{"_id": id, "refresh_token": current},
+		bson.M{"$set": bson.M{
+			"token":         access,
+			"refresh_token": refresh,
+			"updated_at":    now(),
+		}},
+	)
+	if err != nil {
+		return fmt.Errorf("rotate refresh token: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoUserStore) CountActiveAdmins(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	n, err := s.col.CountDocuments(ctx, bson.M{
+		"role":   model.RoleAdmin,
+		"status": bson.M{"$ne": model.UserStatusDisabled},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("count admins: %w", err)
+	}
+	return n, nil
+}
+
+func (s *MongoUserStore) Delete(ctx context.Context, id bson.ObjectID) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return mapErr("delete user", s.col.FindOneAndDelete(ctx, bson.M{"_id": id}).Err())
+}
+
+func (s *MongoUserStore) UpdateKeepingAdmin(ctx context.Context, id bson.ObjectID, u UserUpdate) (model.User, error) {
+	before, err := s.findOne(ctx, bson.M{"_id": id}, options.FindOne().SetProjection(bson.M{"role": 1, "status": 1}))
+	if err != nil {
+		return model.User{}, err
+	}
+	user, err := s.Update(ctx, id, u)
+	if err != nil || !removesAdmin(before, &u) {
+		return user, err
+	}
+	if err := s.ensureAdminLeft(ctx, before); err != nil {
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+// DeleteKeepingAdmin disables an active admin under the guard before
+// deleting it. If the delete then fails the user stays disabled.
+func (s *MongoUserStore) DeleteKeepingAdmin(ctx context.Context, id bson.ObjectID) error {
+	before, err := s.findOne(ctx, bson.M{"_id": id}, options.FindOne().SetProjection(bson.M{"role": 1, "status": 1}))
+	if err != nil {
+		return err
+	}
+	if removesAdmin(before, nil) {
+		disabled := model.UserStatusDisabled
+		if _, err := s.Update(ctx, id, UserUpdate{Status: &disabled}); err != nil {
+			return err
+		}
+		if err := s.ensureAdminLeft(ctx, before); err != nil {
+			return err
+		}
+	}
+	return s.Delete(ctx, id)
+}
+
+// ensureAdminLeft is the compensating half of the last-admin guard. It runs
+// after a write took away the active admin before, and if no active admin
+// is left it puts back before's role and status and returns ErrLastAdmin.
+// Each guarded write counts only after making its own change, so of two
+// racing writes removing the last two admins at least one sees both changes
+// and undoes its own; at worst both are undone and both callers retry.
+func (s *MongoUserStore) ensureAdminLeft(ctx context.Context, before model.User) error {
+	admins, err := s.CountActiveAdmins(ctx)
+	if err == nil && admins > 0 {
+		return nil
+	}
+
+	restore := bson.M{"$set": bson.M{"role": before.Role, "status": before.Status, "updated_at": now()}}
+	if before.Status == "" {
+		// Users stored before statuses existed have none.
+		restore = bson.M{"$set": bson.M{"role": before.Role, "updated_at": now()}, "$unset": bson.M{"status": ""}}
+	}
+	restoreCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	_, restoreErr := s.col.UpdateOne(restoreCtx, bson.M{"_id": before.ID}, restore)
+	if restoreErr != nil {
+		return fmt.Errorf("restore admin %s: %w", before.ID.Hex(), errors.Join(err, restoreErr))
+	}
+	if err != nil {
+		return err
+	}
+	return ErrLastAdmin
+}
+
+func (s *MongoUserStore) RenameGenre(ctx context.Context, genreID int, name string) (int64, error) {
+	return renameEmbeddedGenre(ctx, s.col, s.timeout, "favourite_genres", genreID, name)
+}
+
+func (s *MongoUserStore) CountWithGenre(ctx context.Context, genreID int) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	n, err := s.col.CountDocuments(ctx, bson.M{"favourite_genres.genre_id": genreID})
+	if err != nil {
+		return 0, fmt.Errorf("count users with genre: %w", err)
+	}
+	return n, nil
+}
+
+// renameEmbeddedGenre sets genre_name on every element of the array field
+// whose genre_id matches, across the collection.
+func renameEmbeddedGenre(ctx context.Context, col *mongo.Collection, timeout time.Duration, field string, genreID int, name string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	res, err := col.UpdateMany(ctx,
+		bson.M{field: bson.M{"$elemMatch": bson.M{"genre_id": genreID, "genre_name": bson.M{"$ne": name}}}},
+		bson.M{"$set": bson.M{field + ".$[g].genre_name": name}},
+		options.UpdateMany().SetArrayFilters([]any{bson.M{"g.genre_id": genreID}}),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("rename genre in %s: %w", col.Name(), err)
+	}
+	return res.ModifiedCount, nil
+}
+
+type MongoGenreStore struct {
+	col     *mongo.Collection
+	timeout time.Duration
+}
+
+func NewMongoGenreStore(col *mongo.Collection, timeout time.Duration) *MongoGenreStore {
+	return &MongoGenreStore{col: col, timeout: queryTimeout(timeout)}
+}
+
+func (s *MongoGenreStore) List(ctx context.Context) ([]model.Genre, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	cursor, err := s.col.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "genre_id", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("find genres: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	genres := make([]model.Genre, 0)
+	if err := cursor.All(ctx, &genres); err != nil {
+		return nil, fmt.Errorf("decode genres: %w", err)
+	}
+	return genres, nil
+}
+
+func (s *MongoGenreStore) Get(ctx context.Context, id int) (model.Genre, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var genre model.Genre
+	if err := s.col.FindOne(ctx, bson.M{"genre_id": id}).Decode(&genre); err != nil {
+		return genre, mapErr("find genre", err)
+	}
+	return genre, nil
+}
+
+// Insert relies on the unique genre_id and case-insensitive genre_name
+// indexes declared in the schema package.
+func (s *MongoGenreStore) Insert(ctx context.Context, genre model.Genre) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.col.InsertOne(ctx, genre)
+	return mapErr("insert genre", err)
+}
+
+func (s *MongoGenreStore) Update(ctx context.Context, genre model.Genre) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	res, err := s.col.UpdateOne(ctx, bson.M{"genre_id": genre.GenreID}, bson.M{"$set": bson.M{"genre_name": genre.GenreName}})
+	if err != nil {
+		return mapErr("update genre", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoGenreStore) Delete(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return mapErr("delete genre", s.col.FindOneAndDelete(ctx, bson.M{"genre_id": id}).Err())
+}
+
+type MongoAuditStore struct {
+	col     *mongo.Collection
+	timeout time.Duration
+}
+
+func NewMongoAuditStore(col *mongo.Collection, timeout time.Duration) *MongoAuditStore {
+	return &MongoAuditStore{col: col, timeout: queryTimeout(timeout)}
+}
+
+func (s *MongoAuditStore) Record(ctx context.Context, entry model.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.col.InsertOne(ctx, entry)
+	return mapErr("insert audit entry", err)
+}
+
+// mapErr translates driver errors into the package's sentinel errors and
+// wraps everything else with the failed operation.
+func mapErr(op string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return ErrNotFound
+	case isDuplicateKeyError(err):
+		return fmt.Errorf("%s: %w", op, ErrDuplicateKey)
+	default:
+		return fmt.Errorf("%s: %w", op, err)
+	}
+}
+
+func isDuplicateKeyError(err error) bool {
+	var writeErr mongo.WriteException
+	if errors.As(err, &writeErr) {
+		for _, e := range writeErr.WriteErrors {
+			if e.Code == 11000 {
+				return true
+			}
+		}
+	}
+
+	var bulkWriteErr mongo.BulkWriteException
+	if errors.As(err, &bulkWriteErr) {
+		for _, e := range bulkWriteErr.WriteErrors {
+			if e.Code == 11000 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type MongoRankingStore struct {
+	col     *mongo.Collection
+	timeout time.Duration
+}
+
+func NewMongoRankingStore(col *mongo.Collection, timeout time.Duration) *MongoRankingStore {
+	return &MongoRankingStore{col: col, timeout: queryTimeout(timeout)}
+}
+
+func (s *MongoRankingStore) List(ctx context.Context) ([]model.Ranking, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	cursor, err := s.col.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "ranking_value", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("find rankings: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	rankings := make([]model.Ranking, 0)
+	if err := cursor.All(ctx, &rankings); err != nil {
+		return nil, fmt.Errorf("decode rankings: %w", err)
+	}
+	return rankings, nil
+}
+
+func (s *MongoRankingStore) Get(ctx context.Context, value int) (model.Ranking, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var ranking model.Ranking
+	if err := s.col.FindOne(ctx, bson.M{"ranking_value": value}).Decode(&ranking); err != nil {
+		return ranking, mapErr("find ranking", err)
+	}
+	return ranking, nil
+}
+
+func (s *MongoRankingStore) Insert(ctx context.Context, ranking model.Ranking) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.col.InsertOne(ctx, ranking)
+	return mapErr("insert ranking", err)
+}
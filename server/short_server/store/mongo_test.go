@@ -0,0 +1,74 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestMovieFilter(t *testing.T) {
+	t.Run("no params matches everything", func(t *testing.T) {
+		if got := movieFilter(MovieQuery{}); len(got) != 0 {
+			t.Fatalf("expected empty filter, got %v", got)
+		}
+	})
+
+	t.Run("combines genre, ranking range and title prefix", func(t *testing.T) {
+		got := movieFilter(MovieQuery{GenreID: 3, MinRank: 2, MaxRank: 4, TitlePrefix: "star.w"})
+		want := bson.M{"$and": bson.A{
+			bson.M{"genre.genre_id": 3},
+			bson.M{"ranking.ranking_value": bson.M{"$gte": 2, "$lte": 4}},
+			bson.M{"title": bson.M{"$regex": `^star\.w`, "$options": "i"}},
+		}}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("cursor on _id", func(t *testing.T) {
+		id := bson.NewObjectID()
+		got := movieFilter(MovieQuery{After: &MovieCursor{ID: id}})
+		want := bson.M{"_id": bson.M{"$gt": id}}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("cursor on descending ranking breaks ties on _id", func(t *testing.T) {
+		id := bson.NewObjectID()
+		q := MovieQuery{SortBy: MovieSortRanking, Desc: true, After: &MovieCursor{ID: id, Rank: 3}}
+		want := bson.M{"$or": bson.A{
+			bson.M{"ranking.ranking_value": bson.M{"$lt": 3}},
+			bson.M{"ranking.ranking_value": 3, "_id": bson.M{"$lt": id}},
+		}}
+		if got := movieFilter(q); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		if spec := movieSort(q); !reflect.DeepEqual(spec, bson.D{{Key: "ranking.ranking_value", Value: -1}, {Key: "_id", Value: -1}}) {
+			t.Fatalf("unexpected sort spec %v", spec)
+		}
+	})
+}
+
+func TestUserPublicProjection(t *testing.T) {
+	for _, f := range []string{"password", "token", "refresh_token"} {
+		if userPublicProjection[f] != 0 {
+			t.Fatalf("projection does not exclude %q", f)
+		}
+	}
+}
+
+func TestUserUpdateSet(t *testing.T) {
+	role := "ADMIN"
+	set := userUpdateSet(UserUpdate{Role: &role})
+	if set["role"] != "ADMIN" {
+		t.Fatalf("expected role in $set, got %v", set)
+	}
+	if _, ok := set["updated_at"]; !ok {
+		t.Fatal("expected updated_at to be bumped")
+	}
+	if _, ok := set["password"]; ok {
+		t.Fatal("expected nil fields to be left out")
+	}
+}
@@ -0,0 +1,158 @@
+// Package store defines the persistence interfaces used by the HTTP handlers
+// together with a MongoDB implementation and an in-memory implementation with
+// the same semantics for tests.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	model "github.com/beheryahmed1991/ClipsStream/server/short_server/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var (
+	// ErrNotFound is returned when the addressed document does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrDuplicateKey is returned when a write violates a unique index.
+	ErrDuplicateKey = errors.New("duplicate key")
+	// ErrLastAdmin is returned when a write would leave no active admin.
+	ErrLastAdmin = errors.New("last active admin")
+)
+
+const (
+	MovieSortID      = "_id"
+	MovieSortTitle   = "title"
+	MovieSortRanking = "ranking"
+)
+
+// MovieCursor is the keyset position of the last movie of a page.
+type MovieCursor struct {
+	ID    bson.ObjectID
+	Title string
+	Rank  int
+}
+
+// MovieQuery selects a page of movies. Zero values mean "no filter".
+type MovieQuery struct {
+	GenreID     int
+	MinRank     int
+	MaxRank     int
+	TitlePrefix string
+	SortBy      string
+	Desc        bool
+	After       *MovieCursor
+	Limit       int
+}
+
+// MovieStore persists movies. imdb_id is unique: Insert and Replace return
+// ErrDuplicateKey when another movie already has the same one.
+type MovieStore interface {
+	List(ctx context.Context, q MovieQuery) ([]model.Movie, error)
+	Get(ctx context.Context, id bson.ObjectID) (model.Movie, error)
+	GetByImdbID(ctx context.Context, imdbID string) (model.Movie, error)
+	Insert(ctx context.Context, movie model.Movie) error
+	// Replace overwrites the movie with movie.ID, or returns ErrNotFound.
+	Replace(ctx context.Context, movie model.Movie) error
+	Delete(ctx context.Context, id bson.ObjectID) error
+	// RenameGenre renames every embedded copy of the genre and returns how
+	// many movies changed.
+	RenameGenre(ctx context.Context, genreID int, name string) (int64, error)
+	CountWithGenre(ctx context.Context, genreID int) (int64, error)
+}
+
+// UserUpdate lists the user fields to change; nil fields are left as they are.
+// UpdatedAt is always bumped.
+type UserUpdate struct {
+	FirstName       *string
+	LastName        *string
+	FavouriteGenres *[]model.Genre
+	Role            *string
+	Status          *string
+	Password        *string
+	Token           *string
+	RefreshToken    *string
+}
+
+// RevokeSessions returns an update that clears the stored tokens.
+func RevokeSessions() UserUpdate {
+	empty := ""
+	return UserUpdate{Token: &empty, RefreshToken: &empty}
+}
+
+// UserStore persists users. List, Get and Update never return credentials;
+// only the *WithCredentials lookups do, for authentication.
+type UserStore interface {
+	List(ctx context.Context) ([]model.User, error)
+	Get(ctx context.Context, id bson.ObjectID) (model.User, error)
+	GetWithCredentials(ctx context.Context, id bson.ObjectID) (model.User, error)
+	GetByEmailWithCredentials(ctx context.Context, email string) (model.User, error)
+	// Insert returns ErrDuplicateKey when the email is already registered.
+	Insert(ctx context.Context, user model.User) error
+	Update(ctx context.Context, id bson.ObjectID, u UserUpdate) (model.User, error)
+	// RotateRefreshToken swaps the stored tokens only if the stored refresh
+	// token still equals current, and returns ErrNotFound otherwise.
+	RotateRefreshToken(ctx context.Context, id bson.ObjectID, current, access, refresh string) error
+	CountActiveAdmins(ctx context.Context) (int64, error)
+	// UpdateKeepingAdmin is Update, except that it returns ErrLastAdmin and
+	// leaves the user as it was when it would demote or disable the only
+	// active admin. Concurrent calls cannot each remove one of the last two
+	// admins; both may be refused instead.
+	UpdateKeepingAdmin(ctx context.Context, id bson.ObjectID, u UserUpdate) (model.User, error)
+	Delete(ctx context.Context, id bson.ObjectID) error
+	// DeleteKeepingAdmin is Delete with the guard of UpdateKeepingAdmin.
+	DeleteKeepingAdmin(ctx context.Context, id bson.ObjectID) error
+	// RenameGenre renames every copy of the genre in favourite genres and
+	// returns how many users changed.
+	RenameGenre(ctx context.Context, genreID int, name string) (int64, error)
+	CountWithGenre(ctx context.Context, genreID int) (int64, error)
+}
+
+// GenreStore persists the genre catalog. IDs are unique and so are names,
+// ignoring case: Insert and Update return ErrDuplicateKey on a clash.
+type GenreStore interface {
+	// List returns every genre ordered by ID.
+	List(ctx context.Context) ([]model.Genre, error)
+	Get(ctx context.Context, id int) (model.Genre, error)
+	Insert(ctx context.Context, genre model.Genre) error
+	// Update renames the genre with genre.GenreID, or returns ErrNotFound.
+	Update(ctx context.Context, genre model.Genre) error
+	Delete(ctx context.Context, id int) error
+}
+
+// RankingStore persists the ranking scale, which maps each ranking value to
+// its name. Values are unique and so are names, ignoring case.
+type RankingStore interface {
+	// List returns the scale ordered by value.
+	List(ctx context.Context) ([]model.Ranking, error)
+	Get(ctx context.Context, value int) (model.Ranking, error)
+	Insert(ctx context.Context, ranking model.Ranking) error
+}
+
+type AuditStore interface {
+	Record(ctx context.Context, entry model.AuditEntry) error
+}
+
+// removesAdmin reports whether applying u to user, or deleting user when u
+// is nil, takes away an active admin.
+func removesAdmin(user model.User, u *UserUpdate) bool {
+	if user.Role != model.RoleAdmin || user.IsDisabled() {
+		return false
+	}
+	if u == nil {
+		return true
+	}
+	return (u.Role != nil && *u.Role != model.RoleAdmin) || (u.Status != nil && *u.Status == model.UserStatusDisabled)
+}
+
+func stripCredentials(u model.User) model.User {
+	u.Password = ""
+	u.Token = ""
+	u.RefreshToken = ""
+	return u
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/logging"
+	"github.com/danielgtaylor/huma/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware starts a server span per request, named after the huma
+// OperationID and parented on an incoming traceparent header, and records it
+// for the access log. Register it first so the span covers the other
+// middlewares, the handler and response encoding.
+func Middleware() func(huma.Context, func(huma.Context)) {
+	tracer := otel.Tracer(instrumentationName)
+	return func(ctx huma.Context, next func(huma.Context)) {
+		op := ctx.Operation()
+		parent := otel.GetTextMapPropagator().Extract(ctx.Context(), headerCarrier{ctx})
+		spanCtx, span := tracer.Start(parent, op.OperationID,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(ctx.Method()),
+				semconv.HTTPRoute(op.Path),
+				semconv.URLPath(ctx.URL().Path),
+			),
+		)
+		defer span.End()
+		logging.SetSpanContext(spanCtx, span.SpanContext())
+
+		next(huma.WithContext(ctx, spanCtx))
+
+		status := ctx.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// headerCarrier adapts huma request headers for propagation.Extract.
+type headerCarrier struct {
+	ctx huma.Context
+}
+
+func (c headerCarrier) Get(key string) string {
+	return c.ctx.Header(key)
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c.ctx.SetHeader(key, value)
+}
+
+func (c headerCarrier) Keys() []string {
+	var keys []string
+	c.ctx.EachHeader(func(name, _ string) {
+		keys = append(keys, name)
+	})
+	return keys
+}
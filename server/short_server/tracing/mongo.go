@@ -0,0 +1,61 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/database"
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CommandMonitor returns a driver monitor that records each command as a
+// client span under the span in the operation's context. Spans are kept by
+// request ID between the started and finished events.
+func CommandMonitor() *event.CommandMonitor {
+	tracer := otel.Tracer(instrumentationName)
+	var spans sync.Map // request ID -> trace.Span
+
+	finish := func(requestID int64, err error) {
+		v, ok := spans.LoadAndDelete(requestID)
+		if !ok {
+			return
+		}
+		span := v.(trace.Span)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			name := e.CommandName
+			attrs := []attribute.KeyValue{
+				semconv.DBSystemNameMongoDB,
+				semconv.DBNamespace(e.DatabaseName),
+				semconv.DBOperationName(e.CommandName),
+			}
+			if collection := database.CommandCollection(e.CommandName, e.Command); collection != "" {
+				name += " " + collection
+				attrs = append(attrs, semconv.DBCollectionName(collection))
+			}
+			_, span := tracer.Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attrs...),
+			)
+			spans.Store(e.RequestID, span)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			finish(e.RequestID, nil)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			finish(e.RequestID, e.Failure)
+		},
+	}
+}
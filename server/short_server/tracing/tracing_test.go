@@ -0,0 +1,178 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/config"
+	"github.com/beheryahmed1991/ClipsStream/server/short_server/logging"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humagin"
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a global tracer provider that keeps finished spans in
+// memory and restores the previous globals when the test ends.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+	return rec
+}
+
+func TestMiddleware(t *testing.T) {
+	rec := recordSpans(t)
+	_, api := humatest.New(t)
+	api.UseMiddleware(Middleware())
+
+	var handlerSpan trace.SpanContext
+	huma.Register(api, huma.Operation{
+		OperationID: "get-movies", Method: http.MethodGet, Path: "/movies",
+	}, func(ctx context.Context, in *struct{}) (*struct{}, error) {
+		handlerSpan = trace.SpanContextFromContext(ctx)
+		return nil, huma.Error500InternalServerError("boom")
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	api.Get("/movies", "traceparent: 00-"+traceID+"-00f067aa0ba902b7-01")
+
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected one span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "get-movies" || span.SpanKind() != trace.SpanKindServer {
+		t.Fatalf("unexpected span %q kind %v", span.Name(), span.SpanKind())
+	}
+	if span.SpanContext().TraceID().String() != traceID || span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("span not parented on traceparent: %v parent %v", span.SpanContext(), span.Parent())
+	}
+	if handlerSpan.SpanID() != span.SpanContext().SpanID() {
+		t.Fatal("handler context does not carry the request span")
+	}
+	if span.Status().Code != codes.Error {
+		t.Fatalf("expected error status for 500, got %v", span.Status())
+	}
+}
+
+func TestAccessLogCarriesSpan(t *testing.T) {
+	rec := recordSpans(t)
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(logging.NewContextHandler(slog.NewJSONHandler(&buf, nil))))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(logging.AccessLog())
+	api := humagin.New(r, huma.DefaultConfig("test", "1.0.0"))
+	api.UseMiddleware(Middleware())
+	huma.Register(api, huma.Operation{
+		OperationID: "get-movies", Method: http.MethodGet, Path: "/movies",
+	}, func(ctx context.Context, in *struct{}) (*struct{}, error) {
+		return nil, nil
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/movies", nil))
+
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected one span, got %d", len(spans))
+	}
+	var access map[string]any
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &access); err != nil {
+		t.Fatalf("decode access log %q: %v", buf.String(), err)
+	}
+	sc := spans[0].SpanContext()
+	if access["trace_id"] != sc.TraceID().String() || access["span_id"] != sc.SpanID().String() {
+		t.Fatalf("access log %v does not carry span %v", access, sc)
+	}
+}
+
+func TestCommandMonitor(t *testing.T) {
+	rec := recordSpans(t)
+	mon := CommandMonitor()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	find, _ := bson.Marshal(bson.D{{Key: "find", Value: "movies"}})
+	mon.Started(ctx, &event.CommandStartedEvent{CommandName: "find", DatabaseName: "clips", RequestID: 1, Command: find})
+	mon.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", RequestID: 1}})
+
+	insert, _ := bson.Marshal(bson.D{{Key: "insert", Value: "users"}})
+	mon.Started(ctx, &event.CommandStartedEvent{CommandName: "insert", DatabaseName: "clips", RequestID: 2, Command: insert})
+	mon.Failed(ctx, &event.CommandFailedEvent{
+		CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "insert", RequestID: 2},
+		Failure:              errors.New("E11000 duplicate key"),
+	})
+	parent.End()
+
+	spans := rec.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected three spans, got %d", len(spans))
+	}
+	for i, want := range []string{"find movies", "insert users"} {
+		s := spans[i]
+		if s.Name() != want || s.SpanKind() != trace.SpanKindClient {
+			t.Fatalf("span %d: got %q kind %v, want %q", i, s.Name(), s.SpanKind(), want)
+		}
+		if s.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Fatalf("span %q is not a child of the request span", s.Name())
+		}
+	}
+	if spans[1].Status().Code != codes.Error {
+		t.Fatalf("expected failed command to mark the span as an error, got %v", spans[1].Status())
+	}
+}
+
+func TestSetupFileExporter(t *testing.T) {
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	cfg := config.Default().Tracing
+	cfg.Exporter = config.TracingExporterFile
+	cfg.File = filepath.Join(t.TempDir(), "spans.json")
+	shutdown, err := Setup(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	_, span := otel.Tracer("test").Start(context.Background(), "offline-span")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	raw, err := os.ReadFile(cfg.File)
+	if err != nil {
+		t.Fatalf("read spans: %v", err)
+	}
+	if !strings.Contains(string(raw), `"offline-span"`) {
+		t.Fatalf("span not exported to file: %s", raw)
+	}
+}